@@ -0,0 +1,485 @@
+package evaluators_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/expr"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+// conformanceCase is a condition fixture every engine must evaluate alike.
+type conformanceCase struct {
+	name string
+	cond policies.PolicyCondition
+	res  bool
+	err  bool
+}
+
+var conformanceAttrs = policies.MapAttributes{
+	"user": map[string]any{
+		"id":     "u1",
+		"name":   "Alice Smith",
+		"age":    30,
+		"score":  7.5,
+		"roles":  []any{"admin", "dev"},
+		"active": true,
+	},
+	"doc": map[string]any{
+		"owner":   "u1",
+		"tags":    []any{"public", "draft"},
+		"created": "2021-06-01",
+		"size":    120,
+	},
+	"limits": map[string]any{"size": 100, "min_age": 18},
+	"quote":  `a "quoted" value`,
+	"none":   nil,
+}
+
+func leaf(attr string, op policies.Operator, v any) policies.PolicyCondition {
+	return policies.PolicyCondition{Attribute: attr, Operator: op, Value: v}
+}
+
+func node(op policies.Operator, children ...policies.PolicyCondition) policies.PolicyCondition {
+	return policies.PolicyCondition{Operator: op, Conditions: children}
+}
+
+var conformanceCases = []conformanceCase{
+	// Comparison
+	{name: "eq string", cond: leaf("user.id", policies.OpEqual, "u1"), res: true},
+	{name: "eq string mismatch", cond: leaf("user.id", policies.OpEqual, "u2"), res: false},
+	{name: "eq string with quotes", cond: leaf("quote", policies.OpEqual, `a "quoted" value`), res: true},
+	{name: "eq bool", cond: leaf("user.active", policies.OpEqual, true), res: true},
+	{name: "neq", cond: leaf("user.id", policies.OpNotEqual, "u2"), res: true},
+	{name: "gt", cond: leaf("user.age", policies.OpGreater, 18), res: true},
+	{name: "gte equal", cond: leaf("user.age", policies.OpGreaterOrEqual, 30), res: true},
+	{name: "lt", cond: leaf("user.age", policies.OpLess, 30), res: false},
+	{name: "lte float", cond: leaf("user.score", policies.OpLessOrEqual, 7.5), res: true},
+	{name: "eq ref", cond: leaf("doc.owner", policies.OpEqual, policies.Ref("user.id")), res: true},
+	{name: "eq int float", cond: leaf("user.age", policies.OpEqual, 30.0), res: true},
+	{name: "eq json number", cond: leaf("user.age", policies.OpEqual, json.Number("30")), res: true},
+	{name: "eq rat", cond: leaf("user.score", policies.OpEqual, big.NewRat(15, 2)), res: true},
+	{name: "lt rat", cond: leaf("user.score", policies.OpLess, big.NewRat(11, 2)), res: false},
+	{name: "gt rat", cond: leaf("user.age", policies.OpGreater, big.NewRat(59, 2)), res: true},
+	{name: "neq numeric string", cond: leaf("user.age", policies.OpNotEqual, "30"), res: true},
+	{name: "gt int float", cond: leaf("user.score", policies.OpGreater, 7), res: true},
+	{name: "gt ref", cond: leaf("doc.size", policies.OpGreater, policies.Ref("limits.size")), res: true},
+
+	// Range
+	{name: "between inclusive", cond: leaf("user.age", policies.OpBetween, []any{18, 30}), res: true},
+	{name: "between exclusive", cond: leaf("user.age", policies.OpBetween, []any{18, 30, false}), res: false},
+	{name: "between map form", cond: leaf("user.age", policies.OpBetween, map[string]any{"min": 31, "max": 40}), res: false},
+	{name: "between refs", cond: leaf("user.age", policies.OpBetween, []any{policies.Ref("limits.min_age"), policies.Ref("limits.size")}), res: true},
+
+	// Set
+	{name: "in", cond: leaf("user.id", policies.OpIn, []any{"u1", "u2"}), res: true},
+	{name: "nin", cond: leaf("user.id", policies.OpNotIn, []any{"u1", "u2"}), res: false},
+	{name: "in mixed numbers", cond: leaf("user.age", policies.OpIn, []any{18.0, 30.0}), res: true},
+	{name: "in rats", cond: leaf("user.score", policies.OpIn, []any{big.NewRat(15, 2), big.NewRat(1, 3)}), res: true},
+	{name: "in ref", cond: leaf("doc.owner", policies.OpIn, []any{policies.Ref("user.id")}), res: true},
+	{name: "subset", cond: leaf("user.roles", policies.OpSubset, []any{"admin", "dev", "ops"}), res: true},
+	{name: "not_subset", cond: leaf("user.roles", policies.OpNotSubset, []any{"admin"}), res: true},
+	{name: "intersects", cond: leaf("doc.tags", policies.OpIntersects, []any{"draft", "archived"}), res: true},
+	{name: "disjoint", cond: leaf("doc.tags", policies.OpDisjoint, []any{"draft", "archived"}), res: false},
+
+	// String
+	{name: "contains", cond: leaf("user.name", policies.OpContains, "Smith"), res: true},
+	{name: "not_contains", cond: leaf("user.name", policies.OpNotContains, "Smith"), res: false},
+	{name: "starts_with", cond: leaf("user.name", policies.OpStartsWith, "Alice"), res: true},
+	{name: "ends_with", cond: leaf("user.name", policies.OpEndsWith, "Alice"), res: false},
+	{name: "matches", cond: leaf("user.name", policies.OpMatches, `^A\w+ S`), res: true},
+	{name: "contains number", cond: leaf("user.age", policies.OpContains, 3), res: true},
+
+	// Temporal
+	{name: "before", cond: leaf("doc.created", policies.OpBefore, "2022-01-01"), res: true},
+	{name: "after", cond: leaf("doc.created", policies.OpAfter, "2022-01-01"), res: false},
+
+	// Arithmetic
+	{name: "mod", cond: leaf("user.age", policies.OpMod, 5), res: true},
+	{name: "mod remainder", cond: leaf("user.age", policies.OpMod, 7), res: false},
+	{name: "mod rat", cond: leaf("user.score", policies.OpMod, big.NewRat(5, 2)), res: true},
+
+	// Logical
+	{name: "and", cond: node(policies.OpAnd, leaf("user.active", policies.OpEqual, true), leaf("user.age", policies.OpGreater, 40)), res: false},
+	{name: "or", cond: node(policies.OpOr, leaf("user.id", policies.OpEqual, "x"), leaf("user.age", policies.OpGreater, 18)), res: true},
+	{name: "not", cond: node(policies.OpNot, leaf("user.id", policies.OpEqual, "u1")), res: false},
+	{name: "nested", cond: node(policies.OpAnd,
+		node(policies.OpNot, leaf("doc.tags", policies.OpIntersects, []any{"secret"})),
+		node(policies.OpOr, leaf("doc.owner", policies.OpEqual, policies.Ref("user.id")), leaf("user.roles", policies.OpIntersects, []any{"admin"})),
+	), res: true},
+
+	// Presence
+	{name: "exists", cond: leaf("user.id", policies.OpExists, nil), res: true},
+	{name: "exists nil value", cond: leaf("none", policies.OpExists, nil), res: true},
+	{name: "exists missing", cond: leaf("user.missing", policies.OpExists, nil), res: false},
+	{name: "not_exists", cond: leaf("user.missing", policies.OpNotExists, nil), res: true},
+	{name: "not_exists present", cond: leaf("user.id", policies.OpNotExists, nil), res: false},
+	{name: "exists guards missing", cond: node(policies.OpAnd, leaf("user.missing", policies.OpExists, nil), leaf("user.missing", policies.OpGreater, 1)), res: false},
+
+	// Errors
+	{name: "injected value stays a literal", cond: leaf("user.id", policies.OpEqual, `u1" || true || "`), res: false},
+	{name: "invalid attribute", cond: leaf("user.id == user.id || x", policies.OpEqual, "u1"), err: true},
+	{name: "missing attribute", cond: leaf("user.missing", policies.OpGreater, 1), err: true},
+	{name: "missing reference", cond: leaf("user.id", policies.OpEqual, policies.Ref("user.missing")), err: true},
+}
+
+// compiledEngine evaluates conditions with programs compiled by a
+// NativeEngine.
+type compiledEngine struct {
+	*native.NativeEngine
+}
+
+func (e compiledEngine) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	program, err := e.Compile(pc)
+	if err != nil {
+		return false, err
+	}
+	return program.Eval(context.Background(), attr)
+}
+
+func TestEngines_Conformance(t *testing.T) {
+	engines := map[string]policies.Engine{
+		"native":          native.NewNativeEngine(),
+		"native-compiled": compiledEngine{native.NewNativeEngine().(*native.NativeEngine)},
+		"expr":            expr.NewEngine(),
+	}
+
+	for name, eng := range engines {
+		for _, tt := range conformanceCases {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				res, err := eng.Eval(tt.cond, conformanceAttrs)
+				if tt.err {
+					assert.Error(t, err)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tt.res, res)
+			})
+		}
+	}
+}
+
+func TestEngines_Coercion(t *testing.T) {
+	tests := []struct {
+		name string
+		cond policies.PolicyCondition
+		res  map[utils.Coercion]bool
+		err  map[utils.Coercion]bool
+	}{
+		{
+			name: "eq int float",
+			cond: leaf("user.age", policies.OpEqual, 30.0),
+			res:  map[utils.Coercion]bool{utils.CoerceNumbers: true, utils.CoerceNumericStrings: true},
+		},
+		{
+			name: "eq numeric string",
+			cond: leaf("user.age", policies.OpEqual, "30"),
+			res:  map[utils.Coercion]bool{utils.CoerceNumericStrings: true},
+		},
+		{
+			name: "in mixed numbers",
+			cond: leaf("user.age", policies.OpIn, []any{"18", 30.0}),
+			res:  map[utils.Coercion]bool{utils.CoerceNumbers: true, utils.CoerceNumericStrings: true},
+		},
+		{
+			name: "in numeric strings",
+			cond: leaf("user.age", policies.OpIn, []any{"18", "30"}),
+			res:  map[utils.Coercion]bool{utils.CoerceNumericStrings: true},
+		},
+		{
+			name: "gt int float",
+			cond: leaf("user.score", policies.OpGreater, 7),
+			res:  map[utils.Coercion]bool{utils.CoerceNumbers: true, utils.CoerceNumericStrings: true},
+			err:  map[utils.Coercion]bool{utils.CoerceNone: true},
+		},
+		{
+			name: "lt numeric string",
+			cond: leaf("user.age", policies.OpLess, "100"),
+			res:  map[utils.Coercion]bool{utils.CoerceNumericStrings: true},
+			err:  map[utils.Coercion]bool{utils.CoerceNumbers: true, utils.CoerceNone: true},
+		},
+		{
+			name: "between int floats",
+			cond: leaf("user.age", policies.OpBetween, []any{29.5, 30.5}),
+			res:  map[utils.Coercion]bool{utils.CoerceNumbers: true, utils.CoerceNumericStrings: true},
+			err:  map[utils.Coercion]bool{utils.CoerceNone: true},
+		},
+	}
+
+	for _, c := range []utils.Coercion{utils.CoerceNumbers, utils.CoerceNumericStrings, utils.CoerceNone} {
+		engines := map[string]policies.Engine{
+			"native":          native.NewNativeEngine(native.WithCoercion(c)),
+			"native-compiled": compiledEngine{native.NewNativeEngine(native.WithCoercion(c)).(*native.NativeEngine)},
+			"expr":            expr.NewEngine(expr.WithCoercion(c)),
+		}
+
+		for name, eng := range engines {
+			for _, tt := range tests {
+				t.Run(name+"/"+c.String()+"/"+tt.name, func(t *testing.T) {
+					res, err := eng.Eval(tt.cond, conformanceAttrs)
+					if tt.err[c] {
+						assert.Error(t, err)
+						return
+					}
+					assert.NoError(t, err)
+					assert.Equal(t, tt.res[c], res)
+				})
+			}
+		}
+	}
+}
+
+func TestEngines_Decimals(t *testing.T) {
+	attrs := policies.MapAttributes{
+		"order": map[string]any{
+			"total": json.Number("100.30"),
+			"fee":   "0.10",
+			"rate":  0.1,
+		},
+	}
+	tests := []struct {
+		name string
+		cond policies.PolicyCondition
+		res  bool
+	}{
+		{name: "eq trailing zeros", cond: leaf("order.total", policies.OpEqual, json.Number("100.3")), res: true},
+		{name: "gt beyond float precision", cond: leaf("order.total", policies.OpGreater, json.Number("100.29999999999999999")), res: true},
+		{name: "lte beyond float precision", cond: leaf("order.total", policies.OpLessOrEqual, json.Number("100.29999999999999999")), res: false},
+		{name: "between exclusive bound", cond: leaf("order.total", policies.OpBetween, []any{json.Number("100.30"), json.Number("200"), false}), res: false},
+		{name: "between inclusive bound", cond: leaf("order.total", policies.OpBetween, []any{json.Number("100.30"), json.Number("200")}), res: true},
+		{name: "in decimals", cond: leaf("order.total", policies.OpIn, []any{json.Number("100.300"), json.Number("200")}), res: true},
+		{name: "mod cents", cond: leaf("order.total", policies.OpMod, json.Number("0.01")), res: true},
+		{name: "mod string decimal", cond: leaf("order.total", policies.OpMod, "0.05"), res: true},
+		{name: "mod remainder", cond: leaf("order.total", policies.OpMod, json.Number("0.07")), res: false},
+		{name: "mod of string decimal", cond: leaf("order.fee", policies.OpMod, json.Number("0.05")), res: true},
+		{name: "eq float nearest decimal", cond: leaf("order.rate", policies.OpEqual, json.Number("0.1")), res: true},
+		{name: "eq rat", cond: leaf("order.total", policies.OpEqual, big.NewRat(1003, 10)), res: true},
+		{name: "lt repeating rat", cond: leaf("order.total", policies.OpLess, big.NewRat(301, 3)), res: true},
+		{name: "between rats", cond: leaf("order.total", policies.OpBetween, []any{big.NewRat(1003, 10), big.NewRat(301, 3)}), res: true},
+		{name: "mod rat cents", cond: leaf("order.total", policies.OpMod, big.NewRat(1, 100)), res: true},
+	}
+
+	engines := map[string]policies.Engine{
+		"native":          native.NewNativeEngine(),
+		"native-compiled": compiledEngine{native.NewNativeEngine().(*native.NativeEngine)},
+		"expr":            expr.NewEngine(),
+	}
+
+	for name, eng := range engines {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				res, err := eng.Eval(tt.cond, attrs)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.res, res)
+			})
+		}
+	}
+}
+
+func TestEngines_Arithmetic(t *testing.T) {
+	attrs := policies.MapAttributes{
+		"request": map[string]any{
+			"amount":   json.Number("19.99"),
+			"quantity": 3,
+			"fee":      "0.10",
+		},
+		"subject": map[string]any{"limit": 60},
+	}
+	amount, quantity, limit := policies.Ref("request.amount"), policies.Ref("request.quantity"), policies.Ref("subject.limit")
+	expression := func(x *policies.Arithmetic, op policies.Operator, v any) policies.PolicyCondition {
+		return policies.PolicyCondition{Expression: x, Operator: op, Value: v}
+	}
+
+	tests := []struct {
+		name string
+		cond policies.PolicyCondition
+		res  bool
+		err  string
+	}{
+		{name: "mul lte ref", cond: expression(policies.Arith(policies.ArithMul, amount, quantity), policies.OpLessOrEqual, limit), res: true},
+		{name: "mul eq exact", cond: expression(policies.Arith(policies.ArithMul, amount, quantity), policies.OpEqual, json.Number("59.97")), res: true},
+		{name: "add decimals", cond: expression(policies.Arith(policies.ArithAdd, policies.Ref("request.fee"), json.Number("0.2")), policies.OpEqual, json.Number("0.3")), res: true},
+		{name: "abs of sub", cond: expression(policies.Arith(policies.ArithAbs, policies.Arith(policies.ArithSub, quantity, 5)), policies.OpEqual, 2), res: true},
+		{name: "min", cond: expression(policies.Arith(policies.ArithMin, limit, 50, 55), policies.OpEqual, 50), res: true},
+		{name: "max", cond: expression(policies.Arith(policies.ArithMax, limit, 50), policies.OpEqual, 60), res: true},
+		{name: "mod remainder", cond: expression(policies.Arith(policies.ArithMod, quantity, 2), policies.OpEqual, 1), res: true},
+		{name: "in", cond: expression(policies.Arith(policies.ArithMul, quantity, 2), policies.OpIn, []any{6, 7}), res: true},
+		{name: "value side", cond: leaf("request.quantity", policies.OpGreaterOrEqual, policies.Arith(policies.ArithDiv, limit, amount)), res: false},
+		{name: "between bounds", cond: leaf("request.amount", policies.OpBetween, []any{policies.Arith(policies.ArithSub, limit, 50), limit}), res: true},
+		{name: "both sides", cond: expression(policies.Arith(policies.ArithMul, quantity, 20), policies.OpGreater, policies.Arith(policies.ArithMul, amount, 3)), res: true},
+		{name: "json form", cond: expression(policies.Arith(policies.ArithDiv, limit, 4), policies.OpEqual, map[string]any{"op": "add", "args": []any{10, map[string]any{"ref": "request.quantity"}, 2}}), res: true},
+		{name: "mod operator remainder", cond: leaf("request.quantity", policies.OpMod, []any{2, 1}), res: true},
+		{name: "mod operator expression", cond: expression(policies.Arith(policies.ArithMul, amount, 100), policies.OpMod, []any{7, 4}), res: true},
+		{name: "div by zero", cond: expression(policies.Arith(policies.ArithDiv, limit, 0), policies.OpEqual, 1), err: "div: division by zero"},
+		{name: "not numeric", cond: expression(policies.Arith(policies.ArithAdd, limit, "x"), policies.OpEqual, 1), err: "add: strconv.ParseFloat: parsing \"x\": invalid syntax"},
+	}
+
+	engines := map[string]policies.Engine{
+		"native":          native.NewNativeEngine(),
+		"native-compiled": compiledEngine{native.NewNativeEngine().(*native.NativeEngine)},
+		"expr":            expr.NewEngine(),
+	}
+
+	for name, eng := range engines {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				res, err := eng.Eval(tt.cond, attrs)
+				if tt.err != "" {
+					assert.ErrorContains(t, err, tt.err)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tt.res, res)
+			})
+		}
+	}
+}
+
+func TestEngines_MissingAttributes(t *testing.T) {
+	missingLeaves := []policies.PolicyCondition{
+		leaf("user.missing", policies.OpGreater, 1),
+		leaf("user.missing", policies.OpNotIn, []any{"a"}),
+		leaf("user.missing", policies.OpNotContains, "a"),
+		leaf("user.missing", policies.OpBetween, []any{1, 2}),
+		leaf("user.missing", policies.OpBefore, "2022-01-01"),
+		leaf("user.missing", policies.OpMod, 2),
+		leaf("user.missing", policies.OpDisjoint, []any{"a"}),
+		{Expression: policies.Arith(policies.ArithAdd, policies.Ref("user.age"), policies.Ref("user.missing")), Operator: policies.OpEqual, Value: 1},
+		leaf("user.id", policies.OpEqual, policies.Ref("user.missing")),
+		leaf("user.id", policies.OpNotIn, []any{"a", policies.Ref("user.missing")}),
+		leaf("user.age", policies.OpBetween, []any{1, policies.Ref("user.missing")}),
+		leaf("user.age", policies.OpLess, policies.Arith(policies.ArithAdd, policies.Ref("user.missing"), 1)),
+	}
+
+	for _, m := range []policies.MissingAttributes{policies.MissingError, policies.MissingFalse, policies.MissingIndeterminate} {
+		engines := map[string]policies.Engine{
+			"native":          native.NewNativeEngine(native.WithMissingAttributes(m)),
+			"native-compiled": compiledEngine{native.NewNativeEngine(native.WithMissingAttributes(m)).(*native.NativeEngine)},
+			"expr":            expr.NewEngine(expr.WithMissingAttributes(m)),
+		}
+
+		for name, eng := range engines {
+			for _, cond := range missingLeaves {
+				t.Run(name+"/"+m.String()+"/"+string(cond.Operator)+"/"+cond.Attribute, func(t *testing.T) {
+					res, err := eng.Eval(cond, conformanceAttrs)
+					assert.False(t, res)
+					switch m {
+					case policies.MissingFalse:
+						assert.NoError(t, err)
+					case policies.MissingIndeterminate:
+						assert.ErrorIs(t, err, policies.ErrIndeterminate)
+						assert.EqualError(t, err, "indeterminate: missing attribute: user.missing")
+					default:
+						assert.ErrorIs(t, err, policies.ErrMissingAttribute)
+						assert.NotErrorIs(t, err, policies.ErrIndeterminate)
+						assert.EqualError(t, err, "missing required attribute: user.missing")
+					}
+				})
+			}
+
+			t.Run(name+"/"+m.String()+"/or", func(t *testing.T) {
+				cond := node(policies.OpOr, leaf("user.id", policies.OpEqual, "u1"), leaf("user.missing", policies.OpEqual, 1))
+				res, err := eng.Eval(cond, conformanceAttrs)
+				assert.NoError(t, err)
+				assert.True(t, res)
+			})
+		}
+	}
+}
+
+func TestEngines_ThreeValuedLogic(t *testing.T) {
+	missing := leaf("user.missing", policies.OpEqual, 1)
+	other := leaf("user.other", policies.OpEqual, 1)
+	yes := leaf("user.active", policies.OpEqual, true)
+	no := leaf("user.active", policies.OpEqual, false)
+	// Conditions failing on the values of present attributes are unknown
+	// too, but their errors are failures rather than indeterminate.
+	mismatch := leaf("user.id", policies.OpGreater, 5)
+	notTime := leaf("user.id", policies.OpBefore, "2022-01-01")
+	notNumber := leaf("user.id", policies.OpMod, 2)
+	divByZero := policies.PolicyCondition{Expression: policies.Arith(policies.ArithDiv, policies.Ref("user.age"), 0), Operator: policies.OpEqual, Value: 1}
+
+	tests := []struct {
+		name    string
+		cond    policies.PolicyCondition
+		res     bool
+		err     string
+		failure bool
+	}{
+		{name: "and unknown false", cond: node(policies.OpAnd, missing, no), res: false},
+		{name: "and false unknown", cond: node(policies.OpAnd, no, missing), res: false},
+		{name: "and unknown true", cond: node(policies.OpAnd, missing, yes), err: "indeterminate: missing attribute: user.missing"},
+		{name: "or unknown true", cond: node(policies.OpOr, missing, yes), res: true},
+		{name: "or unknown false", cond: node(policies.OpOr, no, missing), err: "indeterminate: missing attribute: user.missing"},
+		{name: "not unknown", cond: node(policies.OpNot, missing), err: "indeterminate: missing attribute: user.missing"},
+		{name: "not decided", cond: node(policies.OpNot, node(policies.OpAnd, missing, no)), res: true},
+		{name: "unknown causes", cond: node(policies.OpOr, missing, node(policies.OpAnd, yes, other)), err: "indeterminate: missing attribute: user.missing; indeterminate: missing attribute: user.other"},
+		{name: "or mismatch true", cond: node(policies.OpOr, mismatch, yes), res: true},
+		{name: "or true mismatch", cond: node(policies.OpOr, yes, mismatch), res: true},
+		{name: "or mismatch false", cond: node(policies.OpOr, mismatch, no), err: "cant compare differente type: string x int", failure: true},
+		{name: "and mismatch false", cond: node(policies.OpAnd, mismatch, no), res: false},
+		{name: "and false mismatch", cond: node(policies.OpAnd, no, mismatch), res: false},
+		{name: "and mismatch true", cond: node(policies.OpAnd, mismatch, yes), err: "cant compare differente type: string x int", failure: true},
+		{name: "not mismatch", cond: node(policies.OpNot, mismatch), err: "cant compare differente type: string x int", failure: true},
+		{name: "not decided by mismatch", cond: node(policies.OpNot, node(policies.OpAnd, mismatch, no)), res: true},
+		{name: "or invalid time true", cond: node(policies.OpOr, notTime, yes), res: true},
+		{name: "and invalid number false", cond: node(policies.OpAnd, notNumber, no), res: false},
+		{name: "or arithmetic error true", cond: node(policies.OpOr, divByZero, yes), res: true},
+		{name: "and arithmetic error true", cond: node(policies.OpAnd, divByZero, yes), err: "div: division by zero", failure: true},
+		{name: "failure over indeterminate", cond: node(policies.OpAnd, missing, mismatch, yes), err: "cant compare differente type: string x int", failure: true},
+	}
+
+	m := policies.MissingIndeterminate
+	engines := map[string]policies.Engine{
+		"native":          native.NewNativeEngine(native.WithMissingAttributes(m)),
+		"native-compiled": compiledEngine{native.NewNativeEngine(native.WithMissingAttributes(m)).(*native.NativeEngine)},
+		"expr":            expr.NewEngine(expr.WithMissingAttributes(m)),
+	}
+
+	for name, eng := range engines {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				res, err := eng.Eval(tt.cond, conformanceAttrs)
+				if tt.failure {
+					assert.EqualError(t, err, tt.err)
+					assert.NotErrorIs(t, err, policies.ErrIndeterminate)
+					return
+				}
+				if tt.err != "" {
+					assert.EqualError(t, err, tt.err)
+					assert.ErrorIs(t, err, policies.ErrIndeterminate)
+					assert.ErrorIs(t, err, policies.ErrMissingAttribute)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, tt.res, res)
+			})
+		}
+	}
+}
+
+func TestExprBuilder_SupportsEveryBuiltInOperator(t *testing.T) {
+	builder := expr.NewExprBuilder()
+
+	for _, op := range policies.Operators() {
+		spec, _ := policies.OperatorSpecOf(op)
+		if spec.Kind == policies.KindCustom {
+			continue
+		}
+		cond := leaf("user.roles", op, []any{"a", "b"})
+		if spec.Kind == policies.KindLogical {
+			cond = node(op, leaf("a", policies.OpEqual, 1), leaf("b", policies.OpEqual, 2))
+			if op == policies.OpNot {
+				cond.Conditions = cond.Conditions[:1]
+			}
+		}
+
+		_, err := builder.Build(cond)
+		assert.NoError(t, err, "operator %s", op)
+	}
+}
@@ -17,7 +17,18 @@ type exprBuilder struct {
 	builders map[policies.Operator]ExprBuilder
 }
 
-func NewExprBuilder() ExprBuilder {
+// BuilderOption configures the ExprBuilder built by NewExprBuilder.
+type BuilderOption func(*exprBuilder)
+
+// WithOperatorBuilder registers b for op, adding an operator registered with
+// policies.RegisterOperator or overriding a built-in one.
+func WithOperatorBuilder(op policies.Operator, b ExprBuilder) BuilderOption {
+	return func(h *exprBuilder) {
+		h.builders[op] = b
+	}
+}
+
+func NewExprBuilder(opts ...BuilderOption) ExprBuilder {
 	builders := map[policies.Operator]ExprBuilder{
 		// Comparison
 		policies.OpEqual:          &ComparisonExprBuilder{},
@@ -27,14 +38,16 @@ func NewExprBuilder() ExprBuilder {
 		policies.OpGreater:        &ComparisonExprBuilder{},
 		policies.OpGreaterOrEqual: &ComparisonExprBuilder{},
 
-		// Logical
-		policies.OpAnd: &LogicalExprBuilder{},
-		policies.OpOr:  &LogicalExprBuilder{},
-		policies.OpNot: &LogicalExprBuilder{},
+		// Range
+		policies.OpBetween: &RangeExprBuilder{},
 
 		// Set
-		policies.OpIn:    &SetExprBuilder{},
-		policies.OpNotIn: &SetExprBuilder{},
+		policies.OpIn:         &SetExprBuilder{},
+		policies.OpNotIn:      &SetExprBuilder{},
+		policies.OpSubset:     &SetExprBuilder{},
+		policies.OpNotSubset:  &SetExprBuilder{},
+		policies.OpIntersects: &SetExprBuilder{},
+		policies.OpDisjoint:   &SetExprBuilder{},
 
 		// String functions
 		policies.OpContains:    &FunctionExprBuilder{},
@@ -49,9 +62,21 @@ func NewExprBuilder() ExprBuilder {
 
 		// Arithmetic
 		policies.OpMod: &ArithmeticExprBuilder{},
+
+		// Presence
+		policies.OpExists:    &PresenceExprBuilder{},
+		policies.OpNotExists: &PresenceExprBuilder{},
 	}
 
-	return &exprBuilder{builders: builders}
+	b := &exprBuilder{builders: builders}
+	logical := &LogicalExprBuilder{Builder: b}
+	for _, op := range []policies.Operator{policies.OpAnd, policies.OpOr, policies.OpNot} {
+		b.builders[op] = logical
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
 func (h *exprBuilder) Build(cond policies.PolicyCondition) (string, error) {
@@ -63,7 +88,10 @@ func (h *exprBuilder) Build(cond policies.PolicyCondition) (string, error) {
 }
 
 // LogicalExprBuilder builds logical expressions (and/or/not) for the expr language.
+// Child conditions are built with Builder, or with a default ExprBuilder when
+// Builder is nil.
 type LogicalExprBuilder struct {
+	Builder ExprBuilder
 }
 
 // Build builds a logical expression for cond.
@@ -83,18 +111,18 @@ func (h *LogicalExprBuilder) Build(cond policies.PolicyCondition) (string, error
 		if len(cond.Conditions) != 1 {
 			return "", fmt.Errorf("operator 'not' requires exactly one condition")
 		}
-		expr, err := NewExprBuilder().Build(cond.Conditions[0])
+		expr, err := h.children().Build(cond.Conditions[0])
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("!%s", expr), nil
+		return fmt.Sprintf("!(%s)", expr), nil
 	default:
 		return "", fmt.Errorf("unsupported logical operator: %s", cond.Operator)
 	}
 
 	parts := make([]string, 0, len(cond.Conditions))
 	for _, c := range cond.Conditions {
-		expr, err := NewExprBuilder().Build(c)
+		expr, err := h.children().Build(c)
 		if err != nil {
 			return "", err
 		}
@@ -104,6 +132,13 @@ func (h *LogicalExprBuilder) Build(cond policies.PolicyCondition) (string, error
 	return joinWithOperator(parts, op), nil
 }
 
+func (h *LogicalExprBuilder) children() ExprBuilder {
+	if h.Builder == nil {
+		return NewExprBuilder()
+	}
+	return h.Builder
+}
+
 func joinWithOperator(parts []string, op string) string {
 	return stringJoin(parts, " "+op+" ")
 }
@@ -123,16 +158,20 @@ func stringJoin(parts []string, sep string) string {
 type ComparisonExprBuilder struct {
 }
 
-// Build builds a comparison expression for cond.
+// Build builds a comparison expression for cond. Values are compared as by
+// the native engine, numbers by value whatever their type.
 func (h *ComparisonExprBuilder) Build(cond policies.PolicyCondition) (string, error) {
-	// Exemplo: attribute == value
-	// Ajuste para o operador correto
+	attr, value, err := operands(cond)
+	if err != nil {
+		return "", err
+	}
+
 	var op string
 	switch cond.Operator {
 	case policies.OpEqual:
-		op = "=="
+		return fmt.Sprintf("%s(%s, %s)", fnEqual, attr, value), nil
 	case policies.OpNotEqual:
-		op = "!="
+		return fmt.Sprintf("%s(%s(%s, %s))", fnNot, fnEqual, attr, value), nil
 	case policies.OpLess:
 		op = "<"
 	case policies.OpLessOrEqual:
@@ -144,12 +183,40 @@ func (h *ComparisonExprBuilder) Build(cond policies.PolicyCondition) (string, er
 	default:
 		return "", fmt.Errorf("unsupported comparison operator: %s", cond.Operator)
 	}
+	return fmt.Sprintf("%s($env, %s, %s, %q)", fnCompare, attr, value, op), nil
+}
+
+// RangeExprBuilder builds range expressions (between).
+type RangeExprBuilder struct{}
+
+// Build builds a range expression for cond. The bounds are parsed with
+// policies.ParseBetweenValue and are inclusive unless stated otherwise.
+func (h *RangeExprBuilder) Build(cond policies.PolicyCondition) (string, error) {
+	if cond.Operator != policies.OpBetween {
+		return "", fmt.Errorf("unsupported range operator: %s", cond.Operator)
+	}
 
-	// Supondo que Value pode ser string, número, etc. Ajuste a formatação conforme necessário
-	return fmt.Sprintf("%s %s %v", cond.Attribute, op, cond.Value), nil
+	attr, err := subject(cond)
+	if err != nil {
+		return "", err
+	}
+	min, max, inclusive, err := policies.ParseBetweenValue(cond.Value)
+	if err != nil {
+		return "", err
+	}
+	minExpr, err := operand(min)
+	if err != nil {
+		return "", err
+	}
+	maxExpr, err := operand(max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s($env, %s, %s, %s, %t)", fnBetween, attr, minExpr, maxExpr, inclusive), nil
 }
 
-// ArithmeticExprBuilder builds arithmetic expressions (currently mod).
+// ArithmeticExprBuilder builds arithmetic expressions (currently mod). The
+// value is parsed with policies.ParseModValue.
 type ArithmeticExprBuilder struct{}
 
 // Build builds an arithmetic expression for cond.
@@ -158,58 +225,84 @@ func (h *ArithmeticExprBuilder) Build(cond policies.PolicyCondition) (string, er
 		return "", fmt.Errorf("unsupported arithmetic operator: %s", cond.Operator)
 	}
 
-	return fmt.Sprintf("(%s %% %v)", cond.Attribute, cond.Value), nil
+	attr, err := subject(cond)
+	if err != nil {
+		return "", err
+	}
+	divisor, remainder, err := policies.ParseModValue(cond.Value)
+	if err != nil {
+		return "", err
+	}
+	divisorExpr, err := operand(divisor)
+	if err != nil {
+		return "", err
+	}
+	remainderExpr, err := operand(remainder)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s($env, %s, %s, %s)", fnRemainder, attr, divisorExpr, remainderExpr), nil
 }
 
-// SetExprBuilder builds set membership expressions.
+// SetExprBuilder builds set membership and set relation expressions. Elements
+// are compared as by the native engine.
 type SetExprBuilder struct{}
 
 // Build builds a set expression for cond.
 func (h *SetExprBuilder) Build(cond policies.PolicyCondition) (string, error) {
-	var op string
+	attr, value, err := operands(cond)
+	if err != nil {
+		return "", err
+	}
+
 	switch cond.Operator {
 	case policies.OpIn:
-		op = "in"
+		return fmt.Sprintf("%s(%s, %s)", fnIn, attr, value), nil
 	case policies.OpNotIn:
-		op = "not in"
+		return fmt.Sprintf("%s(%s(%s, %s))", fnNot, fnIn, attr, value), nil
+	case policies.OpSubset:
+		return fmt.Sprintf("all(%s, %s(#, %s))", attr, fnIn, value), nil
+	case policies.OpNotSubset:
+		return fmt.Sprintf("!all(%s, %s(#, %s))", attr, fnIn, value), nil
+	case policies.OpIntersects:
+		return fmt.Sprintf("any(%s, %s(#, %s))", attr, fnIn, value), nil
+	case policies.OpDisjoint:
+		return fmt.Sprintf("none(%s, %s(#, %s))", attr, fnIn, value), nil
 	default:
 		return "", fmt.Errorf("unsupported set operator: %s", cond.Operator)
 	}
-
-	// Value deve ser uma lista/array
-	values, ok := cond.Value.([]any)
-	if !ok {
-		return "", fmt.Errorf("operator %s requires a slice value", cond.Operator)
-	}
-
-	// Construir lista de valores
-	valStrs := make([]string, len(values))
-	for i, v := range values {
-		valStrs[i] = fmt.Sprintf("%v", v)
-	}
-
-	return fmt.Sprintf("%s %s [%s]", cond.Attribute, op, stringJoin(valStrs, ", ")), nil
 }
 
-// FunctionExprBuilder builds string/function expressions (contains, startsWith, etc.).
+// FunctionExprBuilder builds string expressions (contains, startsWith, etc.).
+// Both sides are converted to strings, as done by the native engine.
 type FunctionExprBuilder struct{}
 
-// Build builds a function expression for cond.
+// Build builds a string expression for cond.
 func (h *FunctionExprBuilder) Build(cond policies.PolicyCondition) (string, error) {
+	attr, value, err := operands(cond)
+	if err != nil {
+		return "", err
+	}
+
+	var expr string
 	switch cond.Operator {
-	case policies.OpContains:
-		return fmt.Sprintf("contains(%s, %v)", cond.Attribute, cond.Value), nil
-	case policies.OpNotContains:
-		return fmt.Sprintf("!contains(%s, %v)", cond.Attribute, cond.Value), nil
+	case policies.OpContains, policies.OpNotContains:
+		expr = "contains"
 	case policies.OpStartsWith:
-		return fmt.Sprintf("startsWith(%s, %v)", cond.Attribute, cond.Value), nil
+		expr = "startsWith"
 	case policies.OpEndsWith:
-		return fmt.Sprintf("endsWith(%s, %v)", cond.Attribute, cond.Value), nil
+		expr = "endsWith"
 	case policies.OpMatches:
-		return fmt.Sprintf("matches(%s, %v)", cond.Attribute, cond.Value), nil
+		expr = "matches"
 	default:
 		return "", fmt.Errorf("unsupported function operator: %s", cond.Operator)
 	}
+
+	expr = fmt.Sprintf("string(%s) %s string(%s)", attr, expr, value)
+	if cond.Operator == policies.OpNotContains {
+		return fmt.Sprintf("!(%s)", expr), nil
+	}
+	return expr, nil
 }
 
 // TemporalExprBuilder builds temporal comparison expressions (before/after).
@@ -227,5 +320,32 @@ func (h *TemporalExprBuilder) Build(cond policies.PolicyCondition) (string, erro
 		return "", fmt.Errorf("unsupported temporal operator: %s", cond.Operator)
 	}
 
-	return fmt.Sprintf("%s %s %v", cond.Attribute, op, cond.Value), nil
+	attr, value, err := operands(cond)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s($env, %s, %s, %q)", fnCompareTime, attr, value, op), nil
+}
+
+// PresenceExprBuilder builds presence expressions (exists/not_exists). An
+// attribute exists when it was found while building the environment, even
+// with a nil value.
+type PresenceExprBuilder struct{}
+
+// Build builds a presence expression for cond. The condition value is
+// ignored.
+func (h *PresenceExprBuilder) Build(cond policies.PolicyCondition) (string, error) {
+	missing, err := isMissing(cond.Attribute)
+	if err != nil {
+		return "", err
+	}
+
+	switch cond.Operator {
+	case policies.OpExists:
+		return "!" + missing, nil
+	case policies.OpNotExists:
+		return missing, nil
+	default:
+		return "", fmt.Errorf("unsupported presence operator: %s", cond.Operator)
+	}
 }
@@ -0,0 +1,102 @@
+package expr_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/expr"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestExprBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name string
+		cond policies.PolicyCondition
+		out  string
+		err  string
+	}{
+		{
+			name: "when value is a string should quote it",
+			cond: policies.PolicyCondition{Attribute: "user.role", Operator: policies.OpEqual, Value: `admin" || true || "`},
+			out:  `_eq(user.role, "admin\" || true || \"")`,
+		},
+		{
+			name: "when value is a reference should emit its path",
+			cond: policies.PolicyCondition{Attribute: "doc.owner", Operator: policies.OpEqual, Value: policies.Ref("user.id")},
+			out:  `_eq(doc.owner, user.id)`,
+		},
+		{
+			name: "when between has bounds should emit a range",
+			cond: policies.PolicyCondition{Attribute: "n", Operator: policies.OpBetween, Value: []any{1, 5, false}},
+			out:  `_between($env, n, 1, 5, false)`,
+		},
+		{
+			name: "when value is a json number should keep it decimal",
+			cond: policies.PolicyCondition{Attribute: "amount", Operator: policies.OpLessOrEqual, Value: json.Number("100.10")},
+			out:  `_compare($env, amount, _decimal("100.10"), "<=")`,
+		},
+		{
+			name: "when value is a rational should keep it exact",
+			cond: policies.PolicyCondition{Attribute: "amount", Operator: policies.OpEqual, Value: big.NewRat(11, 2)},
+			out:  `_eq(amount, _rational("11/2"))`,
+		},
+		{
+			name: "when condition has an expression should compute it",
+			cond: policies.PolicyCondition{
+				Expression: policies.Arith(policies.ArithMul, policies.Ref("request.amount"), policies.Ref("request.quantity")),
+				Operator:   policies.OpLessOrEqual,
+				Value:      policies.Arith(policies.ArithMax, policies.Ref("subject.limit"), json.Number("10.5")),
+			},
+			out: `_compare($env, _arith($env, "mul", request.amount, request.quantity), _arith($env, "max", subject.limit, _decimal("10.5")), "<=")`,
+		},
+		{
+			name: "when mod has a remainder should emit it",
+			cond: policies.PolicyCondition{Attribute: "n", Operator: policies.OpMod, Value: []any{5, 2}},
+			out:  `_remainder($env, n, 5, 2)`,
+		},
+		{
+			name: "when expression is invalid should fail",
+			cond: policies.PolicyCondition{Expression: policies.Arith("pow", policies.Ref("n"), 2), Operator: policies.OpEqual, Value: 4},
+			err:  `unknown arithmetic operation: "pow"`,
+		},
+		{
+			name: "when subset should test every element",
+			cond: policies.PolicyCondition{Attribute: "roles", Operator: policies.OpSubset, Value: []string{"a", "b"}},
+			out:  `all(roles, _in(#, ["a","b"]))`,
+		},
+		{
+			name: "when not wraps a comparison should keep precedence",
+			cond: policies.PolicyCondition{Operator: policies.OpNot, Conditions: []policies.PolicyCondition{{Attribute: "n", Operator: policies.OpEqual, Value: 1}}},
+			out:  `!(_eq(n, 1))`,
+		},
+		{
+			name: "when attribute is not an identifier should fail",
+			cond: policies.PolicyCondition{Attribute: "n == 1 || x", Operator: policies.OpEqual, Value: 1},
+			err:  `invalid attribute path: "n == 1 || x"`,
+		},
+		{
+			name: "when attribute is a reserved word should fail",
+			cond: policies.PolicyCondition{Attribute: "not", Operator: policies.OpEqual, Value: 1},
+			err:  `invalid attribute path: "not" is reserved`,
+		},
+		{
+			name: "when reference is not an identifier should fail",
+			cond: policies.PolicyCondition{Attribute: "n", Operator: policies.OpIn, Value: []any{policies.Ref("a b")}},
+			err:  `invalid attribute path: "a b"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := expr.NewExprBuilder().Build(tt.cond)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package expr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultCacheSize is the number of compiled programs kept by an engine
+// unless WithCacheSize is used.
+const DefaultCacheSize = 1024
+
+// CacheStats reports the activity of the compiled-program cache of an engine.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// programCache is a bounded LRU cache of programs keyed by source. A
+// size of zero or less disables caching.
+type programCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	stats   CacheStats
+}
+
+func newProgramCache(size int) *programCache {
+	return &programCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *programCache) get(source string) (*Program, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[source]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*Program), true
+}
+
+func (c *programCache) add(p *Program) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[p.source]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[p.source] = c.order.PushFront(p)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*Program).source)
+		c.stats.Evictions++
+	}
+}
+
+func (c *programCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
@@ -1,42 +1,153 @@
 package expr
 
 import (
+	"context"
 	"fmt"
 
 	exprlang "github.com/expr-lang/expr"
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
 )
 
+// Engine is a policies.ContextEngine compiling conditions into cached
+// programs.
+type Engine interface {
+	policies.ContextEngine
+
+	// Compile validates and compiles cond, returning the cached program when
+	// a condition building the same expression was compiled before.
+	Compile(cond policies.PolicyCondition) (*Program, error)
+
+	// Stats returns the compiled-program cache statistics.
+	Stats() CacheStats
+}
+
 type engine struct {
-	builder ExprBuilder
+	builder   ExprBuilder
+	functions []exprlang.Option
+	env       any
+	cacheSize int
+	cache     *programCache
+	missing   policies.MissingAttributes
+	coercion  utils.Coercion
+}
+
+// Option configures the engine built by NewEngine.
+type Option func(*engine)
+
+// WithBuilder sets the ExprBuilder used to translate conditions, for example
+// one built with WithOperatorBuilder for custom operators.
+func WithBuilder(b ExprBuilder) Option {
+	return func(e *engine) {
+		e.builder = b
+	}
+}
+
+// WithFunction makes fn callable as name from built expressions, so that
+// custom operator builders can delegate to Go code.
+func WithFunction(name string, fn func(params ...any) (any, error)) Option {
+	return func(e *engine) {
+		e.functions = append(e.functions, exprlang.Function(name, fn))
+	}
+}
+
+// WithCacheSize sets the number of compiled programs kept by the engine. A
+// size of zero or less disables the cache.
+func WithCacheSize(n int) Option {
+	return func(e *engine) {
+		e.cacheSize = n
+	}
 }
 
-func NewEngine() policies.Engine {
-	return &engine{
-		builder: NewExprBuilder(),
+// WithEnv declares the environment expressions are type checked against, as
+// a map or struct value shaped like the resolved attributes. Without it
+// expressions are compiled untyped and checked when they run.
+func WithEnv(env any) Option {
+	return func(e *engine) {
+		e.env = env
 	}
 }
 
+// WithCoercion sets how values of different types compare. It defaults to
+// utils.CoerceNumbers; utils.CoerceNone requires exact typing.
+func WithCoercion(c utils.Coercion) Option {
+	return func(e *engine) {
+		e.coercion = c
+	}
+}
+
+func NewEngine(opts ...Option) Engine {
+	e := &engine{
+		builder:   NewExprBuilder(),
+		cacheSize: DefaultCacheSize,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.cache = newProgramCache(e.cacheSize)
+	return e
+}
+
 func (e *engine) Eval(cond policies.PolicyCondition, ctx policies.Resolver) (bool, error) {
-	if err := cond.Validate(); err != nil {
-		return false, fmt.Errorf("invalid condition: %w", err)
+	return e.EvalContext(context.Background(), cond, ctx)
+}
+
+// EvalContext evaluates cond, checking ctx before compiling and before running
+// the expression. A running expression cannot be interrupted.
+func (e *engine) EvalContext(ctx context.Context, cond policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
 	}
 
-	exprStr, err := e.builder.Build(cond)
+	program, err := e.Compile(cond)
 	if err != nil {
-		return false, fmt.Errorf("failed to build expression: %w", err)
+		return false, err
 	}
+	return program.Eval(ctx, attr)
+}
 
-	program, err := exprlang.Compile(exprStr, exprlang.Env(ctx), exprlang.AsBool())
+func (e *engine) Compile(cond policies.PolicyCondition) (*Program, error) {
+	if err := cond.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid condition: %w", err)
+	}
+
+	// Programs are cached by source rather than by fingerprint: values of
+	// different types with the same JSON encoding, such as 5 and 5.0, build
+	// different literals and may compare differently.
+	source, err := build(&guardedBuilder{builder: e.builder}, cond)
 	if err != nil {
-		return false, fmt.Errorf("failed compile expression: %w", err)
+		return nil, err
+	}
+	if program, ok := e.cache.get(source); ok {
+		return program, nil
 	}
 
-	res, err := exprlang.Run(program, ctx)
+	fingerprint, err := cond.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	program, err := compile(source, cond, fingerprint, e.options())
 	if err != nil {
-		return false, fmt.Errorf("failed run expression: %w", err)
+		return nil, err
 	}
+	e.cache.add(program)
+	return program, nil
+}
+
+func (e *engine) Stats() CacheStats {
+	return e.cache.snapshot()
+}
 
-	matches, _ := res.(bool)
-	return matches, nil
+func (e *engine) options() []exprlang.Option {
+	// Expressions evaluate to a bool or to nil when unknown, so their type
+	// is checked by Program.Eval rather than with exprlang.AsBool, which
+	// would turn nil into false.
+	var options []exprlang.Option
+	if e.env != nil {
+		options = append(options, exprlang.Env(e.env))
+	}
+	options = append(options, helperFunctions...)
+	options = append(options, missingFunction(e.missing))
+	options = append(options, comparisonFunctions(e.coercion)...)
+	return append(options, e.functions...)
 }
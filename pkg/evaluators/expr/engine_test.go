@@ -0,0 +1,209 @@
+package expr_test
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/expr"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies/policiestest"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+type cidrBuilder struct{}
+
+func (cidrBuilder) Build(cond policies.PolicyCondition) (string, error) {
+	lit, err := expr.Literal(cond.Value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("inCIDR(%s, %s)", cond.Attribute, lit), nil
+}
+
+func inCIDR(params ...any) (any, error) {
+	_, network, err := net.ParseCIDR(params[1].(string))
+	if err != nil {
+		return nil, err
+	}
+	return network.Contains(net.ParseIP(params[0].(string))), nil
+}
+
+func TestEngine_CustomOperator(t *testing.T) {
+	const opInCIDR policies.Operator = "test_in_cidr"
+	policiestest.MustRegisterOperator(t, opInCIDR, policies.OperatorSpec{Kind: policies.KindCustom, MinArgs: 2, MaxArgs: 2})
+	eng := expr.NewEngine(
+		expr.WithBuilder(expr.NewExprBuilder(expr.WithOperatorBuilder(opInCIDR, cidrBuilder{}))),
+		expr.WithFunction("inCIDR", inCIDR),
+	)
+
+	cond := policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+		{Attribute: "ip", Operator: opInCIDR, Value: "10.0.0.0/8"},
+		{Operator: policies.OpNot, Conditions: []policies.PolicyCondition{
+			{Attribute: "ip", Operator: opInCIDR, Value: "10.9.0.0/16"},
+		}},
+	}}
+
+	ok, err := eng.Eval(cond, policies.MapAttributes{"ip": "10.1.2.3"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = eng.Eval(cond, policies.MapAttributes{"ip": "10.9.2.3"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = expr.NewEngine().Eval(cond, policies.MapAttributes{"ip": "10.1.2.3"})
+	assert.ErrorContains(t, err, "no builder for operator test_in_cidr")
+}
+
+func TestEngine_AttributeRefs(t *testing.T) {
+	attr := policies.MapAttributes{
+		"subject":  map[string]any{"id": "u1", "groups": []any{"a", "b"}},
+		"resource": map[string]any{"owner": "u1", "group": "b"},
+		"request":  map[string]any{"amount": 50},
+		"account":  map[string]any{"limit": 100},
+	}
+
+	tests := []struct {
+		name string
+		cond policies.PolicyCondition
+		res  bool
+	}{
+		{name: "comparison", cond: policies.PolicyCondition{Attribute: "resource.owner", Operator: policies.OpEqual, Value: policies.Ref("subject.id")}, res: true},
+		{name: "ordering", cond: policies.PolicyCondition{Attribute: "request.amount", Operator: policies.OpGreater, Value: map[string]any{"ref": "account.limit"}}, res: false},
+		{name: "set", cond: policies.PolicyCondition{Attribute: "resource.group", Operator: policies.OpIn, Value: policies.Ref("subject.groups")}, res: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := expr.NewEngine().Eval(tt.cond, attr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.res, res)
+		})
+	}
+}
+
+// resolverFunc adapts a function to policies.Resolver.
+type resolverFunc func(attribute string) (any, bool)
+
+func (f resolverFunc) Resolve(attribute string) (any, bool) {
+	return f(attribute)
+}
+
+func TestEngine_Cache(t *testing.T) {
+	eng := expr.NewEngine(expr.WithCacheSize(2))
+	cond := func(v int) policies.PolicyCondition {
+		return policies.PolicyCondition{Attribute: "user.age", Operator: policies.OpGreater, Value: v}
+	}
+	attr := policies.MapAttributes{"user": map[string]any{"age": 30}}
+
+	for _, v := range []int{18, 18, 40, 18, 50, 40} {
+		_, err := eng.Eval(cond(v), attr)
+		assert.NoError(t, err)
+	}
+
+	// 18 miss, 18 hit, 40 miss, 18 hit, 50 miss evicting 40, 40 miss evicting 18.
+	assert.Equal(t, expr.CacheStats{Hits: 2, Misses: 4, Evictions: 2, Size: 2}, eng.Stats())
+
+	p1, err := eng.Compile(cond(50))
+	assert.NoError(t, err)
+	p2, err := eng.Compile(cond(50))
+	assert.NoError(t, err)
+	assert.Same(t, p1, p2)
+	assert.Equal(t, `_isMissing($env, "user.age") ? _missing($env, "user.age") : (_compare($env, user.age, 50, ">"))`, p1.Source())
+
+	fp, _ := cond(50).Fingerprint()
+	assert.Equal(t, fp, p1.Fingerprint())
+}
+
+func TestEngine_CacheTellsTypesApart(t *testing.T) {
+	eng := expr.NewEngine(expr.WithCoercion(utils.CoerceNone))
+	attr := policies.MapAttributes{"a": 5}
+
+	res, err := eng.Eval(policies.PolicyCondition{Attribute: "a", Operator: policies.OpEqual, Value: 5}, attr)
+	assert.NoError(t, err)
+	assert.True(t, res)
+
+	res, err = eng.Eval(policies.PolicyCondition{Attribute: "a", Operator: policies.OpEqual, Value: 5.0}, attr)
+	assert.NoError(t, err)
+	assert.False(t, res)
+	assert.Equal(t, 2, eng.Stats().Size)
+}
+
+func TestEngine_CacheDisabled(t *testing.T) {
+	eng := expr.NewEngine(expr.WithCacheSize(0))
+	cond := policies.PolicyCondition{Attribute: "role", Operator: policies.OpEqual, Value: "admin"}
+
+	p1, err := eng.Compile(cond)
+	assert.NoError(t, err)
+	p2, err := eng.Compile(cond)
+	assert.NoError(t, err)
+	assert.NotSame(t, p1, p2)
+	assert.Equal(t, 0, eng.Stats().Size)
+}
+
+func TestEngine_ProgramIsIndependentOfResolver(t *testing.T) {
+	eng := expr.NewEngine()
+	cond := policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+		{Attribute: "user.role", Operator: policies.OpEqual, Value: "admin"},
+		{Attribute: "user.level", Operator: policies.OpGreaterOrEqual, Value: policies.Ref("doc.level")},
+	}}
+
+	ok, err := eng.Eval(cond, policies.MapAttributes{
+		"user": map[string]any{"role": "admin", "level": 3},
+		"doc":  map[string]any{"level": 2},
+	})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// A resolver that is not a map only receives the paths used by cond.
+	var resolved []string
+	ok, err = eng.Eval(cond, resolverFunc(func(attribute string) (any, bool) {
+		resolved = append(resolved, attribute)
+		switch attribute {
+		case "user.role":
+			return "admin", true
+		case "user.level":
+			return 1, true
+		case "doc.level":
+			return 2, true
+		}
+		return nil, false
+	}))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.ElementsMatch(t, []string{"user.role", "user.level", "doc.level"}, resolved)
+	assert.Equal(t, uint64(1), eng.Stats().Hits)
+}
+
+func TestEngine_WithEnv(t *testing.T) {
+	eng := expr.NewEngine(expr.WithEnv(map[string]any{"age": 0}))
+
+	_, err := eng.Eval(policies.PolicyCondition{Attribute: "height", Operator: policies.OpGreater, Value: 1}, policies.MapAttributes{"height": 1})
+	assert.ErrorContains(t, err, "failed compile expression")
+
+	ok, err := eng.Eval(policies.PolicyCondition{Attribute: "age", Operator: policies.OpGreater, Value: 18}, policies.MapAttributes{"age": 30})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestEngine_Concurrent(t *testing.T) {
+	eng := expr.NewEngine()
+	cond := policies.PolicyCondition{Attribute: "n", Operator: policies.OpMod, Value: 2}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			ok, err := eng.Eval(cond, policies.MapAttributes{"n": n})
+			assert.NoError(t, err)
+			assert.Equal(t, n%2 == 0, ok)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, eng.Stats().Size)
+}
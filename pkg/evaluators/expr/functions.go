@@ -0,0 +1,205 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	exprlang "github.com/expr-lang/expr"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+// Helper functions referenced by the built-in builders. They mirror the
+// coercions of the native engine where expr-lang has no equivalent and are
+// made available to every expression compiled by the engine.
+//
+// Like the native engine, which leaves a logical condition undecided by the
+// errors of its conditions rather than failing, helpers taking $env do not
+// fail the run: they record the error in the missingState of the environment
+// and return nil, the unknown result, which _and, _or and _not combine.
+const (
+	fnCompareTime = "_compareTime"
+	fnDecimal     = "_decimal"
+	fnRational    = "_rational"
+	fnRemainder   = "_remainder"
+	fnArith       = "_arith"
+	fnIsMissing   = "_isMissing"
+	fnMissing     = "_missing"
+	fnAnd         = "_and"
+	fnOr          = "_or"
+	fnNot         = "_not"
+	fnEqual       = "_eq"
+	fnCompare     = "_compare"
+	fnBetween     = "_between"
+	fnIn          = "_in"
+)
+
+// unknownValue is the result of an arithmetic operation that failed. The
+// helpers given one return nil without recording the failure again.
+type unknownValue struct{}
+
+// unknownArg reports whether one of params is an unknownValue.
+func unknownArg(params ...any) bool {
+	for _, p := range params {
+		if _, ok := p.(unknownValue); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ordered reports whether c, the result of a comparison, satisfies op.
+func ordered(op string, c int) (bool, error) {
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	default:
+		return false, fmt.Errorf("unsupported comparison: %q", op)
+	}
+}
+
+var helperFunctions = []exprlang.Option{
+	exprlang.Function(fnCompareTime, func(params ...any) (any, error) {
+		if unknownArg(params[1], params[2]) {
+			return nil, nil
+		}
+		a, err := utils.AnyToTime(params[1])
+		if err != nil {
+			return missingOf(params[0]).unknown(err)
+		}
+		b, err := utils.AnyToTime(params[2])
+		if err != nil {
+			return missingOf(params[0]).unknown(err)
+		}
+		op, _ := params[3].(string)
+		return ordered(op, a.Compare(b))
+	}, new(func(any, any, any, string) any)),
+	exprlang.Function(fnDecimal, func(params ...any) (any, error) {
+		s, _ := params[0].(string)
+		return json.Number(s), nil
+	}, new(func(string) any)),
+	exprlang.Function(fnRational, func(params ...any) (any, error) {
+		s, _ := params[0].(string)
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid rational: %q", s)
+		}
+		return r, nil
+	}, new(func(string) any)),
+	exprlang.Function(fnRemainder, func(params ...any) (any, error) {
+		if unknownArg(params[1:]...) {
+			return nil, nil
+		}
+		ok, err := utils.HasRemainder(params[1], params[2], params[3])
+		if err != nil {
+			return missingOf(params[0]).unknown(err)
+		}
+		return ok, nil
+	}, new(func(any, any, any, any) any)),
+	exprlang.Function(fnArith, func(params ...any) (any, error) {
+		if unknownArg(params[2:]...) {
+			return unknownValue{}, nil
+		}
+		op, _ := params[1].(string)
+		v, err := policies.ArithmeticOp(op).Apply(params[2:]...)
+		if err != nil {
+			_, _ = missingOf(params[0]).unknown(err)
+			return unknownValue{}, nil
+		}
+		return v, nil
+	}),
+	exprlang.Function(fnIsMissing, func(params ...any) (any, error) {
+		path, _ := params[1].(string)
+		return missingOf(params[0]).paths[path], nil
+	}, new(func(any, string) bool)),
+
+	// Logical operators over true, false and nil, the unknown result.
+	exprlang.Function(fnAnd, func(params ...any) (any, error) {
+		return kleene("and", params[0], params[1], false)
+	}, new(func(any, any) any)),
+	exprlang.Function(fnOr, func(params ...any) (any, error) {
+		return kleene("or", params[0], params[1], true)
+	}, new(func(any, any) any)),
+	exprlang.Function(fnNot, func(params ...any) (any, error) {
+		if params[0] == nil {
+			return nil, nil
+		}
+		b, ok := params[0].(bool)
+		if !ok {
+			return nil, fmt.Errorf("not: expected bool, got %T", params[0])
+		}
+		return !b, nil
+	}, new(func(any) any)),
+}
+
+// kleene combines a and b, each true, false or nil: decisive wins, then nil,
+// then !decisive.
+func kleene(op string, a, b any, decisive bool) (any, error) {
+	unknown := false
+	for _, v := range []any{a, b} {
+		if v == nil {
+			unknown = true
+			continue
+		}
+		bv, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected bool, got %T", op, v)
+		}
+		if bv == decisive {
+			return decisive, nil
+		}
+	}
+	if unknown {
+		return nil, nil
+	}
+	return !decisive, nil
+}
+
+// comparisonFunctions returns the helper functions comparing values under c,
+// as the native engine does with utils.Equal, utils.Compare, utils.Between
+// and utils.Set.
+func comparisonFunctions(c utils.Coercion) []exprlang.Option {
+	return []exprlang.Option{
+		exprlang.Function(fnEqual, func(params ...any) (any, error) {
+			if unknownArg(params...) {
+				return nil, nil
+			}
+			return utils.Equal(params[0], params[1], c), nil
+		}, new(func(any, any) any)),
+		exprlang.Function(fnCompare, func(params ...any) (any, error) {
+			if unknownArg(params[1], params[2]) {
+				return nil, nil
+			}
+			n, err := utils.Compare(params[1], params[2], c)
+			if err != nil {
+				return missingOf(params[0]).unknown(err)
+			}
+			op, _ := params[3].(string)
+			return ordered(op, n)
+		}, new(func(any, any, any, string) any)),
+		exprlang.Function(fnBetween, func(params ...any) (any, error) {
+			if unknownArg(params[1:4]...) {
+				return nil, nil
+			}
+			inclusive, _ := params[4].(bool)
+			ok, err := utils.Between(params[1], params[2], params[3], inclusive, c)
+			if err != nil {
+				return missingOf(params[0]).unknown(err)
+			}
+			return ok, nil
+		}, new(func(any, any, any, any, bool) any)),
+		exprlang.Function(fnIn, func(params ...any) (any, error) {
+			if unknownArg(params[0]) {
+				return nil, nil
+			}
+			return utils.NewSet(params[1], true).Contains(params[0], c), nil
+		}, new(func(any, any) any)),
+	}
+}
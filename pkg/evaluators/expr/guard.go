@@ -0,0 +1,118 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// guardedBuilder translates conditions for the engine under three-valued
+// logic (see package policies). The expression of a condition on an
+// attribute, or on the attributes of its Expression, is guarded so that it
+// follows the policies.MissingAttributes of the engine when an attribute, or
+// one its value refers to, is missing, evaluating to nil when unknown. The
+// helper functions evaluate to nil too when they fail, and logical operators
+// combine true, false and nil. and and or still short-circuit on false and
+// true respectively.
+type guardedBuilder struct {
+	builder ExprBuilder
+	// vars numbers the variables bound by logical expressions, which expr
+	// does not allow to redeclare.
+	vars int
+}
+
+func (g *guardedBuilder) Build(cond policies.PolicyCondition) (string, error) {
+	spec, _ := policies.OperatorSpecOf(cond.Operator)
+	switch spec.Kind {
+	case policies.KindLogical:
+		return g.logical(cond)
+	case policies.KindPresence:
+		return g.builder.Build(cond)
+	}
+
+	expr, err := g.builder.Build(cond)
+	if err != nil {
+		return "", err
+	}
+	// Attributes are guarded in the order the native engine resolves them:
+	// those of the expression, then the references of the value, then the
+	// attribute.
+	refs := policies.RefPaths(cond.Value)
+	attrs := append(refs, cond.Attribute)
+	if cond.Expression != nil {
+		attrs = append(cond.Expression.Paths(), refs...)
+	}
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i] == "" {
+			continue
+		}
+		missing, err := isMissing(attrs[i])
+		if err != nil {
+			return "", err
+		}
+		path, err := Literal(attrs[i])
+		if err != nil {
+			return "", err
+		}
+		expr = fmt.Sprintf("%s ? %s($env, %s) : (%s)", missing, fnMissing, path, expr)
+	}
+	return expr, nil
+}
+
+func (g *guardedBuilder) logical(cond policies.PolicyCondition) (string, error) {
+	if len(cond.Conditions) == 0 {
+		return "", fmt.Errorf("logical operator %s requires conditions", cond.Operator)
+	}
+
+	parts := make([]string, len(cond.Conditions))
+	for i, c := range cond.Conditions {
+		expr, err := g.Build(c)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = expr
+	}
+
+	switch cond.Operator {
+	case policies.OpAnd:
+		return g.fold(parts, fnAnd, "false"), nil
+	case policies.OpOr:
+		return g.fold(parts, fnOr, "true"), nil
+	case policies.OpNot:
+		if len(parts) != 1 {
+			return "", fmt.Errorf("operator 'not' requires exactly one condition")
+		}
+		return fmt.Sprintf("%s(%s)", fnNot, parts[0]), nil
+	default:
+		return "", fmt.Errorf("unsupported logical operator: %s", cond.Operator)
+	}
+}
+
+// fold combines parts with fn from the right, returning decisive as soon as
+// a part evaluates to it:
+//
+//	let _v1 = (a); _v1 == false ? false : _and(_v1, (b))
+func (g *guardedBuilder) fold(parts []string, fn, decisive string) string {
+	last := len(parts) - 1
+	expr := "(" + parts[last] + ")"
+	var sb strings.Builder
+	for _, part := range parts[:last] {
+		g.vars++
+		v := fmt.Sprintf("_v%d", g.vars)
+		fmt.Fprintf(&sb, "let %s = (%s); %s == %s ? %s : %s(%s, ", v, part, v, decisive, decisive, fn, v)
+	}
+	return sb.String() + expr + strings.Repeat(")", last)
+}
+
+// isMissing returns the expression reporting whether path was not found.
+func isMissing(path string) (string, error) {
+	if _, err := attributePath(path); err != nil {
+		return "", err
+	}
+	lit, err := Literal(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s($env, %s)", fnIsMissing, lit), nil
+}
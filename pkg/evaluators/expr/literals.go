@@ -3,14 +3,203 @@ package expr
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
 )
 
 // Literal returns a JSON literal representation of v suitable for embedding
-// in an expression built by the expr engine.
+// in an expression built by the expr engine. Floats always hold a decimal
+// point or an exponent, so that expr does not read whole floats as ints, and
+// exact numbers, a json.Number or a *big.Rat, are passed as is to compare
+// exactly, as the native engine does.
 func Literal(v any) (string, error) {
+	switch n := v.(type) {
+	case json.Number:
+		if _, err := json.Marshal(n); err != nil {
+			return "", fmt.Errorf("invalid literal: %w", err)
+		}
+		return fmt.Sprintf("%s(%q)", fnDecimal, string(n)), nil
+	case *big.Rat:
+		if n != nil {
+			return fmt.Sprintf("%s(%q)", fnRational, n.RatString()), nil
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return floatLiteral(rv.Float(), rv.Type().Bits())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 || rv.Kind() == reflect.Slice && rv.IsNil() {
+			break
+		}
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			part, err := Literal(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return "[" + strings.Join(parts, ",") + "]", nil
+	case reflect.Map:
+		if rv.IsNil() {
+			break
+		}
+		parts := make([]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := Literal(fmt.Sprint(iter.Key().Interface()))
+			if err != nil {
+				return "", err
+			}
+			val, err := Literal(iter.Value().Interface())
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, key+":"+val)
+		}
+		sort.Strings(parts)
+		return "{" + strings.Join(parts, ",") + "}", nil
+	}
+
 	b, err := json.Marshal(v)
 	if err != nil {
 		return "", fmt.Errorf("invalid literal: %w", err)
 	}
 	return string(b), nil
 }
+
+func floatLiteral(f float64, bits int) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("invalid literal: unsupported value: %v", f)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s, nil
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedWords cannot start an attribute path since the expr lexer reads
+// them as operators or literals.
+var reservedWords = map[string]bool{
+	"not": true, "in": true, "or": true, "and": true, "let": true,
+	"matches": true, "contains": true, "startsWith": true, "endsWith": true,
+	"if": true, "else": true, "true": true, "false": true, "nil": true,
+}
+
+// attributePath validates that path is a dotted sequence of identifiers, so
+// that it cannot inject anything but a variable lookup into an expression.
+func attributePath(path string) (string, error) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts {
+		if !identifierPattern.MatchString(part) {
+			return "", fmt.Errorf("invalid attribute path: %q", path)
+		}
+	}
+	if reservedWords[parts[0]] {
+		return "", fmt.Errorf("invalid attribute path: %q is reserved", path)
+	}
+	return path, nil
+}
+
+// operand returns the expression fragment for a condition value: the
+// validated path for an attribute reference, a call for an Arithmetic
+// expression and a Literal otherwise. Slices and maps containing references
+// are emitted element by element.
+func operand(v any) (string, error) {
+	if path, ok := policies.RefOf(v); ok {
+		return attributePath(path)
+	}
+	if a, ok := policies.ArithmeticOf(v); ok {
+		return arithmetic(a)
+	}
+	if !policies.HasRefs(v) {
+		return Literal(v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			part, err := operand(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case reflect.Map:
+		parts := make([]string, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := Literal(fmt.Sprint(iter.Key().Interface()))
+			if err != nil {
+				return "", err
+			}
+			val, err := operand(iter.Value().Interface())
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, key+": "+val)
+		}
+		sort.Strings(parts)
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	default:
+		return Literal(v)
+	}
+}
+
+// arithmetic returns the call computing a:
+//
+//	_arith($env, "mul", request.amount, request.quantity)
+func arithmetic(a *policies.Arithmetic) (string, error) {
+	if err := a.Validate(); err != nil {
+		return "", err
+	}
+	parts := make([]string, len(a.Args)+2)
+	parts[0] = "$env"
+	parts[1] = strconv.Quote(string(a.Op))
+	for i, arg := range a.Args {
+		part, err := operand(arg)
+		if err != nil {
+			return "", err
+		}
+		parts[i+2] = part
+	}
+	return fmt.Sprintf("%s(%s)", fnArith, strings.Join(parts, ", ")), nil
+}
+
+// subject returns the expression fragment for the attribute of cond, or for
+// its Expression.
+func subject(cond policies.PolicyCondition) (string, error) {
+	if cond.Expression != nil {
+		return arithmetic(cond.Expression)
+	}
+	return attributePath(cond.Attribute)
+}
+
+// operands returns the expression fragments for the attribute, or the
+// expression, and the value of cond.
+func operands(cond policies.PolicyCondition) (string, string, error) {
+	attr, err := subject(cond)
+	if err != nil {
+		return "", "", err
+	}
+	value, err := operand(cond.Value)
+	if err != nil {
+		return "", "", err
+	}
+	return attr, value, nil
+}
@@ -0,0 +1,56 @@
+package expr
+
+import (
+	exprlang "github.com/expr-lang/expr"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// WithMissingAttributes sets how conditions on missing attributes evaluate.
+// It defaults to policies.MissingError.
+func WithMissingAttributes(m policies.MissingAttributes) Option {
+	return func(e *engine) {
+		e.missing = m
+	}
+}
+
+// envMissing is the key of the environment of a program holding its
+// missingState. It cannot collide with an attribute path.
+const envMissing = "$missing"
+
+// missingState records the attributes missing from the environment of a
+// program run and the errors of the conditions left unknown, by missing
+// attributes or by the failures of helper functions.
+type missingState struct {
+	paths  map[string]bool
+	causes []error
+}
+
+// unknown records err as the cause of a condition left unknown and returns
+// nil, the unknown result.
+func (s *missingState) unknown(err error) (any, error) {
+	s.causes = append(s.causes, err)
+	return nil, nil
+}
+
+// missingOf returns the missingState of env.
+func missingOf(env any) *missingState {
+	m, _ := env.(map[string]any)
+	state, _ := m[envMissing].(*missingState)
+	if state == nil {
+		return &missingState{}
+	}
+	return state
+}
+
+// missingFunction returns the function called by guarded expressions when
+// their attribute is missing. Conditions that do not evaluate to false are
+// unknown: their error is recorded and they return nil.
+func missingFunction(m policies.MissingAttributes) exprlang.Option {
+	return exprlang.Function(fnMissing, func(params ...any) (any, error) {
+		path, _ := params[1].(string)
+		if _, err := m.Missing(path); err != nil {
+			return missingOf(params[0]).unknown(err)
+		}
+		return false, nil
+	}, new(func(any, string) any))
+}
@@ -0,0 +1,165 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	exprlang "github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// Program is a PolicyCondition compiled into an expr-lang program. It is
+// immutable and safe for concurrent use.
+type Program struct {
+	fingerprint string
+	source      string
+	program     *vm.Program
+	// paths are resolved into the environment of the program, shortest
+	// first.
+	paths []string
+}
+
+// Fingerprint returns the fingerprint of the condition the program was
+// compiled from.
+func (p *Program) Fingerprint() string {
+	return p.fingerprint
+}
+
+// Source returns the expression the program was compiled from.
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Eval runs the program against the attributes resolved by attr. Only the
+// attributes and references used by the condition are resolved; a condition
+// on a missing attribute, or referring to one, follows the
+// policies.MissingAttributes of the engine.
+func (p *Program) Eval(ctx context.Context, attr policies.Resolver) (bool, error) {
+	env, err := p.env(ctx, attr)
+	if err != nil {
+		return false, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	res, err := exprlang.Run(p.program, env)
+	if err != nil {
+		return false, fmt.Errorf("failed run expression: %w", err)
+	}
+	switch res := res.(type) {
+	case bool:
+		return res, nil
+	case nil:
+		return false, policies.Unknown(missingOf(env).causes...)
+	default:
+		return false, fmt.Errorf("failed run expression: expected bool, got %T", res)
+	}
+}
+
+// env builds the expression environment from the resolved paths. Paths are
+// inserted shortest first and children of an inserted path are skipped, so
+// resolved values are never mutated. Missing attributes are recorded in the
+// missingState of the environment.
+func (p *Program) env(ctx context.Context, attr policies.Resolver) (map[string]any, error) {
+	missing := &missingState{paths: make(map[string]bool)}
+	env := map[string]any{envMissing: missing}
+	inserted := make(map[string]bool)
+	for _, path := range p.paths {
+		v, ok, err := policies.ResolveContext(ctx, attr, path)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			missing.paths[path] = true
+			continue
+		}
+		insertPath(env, inserted, path, v)
+	}
+	return env, nil
+}
+
+func insertPath(env map[string]any, inserted map[string]bool, path string, v any) {
+	parts := strings.Split(path, ".")
+	current := env
+	for i, part := range parts[:len(parts)-1] {
+		if inserted[strings.Join(parts[:i+1], ".")] {
+			return
+		}
+		child, ok := current[part].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			current[part] = child
+		}
+		current = child
+	}
+	if !inserted[path] {
+		current[parts[len(parts)-1]] = v
+		inserted[path] = true
+	}
+}
+
+// build translates cond with builder.
+func build(builder ExprBuilder, cond policies.PolicyCondition) (string, error) {
+	source, err := builder.Build(cond)
+	if err != nil {
+		return "", fmt.Errorf("failed to build expression: %w", err)
+	}
+	return source, nil
+}
+
+// compile compiles the source built for cond with options.
+func compile(source string, cond policies.PolicyCondition, fingerprint string, options []exprlang.Option) (*Program, error) {
+	program, err := exprlang.Compile(source, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed compile expression: %w", err)
+	}
+
+	return &Program{
+		fingerprint: fingerprint,
+		source:      source,
+		program:     program,
+		paths:       paths(cond),
+	}, nil
+}
+
+// paths returns the attribute and referenced paths used by cond, sorted by
+// length so parents come before their children.
+func paths(cond policies.PolicyCondition) []string {
+	set := make(map[string]struct{})
+	collectPaths(cond, set)
+
+	out := make([]string, 0, len(set))
+	for path := range set {
+		out = append(out, path)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i]) != len(out[j]) {
+			return len(out[i]) < len(out[j])
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// collectPaths adds the paths used by cond to set.
+func collectPaths(cond policies.PolicyCondition, set map[string]struct{}) {
+	if cond.Attribute != "" {
+		set[cond.Attribute] = struct{}{}
+	}
+	if cond.Expression != nil {
+		for _, path := range cond.Expression.Paths() {
+			set[path] = struct{}{}
+		}
+	}
+	for _, path := range policies.RefPaths(cond.Value) {
+		set[path] = struct{}{}
+	}
+	for _, child := range cond.Conditions {
+		collectPaths(child, set)
+	}
+}
@@ -1,14 +1,16 @@
 package native
 
 import (
+	"context"
 	"fmt"
-	"math"
 
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
 	"github.com/tavaresphil/go-policy-engine/pkg/utils"
 )
 
 // ArithmeticHandler implements arithmetic operators (currently only mod).
+// Arithmetic values are computed by policies.Arithmetic expressions, which
+// may appear on either side of any condition.
 type ArithmeticHandler struct{}
 
 // NewArithmeticHandler constructs an ArithmeticHandler.
@@ -16,38 +18,31 @@ func NewArithmeticHandler() OperatorHandler {
 	return &ArithmeticHandler{}
 }
 
-// Eval implements the modulus check: attribute % value == 0.
-// Supports numeric types and numeric strings via utils.AnyToFloat64.
+// Eval evaluates pc with a background context.
 func (h *ArithmeticHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
-	if pc.Operator != policies.OpMod {
-		return false, fmt.Errorf("unsupported arithmetic operator: %s", pc.Operator)
-	}
-
-	attrVal, ok := attr.Resolve(pc.Attribute)
-	if !ok {
-		return false, fmt.Errorf("missing required attribute: %s", pc.Attribute)
-	}
+	return h.EvalContext(context.Background(), pc, attr)
+}
 
-	divisor := pc.Value
+// EvalContext implements the modulus check: attribute % divisor ==
+// remainder, the value being parsed with policies.ParseModValue. Supports
+// numeric types and numeric strings via utils.HasRemainder, exactly when
+// all are integers or decimals.
+func (h *ArithmeticHandler) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return evalCompiled(ctx, h, pc, attr)
+}
 
-	// parse numbers using helper
-	af, err := utils.AnyToFloat64(attrVal)
-	if err != nil {
-		return false, err
-	}
-	vf, err := utils.AnyToFloat64(divisor)
-	if err != nil {
-		return false, err
+// Compile compiles pc, parsing its value once.
+func (h *ArithmeticHandler) Compile(pc policies.PolicyCondition) (Predicate, error) {
+	if pc.Operator != policies.OpMod {
+		return nil, fmt.Errorf("unsupported arithmetic operator: %s", pc.Operator)
 	}
 
-	if vf == 0 {
-		return false, fmt.Errorf("modulo by zero")
+	divisor, remainder, err := policies.ParseModValue(pc.Value)
+	if err != nil {
+		return attribute(pc.Attribute, failing(err)), nil
 	}
 
-	res := math.Mod(af, vf)
-	// consider floating point precision
-	if math.Abs(res) < 1e-9 {
-		return true, nil
-	}
-	return false, nil
+	return attribute(pc.Attribute, func(_ context.Context, attrVal any) (bool, error) {
+		return utils.HasRemainder(attrVal, divisor, remainder)
+	}), nil
 }
@@ -0,0 +1,301 @@
+package native
+
+import (
+	"container/list"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// DefaultCacheSize is the number of compiled conditions kept by an engine
+// unless WithCacheSize is used.
+const DefaultCacheSize = 1024
+
+// predicateCache is a bounded LRU cache of compiled conditions keyed by
+// conditionKey, or by the key given by the caller (see
+// policies.ContextWithConditionKey). A size of zero or less disables caching.
+type predicateCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[cacheKey]*list.Element
+}
+
+// cacheKey keeps the keys given by callers apart from those computed by
+// conditionKey.
+type cacheKey struct {
+	key    string
+	caller bool
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	eval Predicate
+}
+
+func newPredicateCache(size int) *predicateCache {
+	return &predicateCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[cacheKey]*list.Element),
+	}
+}
+
+func (c *predicateCache) get(key cacheKey) (Predicate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).eval, true
+}
+
+func (c *predicateCache) add(key cacheKey, eval Predicate) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, eval: eval})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// conditionKey returns a key identifying pc with the Go types of its values,
+// so that int 5 and float64 5, which handlers may compare differently under
+// utils.CoerceNone, get distinct keys. The common types of condition values
+// are encoded without reflection into a pooled buffer. It reports false for
+// conditions holding values it cannot encode, such as functions, or nested
+// deeper than maxKeyDepth, as cyclic values are: they are not cached.
+func conditionKey(pc policies.PolicyCondition) (string, bool) {
+	buf := keyBuffers.Get().(*[]byte)
+	b, ok := appendCondition((*buf)[:0], pc, 0)
+	key := string(b)
+	*buf = b
+	keyBuffers.Put(buf)
+	return key, ok
+}
+
+// maxKeyDepth bounds the nesting of the values encoded by conditionKey.
+const maxKeyDepth = 64
+
+var keyBuffers = sync.Pool{New: func() any {
+	b := make([]byte, 0, 256)
+	return &b
+}}
+
+func appendCondition(b []byte, pc policies.PolicyCondition, depth int) ([]byte, bool) {
+	if depth > maxKeyDepth {
+		return b, false
+	}
+	b = append(b, '{')
+	b = strconv.AppendQuote(b, pc.Attribute)
+	b = strconv.AppendQuote(b, string(pc.Operator))
+	var ok bool
+	if pc.Expression != nil {
+		if b, ok = appendArithmetic(append(b, 'x'), pc.Expression, depth+1); !ok {
+			return b, false
+		}
+	}
+	if b, ok = appendValue(append(b, 'v'), pc.Value, depth+1); !ok {
+		return b, false
+	}
+	for _, c := range pc.Conditions {
+		if b, ok = appendCondition(append(b, ','), c, depth+1); !ok {
+			return b, false
+		}
+	}
+	return append(b, '}'), true
+}
+
+func appendArithmetic(b []byte, a *policies.Arithmetic, depth int) ([]byte, bool) {
+	b = append(b, "arith("...)
+	b = strconv.AppendQuote(b, string(a.Op))
+	for _, arg := range a.Args {
+		var ok bool
+		if b, ok = appendValue(append(b, ','), arg, depth+1); !ok {
+			return b, false
+		}
+	}
+	return append(b, ')'), true
+}
+
+func appendValue(b []byte, v any, depth int) ([]byte, bool) {
+	if depth > maxKeyDepth {
+		return b, false
+	}
+
+	switch t := v.(type) {
+	case nil:
+		return append(b, "nil"...), true
+	case string:
+		return strconv.AppendQuote(append(b, "string"...), t), true
+	case bool:
+		return strconv.AppendBool(append(b, "bool"...), t), true
+	case int:
+		return strconv.AppendInt(append(b, "int"...), int64(t), 10), true
+	case int64:
+		return strconv.AppendInt(append(b, "int64"...), t, 10), true
+	case int32:
+		return strconv.AppendInt(append(b, "int32"...), int64(t), 10), true
+	case uint:
+		return strconv.AppendUint(append(b, "uint"...), uint64(t), 10), true
+	case uint64:
+		return strconv.AppendUint(append(b, "uint64"...), t, 10), true
+	case float64:
+		return strconv.AppendFloat(append(b, "float64"...), t, 'g', -1, 64), true
+	case float32:
+		return strconv.AppendFloat(append(b, "float32"...), float64(t), 'g', -1, 32), true
+	case json.Number:
+		return strconv.AppendQuote(append(b, "json.Number"...), string(t)), true
+	case *big.Rat:
+		if t == nil {
+			return append(b, "*big.Rat(nil)"...), true
+		}
+		return append(append(b, "*big.Rat"...), t.RatString()...), true
+	case time.Time:
+		b = t.AppendFormat(append(b, "time.Time"...), time.RFC3339Nano)
+		return append(append(b, ' '), t.Location().String()...), true
+	case policies.AttributeRef:
+		return strconv.AppendQuote(append(b, "ref"...), t.Ref), true
+	case *policies.Arithmetic:
+		if t == nil {
+			return append(b, "*policies.Arithmetic(nil)"...), true
+		}
+		return appendArithmetic(b, t, depth+1)
+	case []any:
+		b = append(b, "[]any["...)
+		for _, e := range t {
+			var ok bool
+			if b, ok = appendValue(b, e, depth+1); !ok {
+				return b, false
+			}
+			b = append(b, ',')
+		}
+		return append(b, ']'), true
+	case []string:
+		b = append(b, "[]string["...)
+		for _, e := range t {
+			b = append(strconv.AppendQuote(b, e), ',')
+		}
+		return append(b, ']'), true
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = append(b, "map[string]any{"...)
+		for _, k := range keys {
+			b = append(strconv.AppendQuote(b, k), ':')
+			var ok bool
+			if b, ok = appendValue(b, t[k], depth+1); !ok {
+				return b, false
+			}
+			b = append(b, ',')
+		}
+		return append(b, '}'), true
+	}
+	return appendReflect(b, reflect.ValueOf(v), depth)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// appendReflect encodes the values of types appendValue does not know.
+func appendReflect(b []byte, v reflect.Value, depth int) ([]byte, bool) {
+	if depth > maxKeyDepth {
+		return b, false
+	}
+	if !v.IsValid() {
+		return append(b, "nil"...), true
+	}
+	if v.Type() == timeType && v.CanInterface() {
+		return appendValue(b, v.Interface(), depth)
+	}
+
+	b = append(b, v.Type().String()...)
+	var ok bool
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.AppendBool(b, v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(b, v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(b, v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.AppendFloat(b, v.Float(), 'g', -1, 64), true
+	case reflect.String:
+		return strconv.AppendQuote(b, v.String()), true
+	case reflect.Interface, reflect.Pointer:
+		b = append(b, '(')
+		if !v.IsNil() {
+			if v.CanInterface() {
+				b, ok = appendValue(b, v.Elem().Interface(), depth+1)
+			} else {
+				b, ok = appendReflect(b, v.Elem(), depth+1)
+			}
+			if !ok {
+				return b, false
+			}
+		}
+		return append(b, ')'), true
+	case reflect.Slice, reflect.Array:
+		b = append(b, '[')
+		for i := 0; i < v.Len(); i++ {
+			if b, ok = appendReflect(b, v.Index(i), depth+1); !ok {
+				return b, false
+			}
+			b = append(b, ',')
+		}
+		return append(b, ']'), true
+	case reflect.Map:
+		pairs := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var pair []byte
+			if pair, ok = appendReflect(pair, iter.Key(), depth+1); !ok {
+				return b, false
+			}
+			pair = append(pair, ':')
+			if pair, ok = appendReflect(pair, iter.Value(), depth+1); !ok {
+				return b, false
+			}
+			pairs = append(pairs, string(pair))
+		}
+		sort.Strings(pairs)
+		b = append(b, '{')
+		for _, pair := range pairs {
+			b = append(append(b, pair...), ',')
+		}
+		return append(b, '}'), true
+	case reflect.Struct:
+		b = append(b, '{')
+		for i := 0; i < v.NumField(); i++ {
+			if b, ok = appendReflect(b, v.Field(i), depth+1); !ok {
+				return b, false
+			}
+			b = append(b, ',')
+		}
+		return append(b, '}'), true
+	default:
+		return b, false
+	}
+}
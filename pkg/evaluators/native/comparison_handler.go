@@ -1,9 +1,8 @@
 package native
 
 import (
+	"context"
 	"fmt"
-	"reflect"
-	"time"
 
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
 	"github.com/tavaresphil/go-policy-engine/pkg/utils"
@@ -16,85 +15,45 @@ func NewComparisonHandler() OperatorHandler {
 }
 
 func (h *ComparisonHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
-	attrVal, ok := attr.Resolve(pc.Attribute)
-	if !ok {
-		return false, fmt.Errorf("missing required attribute: %s", pc.Attribute)
-	}
+	return h.EvalContext(context.Background(), pc, attr)
+}
+
+func (h *ComparisonHandler) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return evalCompiled(ctx, h, pc, attr)
+}
 
+// Compile compiles pc. Values compare with utils.Equal and utils.Compare
+// under the coercion of the context (see policies.CoercionOf).
+func (h *ComparisonHandler) Compile(pc policies.PolicyCondition) (Predicate, error) {
+	var test attributeTest
 	switch pc.Operator {
-	case policies.OpEqual:
-		return equal(attrVal, pc.Value), nil
-	case policies.OpNotEqual:
-		return !equal(attrVal, pc.Value), nil
+	case policies.OpEqual, policies.OpNotEqual:
+		eq := pc.Operator == policies.OpEqual
+		test = func(ctx context.Context, v any) (bool, error) {
+			return utils.Equal(v, pc.Value, policies.CoercionOf(ctx)) == eq, nil
+		}
 	case policies.OpGreater:
-		return greater(attrVal, pc.Value)
+		test = compareTo(pc.Value, func(n int) bool { return n > 0 })
 	case policies.OpGreaterOrEqual:
-		gt, err := greater(attrVal, pc.Value)
-		if err != nil {
-			return false, err
-		}
-		return gt || equal(attrVal, pc.Value), nil
+		test = compareTo(pc.Value, func(n int) bool { return n >= 0 })
 	case policies.OpLess:
-		gt, err := greater(attrVal, pc.Value)
-		if err != nil {
-			return false, err
-		}
-		eq := equal(attrVal, pc.Value)
-		return !gt && !eq, nil
+		test = compareTo(pc.Value, func(n int) bool { return n < 0 })
 	case policies.OpLessOrEqual:
-		gt, err := greater(attrVal, pc.Value)
-		if err != nil {
-			return false, err
-		}
-		return !gt || equal(attrVal, pc.Value), nil
-	default:
-		return false, fmt.Errorf("unsupported comparison operator: %s", pc.Operator)
-	}
-}
-
-func equal(a, b any) bool {
-	return reflect.DeepEqual(a, b)
-}
-
-func greater(a, b any) (bool, error) {
-	if atime, btime, err := ParseBothAsTime(a, b); err == nil {
-		return atime.After(btime), nil
-	}
-
-	aval := reflect.ValueOf(a)
-	bval := reflect.ValueOf(b)
-
-	if aval.Type() != bval.Type() {
-		return false, fmt.Errorf("cant compare differente type: %T x %T", a, b)
-	}
-
-	switch aval.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return aval.Int() > bval.Int(), nil
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return aval.Uint() > bval.Uint(), nil
-	case reflect.Float32, reflect.Float64:
-		return aval.Float() > bval.Float(), nil
-	case reflect.String:
-		return aval.String() > bval.String(), nil
+		test = compareTo(pc.Value, func(n int) bool { return n <= 0 })
 	default:
-		return false, fmt.Errorf("unsupported type for comparison: %v", aval.Kind())
+		test = failing(fmt.Errorf("unsupported comparison operator: %s", pc.Operator))
 	}
+	return attribute(pc.Attribute, test), nil
 }
 
-func ParseBothAsTime(a, b any) (time.Time, time.Time, error) {
-	var atime, btime time.Time
-	var err error
-
-	atime, err = utils.AnyToTime(a)
-	if err != nil {
-		return atime, btime, err
-	}
-
-	btime, err = utils.AnyToTime(b)
-	if err != nil {
-		return atime, btime, err
+// compareTo returns an attributeTest applying ok to the comparison of the
+// attribute value with b.
+func compareTo(b any, ok func(n int) bool) attributeTest {
+	return func(ctx context.Context, a any) (bool, error) {
+		n, err := utils.Compare(a, b, policies.CoercionOf(ctx))
+		if err != nil {
+			return false, err
+		}
+		return ok(n), nil
 	}
-
-	return atime, btime, nil
 }
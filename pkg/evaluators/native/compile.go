@@ -0,0 +1,148 @@
+package native
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// Predicate is a compiled condition, evaluated against the attributes
+// resolved by attr.
+type Predicate func(ctx context.Context, attr policies.Resolver) (bool, error)
+
+// Compiler is implemented by handlers able to compile a condition into a
+// Predicate, parsing its value once. Errors about the value are returned by
+// the Predicate, at the point the handler would report them when evaluating.
+type Compiler interface {
+	Compile(pc policies.PolicyCondition) (Predicate, error)
+}
+
+// Program is a PolicyCondition compiled by NativeEngine.Compile. It is
+// immutable and safe for concurrent use; the condition it was compiled from
+// must not be modified afterwards.
+type Program struct {
+	eval     Predicate
+	settings settings
+}
+
+// Eval evaluates the program against the attributes resolved by attr. ctx is
+// checked before every condition, as done by NativeEngine.EvalContext.
+func (p *Program) Eval(ctx context.Context, attr policies.Resolver) (bool, error) {
+	return p.eval(p.settings.apply(ctx), attr)
+}
+
+// Compile compiles pc into a Program. Operators are looked up once and the
+// values of built-in operators are parsed once: regular expressions are
+// compiled, sets hashed, times parsed and numbers converted. Conditions
+// whose values reference attributes are resolved on every evaluation.
+//
+// Unknown operators and operators without a handler are reported by Compile;
+// any other error is reported when the Program is evaluated.
+func (e *NativeEngine) Compile(pc policies.PolicyCondition) (*Program, error) {
+	eval, err := e.compile(pc)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{eval: eval, settings: e.settings}, nil
+}
+
+func (e *NativeEngine) compile(pc policies.PolicyCondition) (Predicate, error) {
+	spec, ok := policies.OperatorSpecOf(pc.Operator)
+	if !ok {
+		return nil, fmt.Errorf("unknown operator: %s", pc.Operator)
+	}
+
+	handler, err := e.handlerFor(pc.Operator, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Kind != policies.KindLogical && pc.Expression != nil {
+		x, inner := pc.Expression, expression(pc)
+		next, err := e.compile(inner)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, attr policies.Resolver) (bool, error) {
+			return evalExpression(ctx, x, inner.Attribute, attr, next)
+		}, nil
+	}
+
+	var eval Predicate
+	switch {
+	case spec.Kind == policies.KindLogical && handler == e.logical:
+		children := make([]Predicate, len(pc.Conditions))
+		for i, cond := range pc.Conditions {
+			if children[i], err = e.compile(cond); err != nil {
+				return nil, err
+			}
+		}
+		eval = combine(pc.Operator, children)
+	case spec.Kind != policies.KindLogical && policies.HasRefs(pc.Value):
+		h := policies.AsContextHandler(handler)
+		eval = func(ctx context.Context, attr policies.Resolver) (bool, error) {
+			resolved := pc
+			value, err := policies.ResolveRefs(ctx, pc.Value, attr)
+			if err != nil {
+				return policies.MissingReference(ctx, err)
+			}
+			resolved.Value = value
+			return h.EvalContext(ctx, resolved, attr)
+		}
+	default:
+		if c, ok := handler.(Compiler); ok {
+			if eval, err = c.Compile(pc); err != nil {
+				return nil, err
+			}
+			break
+		}
+		h := policies.AsContextHandler(handler)
+		eval = func(ctx context.Context, attr policies.Resolver) (bool, error) {
+			return h.EvalContext(ctx, pc, attr)
+		}
+	}
+
+	return func(ctx context.Context, attr policies.Resolver) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		return eval(ctx, attr)
+	}, nil
+}
+
+// attributeTest is the part of a compiled condition applied to the value
+// resolved for its attribute.
+type attributeTest func(ctx context.Context, v any) (bool, error)
+
+// attribute returns a Predicate applying test to the value of name. When
+// name is missing the result follows the policies.MissingAttributes of ctx
+// (see policies.MissingAttribute).
+func attribute(name string, test attributeTest) Predicate {
+	return func(ctx context.Context, attr policies.Resolver) (bool, error) {
+		v, ok, err := policies.ResolveContext(ctx, attr, name)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return policies.MissingAttribute(ctx, name)
+		}
+		return test(ctx, v)
+	}
+}
+
+// failing returns an attributeTest always failing with err.
+func failing(err error) attributeTest {
+	return func(context.Context, any) (bool, error) {
+		return false, err
+	}
+}
+
+// evalCompiled evaluates pc with the Predicate compiled by c.
+func evalCompiled(ctx context.Context, c Compiler, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	eval, err := c.Compile(pc)
+	if err != nil {
+		return false, err
+	}
+	return eval(ctx, attr)
+}
@@ -0,0 +1,305 @@
+package native_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies/policiestest"
+)
+
+func TestNativeEngine_Compile(t *testing.T) {
+	type input struct {
+		pc   policies.PolicyCondition
+		attr policies.Resolver
+	}
+	type output struct {
+		res        bool
+		compileErr string
+		evalErr    string
+	}
+
+	tests := []struct {
+		name   string
+		input  input
+		output output
+	}{
+		{
+			name:   "when operator unknown should fail to compile",
+			input:  input{pc: policies.PolicyCondition{Operator: policies.Operator("foo")}},
+			output: output{compileErr: "unknown operator: foo"},
+		},
+		{
+			name: "when nested operator unknown should fail to compile",
+			input: input{pc: policies.PolicyCondition{Operator: policies.OpOr, Conditions: []policies.PolicyCondition{
+				{Attribute: "a", Operator: policies.OpEqual, Value: 1},
+				{Operator: policies.Operator("foo")},
+			}}},
+			output: output{compileErr: "unknown operator: foo"},
+		},
+		{
+			name:   "when regex is invalid should fail on evaluation",
+			input:  input{pc: policies.PolicyCondition{Attribute: "name", Operator: policies.OpMatches, Value: "("}, attr: policies.MapAttributes{"name": "x"}},
+			output: output{evalErr: "missing closing )"},
+		},
+		{
+			name:   "when regex attribute is missing should report it first",
+			input:  input{pc: policies.PolicyCondition{Attribute: "name", Operator: policies.OpMatches, Value: "("}, attr: policies.MapAttributes{}},
+			output: output{evalErr: "missing required attribute: name"},
+		},
+		{
+			name:   "when regex matches should return true",
+			input:  input{pc: policies.PolicyCondition{Attribute: "name", Operator: policies.OpMatches, Value: `^a\d+$`}, attr: policies.MapAttributes{"name": "a42"}},
+			output: output{res: true},
+		},
+		{
+			name:   "when between bounds are invalid should report missing attribute first",
+			input:  input{pc: policies.PolicyCondition{Attribute: "age", Operator: policies.OpBetween, Value: "x"}, attr: policies.MapAttributes{}},
+			output: output{evalErr: "missing required attribute: age"},
+		},
+		{
+			name:   "when value is in hashed set should return true",
+			input:  input{pc: policies.PolicyCondition{Attribute: "role", Operator: policies.OpIn, Value: []any{"admin", []any{"x"}, "dev"}}, attr: policies.MapAttributes{"role": "dev"}},
+			output: output{res: true},
+		},
+		{
+			name:   "when value is a non scalar member should return true",
+			input:  input{pc: policies.PolicyCondition{Attribute: "pair", Operator: policies.OpIn, Value: []any{"admin", []any{"x"}}}, attr: policies.MapAttributes{"pair": []any{"x"}}},
+			output: output{res: true},
+		},
+		{
+			name:   "when numeric types differ should be in set",
+			input:  input{pc: policies.PolicyCondition{Attribute: "n", Operator: policies.OpIn, Value: []any{1, 2}}, attr: policies.MapAttributes{"n": int64(1)}},
+			output: output{res: true},
+		},
+		{
+			name:   "when superset is not a slice should fail on evaluation",
+			input:  input{pc: policies.PolicyCondition{Attribute: "roles", Operator: policies.OpSubset, Value: "admin"}, attr: policies.MapAttributes{"roles": []any{"admin"}}},
+			output: output{evalErr: "superset must be a slice or array, got string"},
+		},
+		{
+			name:   "when value references an attribute should resolve it on evaluation",
+			input:  input{pc: policies.PolicyCondition{Attribute: "owner", Operator: policies.OpEqual, Value: policies.Ref("user")}, attr: policies.MapAttributes{"owner": "u1", "user": "u1"}},
+			output: output{res: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := native.NewNativeEngine().(*native.NativeEngine)
+
+			program, err := eng.Compile(tt.input.pc)
+			if tt.output.compileErr != "" {
+				assert.ErrorContains(t, err, tt.output.compileErr)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			res, err := program.Eval(context.Background(), tt.input.attr)
+			if tt.output.evalErr != "" {
+				assert.ErrorContains(t, err, tt.output.evalErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.output.res, res)
+
+			res, err = eng.Eval(tt.input.pc, tt.input.attr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.output.res, res, "Eval and Compile must agree")
+		})
+	}
+}
+
+func TestProgram_EvalContext(t *testing.T) {
+	eng := native.NewNativeEngine().(*native.NativeEngine)
+	program, err := eng.Compile(policies.PolicyCondition{Attribute: "role", Operator: policies.OpEqual, Value: "admin"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = program.Eval(ctx, policies.MapAttributes{"role": "admin"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestProgram_Concurrent(t *testing.T) {
+	eng := native.NewNativeEngine().(*native.NativeEngine)
+	program, err := eng.Compile(policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+		{Attribute: "name", Operator: policies.OpMatches, Value: `^user-\d+$`},
+		{Attribute: "n", Operator: policies.OpMod, Value: 2},
+		{Attribute: "roles", Operator: policies.OpIntersects, Value: []any{"admin", "dev"}},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			ok, err := program.Eval(context.Background(), policies.MapAttributes{
+				"name":  "user-1",
+				"n":     n,
+				"roles": []any{"dev"},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, n%2 == 0, ok)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// countingHandler compares its attribute with its value and counts the
+// conditions it compiles.
+type countingHandler struct {
+	compiled atomic.Int64
+}
+
+func (h *countingHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	v, _ := attr.Resolve(pc.Attribute)
+	return v == pc.Value, nil
+}
+
+func (h *countingHandler) Compile(pc policies.PolicyCondition) (native.Predicate, error) {
+	h.compiled.Add(1)
+	return func(ctx context.Context, attr policies.Resolver) (bool, error) {
+		return h.Eval(pc, attr)
+	}, nil
+}
+
+func TestNativeEngine_CachesCompiledConditions(t *testing.T) {
+	const opCounted policies.Operator = "test_counted"
+	policiestest.MustRegisterOperator(t, opCounted, policies.OperatorSpec{Kind: policies.KindCustom, MinArgs: 2, MaxArgs: 2})
+
+	t.Run("when evaluated again should not compile", func(t *testing.T) {
+		h := &countingHandler{}
+		eng := native.NewNativeEngine(native.WithOperatorHandler(opCounted, h))
+		cond := policies.PolicyCondition{Attribute: "role", Operator: opCounted, Value: "admin"}
+
+		for i := 0; i < 3; i++ {
+			ok, err := eng.Eval(cond, policies.MapAttributes{"role": "admin"})
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		assert.Equal(t, int64(1), h.compiled.Load())
+	})
+
+	t.Run("when values differ by type should compile each", func(t *testing.T) {
+		h := &countingHandler{}
+		eng := native.NewNativeEngine(native.WithOperatorHandler(opCounted, h))
+
+		ok, err := eng.Eval(policies.PolicyCondition{Attribute: "n", Operator: opCounted, Value: 5}, policies.MapAttributes{"n": 5})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = eng.Eval(policies.PolicyCondition{Attribute: "n", Operator: opCounted, Value: 5.0}, policies.MapAttributes{"n": 5})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, int64(2), h.compiled.Load())
+	})
+
+	t.Run("when the condition changes after evaluation should use the new value", func(t *testing.T) {
+		h := &countingHandler{}
+		eng := native.NewNativeEngine(native.WithOperatorHandler(opCounted, h))
+		cond := policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+			{Attribute: "role", Operator: opCounted, Value: "admin"},
+		}}
+
+		ok, _ := eng.Eval(cond, policies.MapAttributes{"role": "admin"})
+		assert.True(t, ok)
+		cond.Conditions[0].Value = "guest"
+		ok, _ = eng.Eval(cond, policies.MapAttributes{"role": "admin"})
+		assert.False(t, ok)
+	})
+
+	t.Run("when the cache is disabled should compile every time", func(t *testing.T) {
+		h := &countingHandler{}
+		eng := native.NewNativeEngine(native.WithOperatorHandler(opCounted, h), native.WithCacheSize(0))
+		cond := policies.PolicyCondition{Attribute: "role", Operator: opCounted, Value: "admin"}
+
+		_, _ = eng.Eval(cond, policies.MapAttributes{"role": "admin"})
+		_, _ = eng.Eval(cond, policies.MapAttributes{"role": "admin"})
+		assert.Equal(t, int64(2), h.compiled.Load())
+	})
+
+	t.Run("when the caller gives a key should cache by key", func(t *testing.T) {
+		h := &countingHandler{}
+		eng := native.NewNativeEngine(native.WithOperatorHandler(opCounted, h)).(policies.ContextEngine)
+		cond := policies.PolicyCondition{Attribute: "role", Operator: opCounted, Value: "admin"}
+		ctx := policies.ContextWithConditionKey(context.Background(), "p1@1")
+
+		for i := 0; i < 3; i++ {
+			ok, err := eng.EvalContext(ctx, cond, policies.MapAttributes{"role": "admin"})
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		ok, _ := eng.EvalContext(context.Background(), cond, policies.MapAttributes{"role": "admin"})
+		assert.True(t, ok)
+		ok, _ = eng.EvalContext(policies.ContextWithConditionKey(context.Background(), "p1@2"), cond, policies.MapAttributes{"role": "admin"})
+		assert.True(t, ok)
+		assert.Equal(t, int64(3), h.compiled.Load(), "one compilation per key and one by content")
+	})
+
+	t.Run("when the value is cyclic should not cache", func(t *testing.T) {
+		h := &countingHandler{}
+		eng := native.NewNativeEngine(native.WithOperatorHandler(opCounted, h))
+		loop := &cyclic{}
+		loop.next = loop
+		cond := policies.PolicyCondition{Attribute: "node", Operator: opCounted, Value: loop}
+
+		for i := 0; i < 2; i++ {
+			ok, err := eng.Eval(cond, policies.MapAttributes{"node": loop})
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		assert.Equal(t, int64(2), h.compiled.Load())
+	})
+}
+
+type cyclic struct {
+	next *cyclic
+}
+
+func BenchmarkNativeEngine_EvalContext(b *testing.B) {
+	cond := policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+		{Attribute: "subject.role", Operator: policies.OpIn, Value: []any{"admin", "editor", "owner"}},
+		{Attribute: "resource.size", Operator: policies.OpLessOrEqual, Value: 1024},
+		{Attribute: "request.time", Operator: policies.OpAfter, Value: "2020-01-01T00:00:00Z"},
+		{Operator: policies.OpOr, Conditions: []policies.PolicyCondition{
+			{Attribute: "subject.id", Operator: policies.OpEqual, Value: policies.Ref("resource.owner")},
+			{Attribute: "resource.tags", Operator: policies.OpIntersects, Value: []any{"public", "shared"}},
+		}},
+	}}
+	attrs := policies.MapAttributes{
+		"subject":  map[string]any{"id": "u1", "role": "editor"},
+		"resource": map[string]any{"owner": "u2", "size": 512, "tags": []any{"shared"}},
+		"request":  map[string]any{"time": "2024-06-01T00:00:00Z"},
+	}
+
+	for _, bb := range []struct {
+		name string
+		ctx  context.Context
+		opts []native.Option
+	}{
+		{name: "uncached", ctx: context.Background(), opts: []native.Option{native.WithCacheSize(0)}},
+		{name: "cached by content", ctx: context.Background()},
+		{name: "cached by key", ctx: policies.ContextWithConditionKey(context.Background(), "p1@1")},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			eng := native.NewNativeEngine(bb.opts...).(policies.ContextEngine)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if ok, err := eng.EvalContext(bb.ctx, cond, attrs); err != nil || !ok {
+					b.Fatal(ok, err)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package native
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
 )
 
 type OperatorHandler interface {
@@ -11,10 +13,76 @@ type OperatorHandler interface {
 }
 
 type NativeEngine struct {
-	handlers map[policies.OperatorKind]OperatorHandler
+	handlers  map[policies.OperatorKind]OperatorHandler
+	operators map[policies.Operator]OperatorHandler
+	logical   OperatorHandler
+	settings  settings
+	cacheSize int
+	cache     *predicateCache
 }
 
-func NewNativeEngine() policies.Engine {
+// settings are the engine-wide settings passed to handlers through the
+// context.
+type settings struct {
+	missing  policies.MissingAttributes
+	coercion utils.Coercion
+}
+
+// apply returns ctx carrying s.
+func (s settings) apply(ctx context.Context) context.Context {
+	if m, ok := policies.MissingAttributesOf(ctx); !ok || m != s.missing {
+		ctx = policies.ContextWithMissingAttributes(ctx, s.missing)
+	}
+	if policies.CoercionOf(ctx) != s.coercion {
+		ctx = policies.ContextWithCoercion(ctx, s.coercion)
+	}
+	return ctx
+}
+
+// Option configures a NativeEngine built by NewNativeEngine.
+type Option func(*NativeEngine)
+
+// WithOperatorHandler registers h for op. Operator handlers take precedence
+// over the handler of the operator's kind, so they can add operators
+// registered with policies.RegisterOperator or override built-in ones.
+func WithOperatorHandler(op policies.Operator, h OperatorHandler) Option {
+	return func(e *NativeEngine) {
+		e.operators[op] = h
+	}
+}
+
+// WithKindHandler replaces the handler used for every operator of kind.
+func WithKindHandler(kind policies.OperatorKind, h OperatorHandler) Option {
+	return func(e *NativeEngine) {
+		e.handlers[kind] = h
+	}
+}
+
+// WithMissingAttributes sets how conditions on missing attributes evaluate.
+// It defaults to policies.MissingError.
+func WithMissingAttributes(m policies.MissingAttributes) Option {
+	return func(e *NativeEngine) {
+		e.settings.missing = m
+	}
+}
+
+// WithCoercion sets how values of different types compare. It defaults to
+// utils.CoerceNumbers; utils.CoerceNone requires exact typing.
+func WithCoercion(c utils.Coercion) Option {
+	return func(e *NativeEngine) {
+		e.settings.coercion = c
+	}
+}
+
+// WithCacheSize sets the number of compiled conditions kept by the engine.
+// A size of zero or less disables the cache.
+func WithCacheSize(n int) Option {
+	return func(e *NativeEngine) {
+		e.cacheSize = n
+	}
+}
+
+func NewNativeEngine(opts ...Option) policies.Engine {
 	handlers := make(map[policies.OperatorKind]OperatorHandler)
 	handlers[policies.KindArithmetic] = NewArithmeticHandler()
 	handlers[policies.KindComparison] = NewComparisonHandler()
@@ -22,22 +90,122 @@ func NewNativeEngine() policies.Engine {
 	handlers[policies.KindSet] = NewSetHandler()
 	handlers[policies.KindRange] = NewRangeHandler()
 	handlers[policies.KindTemporal] = NewTemporalHandler()
+	handlers[policies.KindPresence] = NewPresenceHandler()
 
-	eng := &NativeEngine{handlers: handlers}
-	eng.handlers[policies.KindLogical] = NewLogicalHandler(eng.Eval)
+	eng := &NativeEngine{
+		handlers:  handlers,
+		operators: make(map[policies.Operator]OperatorHandler),
+		cacheSize: DefaultCacheSize,
+	}
+	eng.logical = NewContextLogicalHandler(eng.EvalContext)
+	eng.handlers[policies.KindLogical] = eng.logical
+	for _, opt := range opts {
+		opt(eng)
+	}
+	eng.cache = newPredicateCache(eng.cacheSize)
 	return eng
 }
 
 func (e *NativeEngine) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return e.EvalContext(context.Background(), pc, attr)
+}
+
+// EvalContext evaluates pc, checking ctx before every condition and passing
+// it down to the handlers and the Resolver. Conditions are compiled once (see
+// Compile) and kept in a cache of the engine, so that evaluating the same
+// condition again only runs its compiled form. They are cached by the key of
+// ctx (see policies.ContextWithConditionKey), or by their content.
+func (e *NativeEngine) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	ctx = e.settings.apply(ctx)
+	if parent, ok := traceFromContext(ctx); ok {
+		return e.trace(ctx, parent, pc, attr)
+	}
+	key, keyed := policies.ConditionKey(ctx)
+	if keyed {
+		// The key identifies pc only, not the conditions handlers may
+		// evaluate with ctx.
+		ctx = policies.ContextWithConditionKey(ctx, "")
+	}
+	eval, err := e.cached(pc, key, keyed)
+	if err != nil {
+		return false, err
+	}
+	return eval(ctx, attr)
+}
+
+// cached returns the compiled form of pc, cached by the key given by the
+// caller when keyed, or by conditionKey. It compiles a copy of pc on a cache
+// miss so that later changes to pc do not affect it.
+func (e *NativeEngine) cached(pc policies.PolicyCondition, key string, keyed bool) (Predicate, error) {
+	if e.cache == nil || e.cache.size <= 0 {
+		return e.compile(pc)
+	}
+	ck := cacheKey{key: key, caller: keyed}
+	if !keyed {
+		var ok bool
+		if ck.key, ok = conditionKey(pc); !ok {
+			return e.compile(pc)
+		}
+	}
+	if eval, ok := e.cache.get(ck); ok {
+		return eval, nil
+	}
+	eval, err := e.compile(pc.Clone())
+	if err != nil {
+		return nil, err
+	}
+	e.cache.add(ck, eval)
+	return eval, nil
+}
+
+func (e *NativeEngine) eval(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
 	spec, ok := policies.OperatorSpecOf(pc.Operator)
 	if !ok {
 		return false, fmt.Errorf("unknown operator: %s", pc.Operator)
 	}
 
+	handler, err := e.handlerFor(pc.Operator, spec)
+	if err != nil {
+		return false, err
+	}
+
+	// Handlers only ever see attributes: the value of an expression is
+	// resolved as that of an attribute named after it.
+	if spec.Kind != policies.KindLogical && pc.Expression != nil {
+		x, inner := pc.Expression, expression(pc)
+		return evalExpression(ctx, x, inner.Attribute, attr, func(ctx context.Context, attr policies.Resolver) (bool, error) {
+			return e.eval(ctx, inner, attr)
+		})
+	}
+
+	// Handlers only ever see literal values: attribute references are
+	// replaced by the values they point to, and a missing one counts as a
+	// missing attribute.
+	if spec.Kind != policies.KindLogical && policies.HasRefs(pc.Value) {
+		pc.Value, err = policies.ResolveRefs(ctx, pc.Value, attr)
+		if err != nil {
+			return policies.MissingReference(ctx, err)
+		}
+		if node, ok := traceFromContext(ctx); ok {
+			node.Expected = pc.Value
+		}
+	}
+
+	return policies.AsContextHandler(handler).EvalContext(ctx, pc, attr)
+}
+
+func (e *NativeEngine) handlerFor(op policies.Operator, spec policies.OperatorSpec) (OperatorHandler, error) {
+	if handler, ok := e.operators[op]; ok {
+		return handler, nil
+	}
+
 	handler, ok := e.handlers[spec.Kind]
 	if !ok {
-		return false, fmt.Errorf("unsupported operator kind: %v", spec.Kind)
+		return nil, fmt.Errorf("unsupported operator kind: %v", spec.Kind)
 	}
-
-	return handler.Eval(pc, attr)
+	return handler, nil
 }
@@ -1,12 +1,15 @@
 package native_test
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies/policiestest"
 )
 
 func TestNativeEngine_Eval(t *testing.T) {
@@ -124,3 +127,86 @@ func TestNativeEngine_Eval(t *testing.T) {
 		})
 	}
 }
+
+type ctxKey struct{}
+
+type ctxResolver struct {
+	policies.MapAttributes
+	seen []context.Context
+}
+
+func (r *ctxResolver) ResolveContext(ctx context.Context, attribute string) (any, bool, error) {
+	r.seen = append(r.seen, ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	v, ok := r.Resolve(attribute)
+	return v, ok, nil
+}
+
+func TestNativeEngine_EvalContext(t *testing.T) {
+	cond := policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+		{Attribute: "a", Operator: policies.OpEqual, Value: 1},
+		{Attribute: "b", Operator: policies.OpIn, Value: []int{1, 2}},
+	}}
+
+	t.Run("when context is active should pass it to the resolver", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxKey{}, "marker")
+		r := &ctxResolver{MapAttributes: policies.MapAttributes{"a": 1, "b": 2}}
+		eng := native.NewNativeEngine().(policies.ContextEngine)
+
+		ok, err := eng.EvalContext(ctx, cond, r)
+
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		if assert.Len(t, r.seen, 2) {
+			assert.Equal(t, "marker", r.seen[1].Value(ctxKey{}))
+		}
+	})
+
+	t.Run("when context is cancelled should return its error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		eng := native.NewNativeEngine().(policies.ContextEngine)
+
+		_, err := eng.EvalContext(ctx, cond, policies.MapAttributes{"a": 1, "b": 2})
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+type cidrHandler struct{}
+
+func (cidrHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	v, ok := attr.Resolve(pc.Attribute)
+	if !ok {
+		return false, fmt.Errorf("missing required attribute: %s", pc.Attribute)
+	}
+	_, network, err := net.ParseCIDR(pc.Value.(string))
+	if err != nil {
+		return false, err
+	}
+	return network.Contains(net.ParseIP(v.(string))), nil
+}
+
+func TestNativeEngine_WithOperatorHandler(t *testing.T) {
+	const opInCIDR policies.Operator = "test_in_cidr"
+	policiestest.MustRegisterOperator(t, opInCIDR, policies.OperatorSpec{Kind: policies.KindCustom, MinArgs: 2, MaxArgs: 2})
+	eng := native.NewNativeEngine(native.WithOperatorHandler(opInCIDR, cidrHandler{}))
+
+	cond := policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+		{Attribute: "ip", Operator: opInCIDR, Value: "10.0.0.0/8"},
+		{Attribute: "active", Operator: policies.OpEqual, Value: true},
+	}}
+
+	ok, err := eng.Eval(cond, policies.MapAttributes{"ip": "10.1.2.3", "active": true})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = eng.Eval(cond, policies.MapAttributes{"ip": "192.168.0.1", "active": true})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = native.NewNativeEngine().Eval(cond, policies.MapAttributes{"ip": "10.1.2.3", "active": true})
+	assert.EqualError(t, err, "unsupported operator kind: custom")
+}
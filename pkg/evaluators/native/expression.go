@@ -0,0 +1,85 @@
+package native
+
+import (
+	"context"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// expression returns the condition a handler evaluates for the Expression
+// of pc: one on an attribute named after the expression, which next resolves
+// to its value through an expressionResolver.
+func expression(pc policies.PolicyCondition) policies.PolicyCondition {
+	pc.Attribute = pc.Expression.String()
+	pc.Expression = nil
+	return pc
+}
+
+// evalExpression evaluates x and calls next with a Resolver resolving the
+// attribute name to its value. When an attribute x refers to is missing,
+// the result follows the policies.MissingAttributes of ctx (see
+// policies.MissingAttribute).
+func evalExpression(ctx context.Context, x *policies.Arithmetic, name string, attr policies.Resolver, next Predicate) (bool, error) {
+	r := &missingResolver{Resolver: attr}
+	v, err := x.Eval(ctx, r)
+	if r.missing != "" {
+		return policies.MissingAttribute(ctx, r.missing)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if node, ok := traceFromContext(ctx); ok && node.Attribute == name {
+		node.Value = v
+		node.Resolved = true
+	}
+	return next(ctx, &expressionResolver{Resolver: attr, name: name, value: v})
+}
+
+// missingResolver records the first attribute not found.
+type missingResolver struct {
+	policies.Resolver
+	missing string
+}
+
+func (r *missingResolver) Resolve(attribute string) (any, bool) {
+	v, ok := r.Resolver.Resolve(attribute)
+	r.record(attribute, ok)
+	return v, ok
+}
+
+func (r *missingResolver) ResolveContext(ctx context.Context, attribute string) (any, bool, error) {
+	v, ok, err := policies.ResolveContext(ctx, r.Resolver, attribute)
+	if err == nil {
+		r.record(attribute, ok)
+	}
+	return v, ok, err
+}
+
+func (r *missingResolver) record(attribute string, ok bool) {
+	if !ok && r.missing == "" {
+		r.missing = attribute
+	}
+}
+
+// expressionResolver resolves the attribute name to the value of an
+// expression.
+type expressionResolver struct {
+	policies.Resolver
+	name  string
+	value any
+}
+
+func (r *expressionResolver) Resolve(attribute string) (any, bool) {
+	if attribute == r.name {
+		return r.value, true
+	}
+	return r.Resolver.Resolve(attribute)
+}
+
+func (r *expressionResolver) ResolveContext(ctx context.Context, attribute string) (any, bool, error) {
+	if attribute == r.name {
+		return r.value, true, nil
+	}
+	return policies.ResolveContext(ctx, r.Resolver, attribute)
+}
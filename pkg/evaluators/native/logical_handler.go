@@ -1,6 +1,7 @@
 package native
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
@@ -8,53 +9,88 @@ import (
 
 type Eval func(pc policies.PolicyCondition, attr policies.Resolver) (bool, error)
 
+// EvalContext evaluates a child condition honouring ctx.
+type EvalContext func(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error)
+
 type LogicalHandler struct {
-	eval Eval
+	eval EvalContext
 }
 
 func NewLogicalHandler(eval Eval) OperatorHandler {
+	return NewContextLogicalHandler(func(_ context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+		return eval(pc, attr)
+	})
+}
+
+// NewContextLogicalHandler builds a LogicalHandler whose children are
+// evaluated with eval, receiving the caller's context.
+func NewContextLogicalHandler(eval EvalContext) OperatorHandler {
 	return &LogicalHandler{eval: eval}
 }
 
 func (h *LogicalHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
-	switch pc.Operator {
-	case policies.OpAnd:
-		for _, cond := range pc.Conditions {
-			ok, err := h.eval(cond, attr)
-			if err != nil {
-				return false, err
-			}
-			if !ok {
-				return false, nil
-			}
+	return h.EvalContext(context.Background(), pc, attr)
+}
+
+func (h *LogicalHandler) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	children := make([]Predicate, len(pc.Conditions))
+	for i, cond := range pc.Conditions {
+		children[i] = func(ctx context.Context, attr policies.Resolver) (bool, error) {
+			return h.eval(ctx, cond, attr)
 		}
-		return true, nil
-	case policies.OpOr:
-		var lastErr error
-		for _, cond := range pc.Conditions {
-			ok, err := h.eval(cond, attr)
-			if err != nil {
-				lastErr = err
-				continue
+	}
+	return combine(pc.Operator, children)(ctx, attr)
+}
+
+// combine returns a Predicate combining children with the logical operator
+// op under three-valued logic (see package policies): the errors of children
+// are unknown results, so and and or only short-circuit on false and true
+// respectively, and fail with policies.Unknown of the errors of their
+// children when undecided. Cancellation of ctx still aborts them.
+func combine(op policies.Operator, children []Predicate) Predicate {
+	switch op {
+	case policies.OpAnd, policies.OpOr:
+		// decisive is the result deciding the operator on its own.
+		decisive := op == policies.OpOr
+		return func(ctx context.Context, attr policies.Resolver) (bool, error) {
+			var causes []error
+			for _, child := range children {
+				if err := ctx.Err(); err != nil {
+					return false, err
+				}
+				ok, err := child(ctx, attr)
+				if err != nil {
+					if ctx.Err() != nil {
+						return false, err
+					}
+					causes = append(causes, err)
+					continue
+				}
+				if ok == decisive {
+					return decisive, nil
+				}
 			}
-			if ok {
-				return true, nil
+			if len(causes) > 0 {
+				return false, policies.Unknown(causes...)
 			}
+			return !decisive, nil
 		}
-		if lastErr != nil {
-			return false, lastErr
-		}
-		return false, nil
 	case policies.OpNot:
-		if len(pc.Conditions) != 1 {
-			return false, fmt.Errorf("not operator requires 1 condition")
+		if len(children) != 1 {
+			return func(context.Context, policies.Resolver) (bool, error) {
+				return false, fmt.Errorf("not operator requires 1 condition")
+			}
 		}
-		ok, err := h.eval(pc.Conditions[0], attr)
-		if err != nil {
-			return false, err
+		return func(ctx context.Context, attr policies.Resolver) (bool, error) {
+			ok, err := children[0](ctx, attr)
+			if err != nil {
+				return false, err
+			}
+			return !ok, nil
 		}
-		return !ok, nil
 	default:
-		return false, fmt.Errorf("unsupported logical operator: %s", pc.Operator)
+		return func(context.Context, policies.Resolver) (bool, error) {
+			return false, fmt.Errorf("unsupported logical operator: %s", op)
+		}
 	}
 }
@@ -77,6 +77,24 @@ func TestLogicalHandler_Eval(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "when a later child false should return false despite earlier errors",
+			input: input{
+				pc: policies.PolicyCondition{
+					Operator: policies.OpAnd,
+					Conditions: []policies.PolicyCondition{
+						{Attribute: "x", Operator: policies.OpEqual, Value: true},
+						{Attribute: "a", Operator: policies.OpEqual, Value: 2},
+					},
+				},
+				attr: policies.MapAttributes{"a": 1},
+			},
+			output: output{res: false, err: nil},
+			assert: func(t *testing.T, expected, actual output) {
+				assert.NoError(t, actual.err)
+				assert.Equal(t, expected.res, actual.res)
+			},
+		},
 		{
 			name: "when any child true should return true",
 			input: input{
@@ -0,0 +1,50 @@
+package native
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// PresenceHandler implements exists and not_exists. An attribute exists when
+// the Resolver finds it, even with a nil value; the condition value is
+// ignored. The policies.MissingAttributes of the engine do not apply.
+type PresenceHandler struct{}
+
+// NewPresenceHandler constructs a PresenceHandler.
+func NewPresenceHandler() OperatorHandler {
+	return &PresenceHandler{}
+}
+
+// Eval evaluates pc with a background context.
+func (h *PresenceHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return h.EvalContext(context.Background(), pc, attr)
+}
+
+// EvalContext reports whether the attribute of pc is found, or not found for
+// not_exists.
+func (h *PresenceHandler) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return evalCompiled(ctx, h, pc, attr)
+}
+
+// Compile compiles pc.
+func (h *PresenceHandler) Compile(pc policies.PolicyCondition) (Predicate, error) {
+	var want bool
+	switch pc.Operator {
+	case policies.OpExists:
+		want = true
+	case policies.OpNotExists:
+		want = false
+	default:
+		return nil, fmt.Errorf("unsupported presence operator: %s", pc.Operator)
+	}
+
+	return func(ctx context.Context, attr policies.Resolver) (bool, error) {
+		_, ok, err := policies.ResolveContext(ctx, attr, pc.Attribute)
+		if err != nil {
+			return false, err
+		}
+		return ok == want, nil
+	}, nil
+}
@@ -0,0 +1,46 @@
+package native_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestNativeEngine_AttributeRefs(t *testing.T) {
+	attr := policies.MapAttributes{
+		"subject":  map[string]any{"id": "u1", "groups": []any{"a", "b"}, "prefix": "rep"},
+		"resource": map[string]any{"owner": "u1", "group": "b", "name": "report.pdf", "created": "2021-01-01"},
+		"request":  map[string]any{"amount": 50, "at": "2022-01-01"},
+		"account":  map[string]any{"min": 10, "limit": 100},
+	}
+
+	tests := []struct {
+		name string
+		cond policies.PolicyCondition
+		res  bool
+		err  string
+	}{
+		{name: "comparison", cond: policies.PolicyCondition{Attribute: "resource.owner", Operator: policies.OpEqual, Value: policies.Ref("subject.id")}, res: true},
+		{name: "ordering", cond: policies.PolicyCondition{Attribute: "request.amount", Operator: policies.OpLessOrEqual, Value: map[string]any{"ref": "account.limit"}}, res: true},
+		{name: "set", cond: policies.PolicyCondition{Attribute: "resource.group", Operator: policies.OpIn, Value: policies.Ref("subject.groups")}, res: true},
+		{name: "string", cond: policies.PolicyCondition{Attribute: "resource.name", Operator: policies.OpStartsWith, Value: policies.Ref("subject.prefix")}, res: true},
+		{name: "temporal", cond: policies.PolicyCondition{Attribute: "request.at", Operator: policies.OpAfter, Value: policies.Ref("resource.created")}, res: true},
+		{name: "range bounds", cond: policies.PolicyCondition{Attribute: "request.amount", Operator: policies.OpBetween, Value: []any{policies.Ref("account.min"), policies.Ref("account.limit")}}, res: true},
+		{name: "arithmetic", cond: policies.PolicyCondition{Attribute: "account.limit", Operator: policies.OpMod, Value: policies.Ref("account.min")}, res: true},
+		{name: "missing reference", cond: policies.PolicyCondition{Attribute: "resource.owner", Operator: policies.OpEqual, Value: policies.Ref("subject.name")}, err: "missing required attribute: subject.name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := native.NewNativeEngine().Eval(tt.cond, attr)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.res, res)
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package native
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
 )
 
 type SetHandler struct{}
@@ -14,112 +16,99 @@ func NewSetHandler() OperatorHandler {
 }
 
 func (h *SetHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
-	attrVal, ok := attr.Resolve(pc.Attribute)
-	if !ok {
-		return false, nil
-	}
-	switch pc.Operator {
-	case policies.OpIn:
-		return contains(pc.Value, attrVal)
-	case policies.OpNotIn:
-		ok, err := contains(pc.Value, attrVal)
-		if err != nil {
-			return false, err
-		}
-		return !ok, nil
-	case policies.OpSubset:
-		return isSubset(attrVal, pc.Value)
-	case policies.OpNotSubset:
-		subset, err := isSubset(attrVal, pc.Value)
-		return !subset, err
-	case policies.OpIntersects:
-		return intersects(attrVal, pc.Value)
-	case policies.OpDisjoint:
-		inter, err := intersects(attrVal, pc.Value)
-		return !inter, err
-	default:
-		return false, fmt.Errorf("unsupported set operator: %s", pc.Operator)
-	}
+	return h.EvalContext(context.Background(), pc, attr)
+}
+
+func (h *SetHandler) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return evalCompiled(ctx, h, pc, attr)
 }
 
-func contains(set any, item any) (bool, error) {
-	setVal := reflect.ValueOf(set)
-	switch setVal.Kind() {
-	case reflect.Slice, reflect.Array:
-		for i := 0; i < setVal.Len(); i++ {
-			if reflect.DeepEqual(setVal.Index(i).Interface(), item) {
-				return true, nil
+// Compile compiles pc, hashing the elements of its value once. Elements are
+// compared with utils.Equal under the coercion of the context (see
+// policies.CoercionOf).
+func (h *SetHandler) Compile(pc policies.PolicyCondition) (Predicate, error) {
+	var test attributeTest
+	switch pc.Operator {
+	case policies.OpIn, policies.OpNotIn:
+		set := utils.NewSet(pc.Value, true)
+		in := pc.Operator == policies.OpIn
+		test = func(ctx context.Context, v any) (bool, error) {
+			return set.Contains(v, policies.CoercionOf(ctx)) == in, nil
+		}
+	case policies.OpSubset, policies.OpNotSubset:
+		set := utils.NewSet(pc.Value, false)
+		var err error
+		if !isList(pc.Value) {
+			err = fmt.Errorf("superset must be a slice or array, got %v", reflect.ValueOf(pc.Value).Kind())
+		}
+		subset := pc.Operator == policies.OpSubset
+		test = func(ctx context.Context, v any) (bool, error) {
+			ok, err := isSubset(v, set, policies.CoercionOf(ctx), err)
+			if err != nil {
+				return false, err
 			}
+			return ok == subset, nil
+		}
+	case policies.OpIntersects, policies.OpDisjoint:
+		set := utils.NewSet(pc.Value, false)
+		var err error
+		if !isList(pc.Value) {
+			err = fmt.Errorf("second set must be a slice or array")
 		}
-		return false, nil
-	case reflect.Map:
-		mapKeys := setVal.MapKeys()
-		for _, key := range mapKeys {
-			if reflect.DeepEqual(key.Interface(), item) {
-				return true, nil
+		intersect := pc.Operator == policies.OpIntersects
+		test = func(ctx context.Context, v any) (bool, error) {
+			ok, err := intersects(v, set, policies.CoercionOf(ctx), err)
+			if err != nil {
+				return false, err
 			}
+			return ok == intersect, nil
 		}
-		return false, nil
 	default:
-		return false, nil
+		test = failing(fmt.Errorf("unsupported set operator: %s", pc.Operator))
 	}
+	return attribute(pc.Attribute, test), nil
 }
 
-func isSubset(subset any, set any) (bool, error) {
-	subsetVal := reflect.ValueOf(subset)
-	setVal := reflect.ValueOf(set)
+// isList reports whether v is a slice or an array.
+func isList(v any) bool {
+	kind := reflect.ValueOf(v).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
 
-	if subsetVal.Kind() != reflect.Slice && subsetVal.Kind() != reflect.Array {
+// isSubset reports whether every element of subset is in set. setErr is the
+// error found compiling set, reported once subset is known to be a slice.
+func isSubset(subset any, set *utils.Set, c utils.Coercion, setErr error) (bool, error) {
+	subsetVal := reflect.ValueOf(subset)
+	if !isList(subset) {
 		return false, fmt.Errorf("subset must be a slice or array, got %v", subsetVal.Kind())
 	}
-	if setVal.Kind() != reflect.Slice && setVal.Kind() != reflect.Array {
-		return false, fmt.Errorf("superset must be a slice or array, got %v", setVal.Kind())
-	}
-
-	setMap := make(map[any]bool)
-	for i := 0; i < setVal.Len(); i++ {
-		setMap[setVal.Index(i).Interface()] = true
+	if setErr != nil {
+		return false, setErr
 	}
 
 	for i := 0; i < subsetVal.Len(); i++ {
-		if !setMap[subsetVal.Index(i).Interface()] {
+		if !set.Contains(subsetVal.Index(i).Interface(), c) {
 			return false, nil
 		}
 	}
-
 	return true, nil
 }
 
-func intersects(setA, setB any) (bool, error) {
+// intersects reports whether an element of setA is in setB. setErr is the
+// error found compiling setB, reported once setA is known to be a slice.
+func intersects(setA any, setB *utils.Set, c utils.Coercion, setErr error) (bool, error) {
 	setAVal := reflect.ValueOf(setA)
-	setBVal := reflect.ValueOf(setB)
-
-	if setAVal.Kind() != reflect.Slice && setAVal.Kind() != reflect.Array {
+	if !isList(setA) {
 		return false, fmt.Errorf("first set must be a slice or array")
 	}
-	if setBVal.Kind() != reflect.Slice && setBVal.Kind() != reflect.Array {
-		return false, fmt.Errorf("second set must be a slice or array")
+	if setErr != nil {
+		return false, setErr
 	}
 
-	var smaller, larger reflect.Value
-	if setAVal.Len() < setBVal.Len() {
-		smaller = setAVal
-		larger = setBVal
-	} else {
-		smaller = setBVal
-		larger = setAVal
-	}
-
-	smallerMap := make(map[any]bool)
-	for i := 0; i < smaller.Len(); i++ {
-		smallerMap[smaller.Index(i).Interface()] = true
-	}
-
-	for i := 0; i < larger.Len(); i++ {
-		if smallerMap[larger.Index(i).Interface()] {
+	for i := 0; i < setAVal.Len(); i++ {
+		if setB.Contains(setAVal.Index(i).Interface(), c) {
 			return true, nil
 		}
 	}
-
 	return false, nil
 }
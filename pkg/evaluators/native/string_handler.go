@@ -1,6 +1,7 @@
 package native
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -16,38 +17,58 @@ func NewStringHandler() OperatorHandler {
 }
 
 func (h *stringHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
-	attrVal, ok := attr.Resolve(pc.Attribute)
-	if !ok {
-		return false, nil
-	}
+	return h.EvalContext(context.Background(), pc, attr)
+}
 
-	// convert attribute and value to string using helper
-	as, err := utils.AnyToString(attrVal)
-	if err != nil {
-		return false, err
-	}
+func (h *stringHandler) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return evalCompiled(ctx, h, pc, attr)
+}
 
-	vs, err := utils.AnyToString(pc.Value)
-	if err != nil {
-		return false, err
-	}
+// Compile compiles pc, converting its value to a string and compiling the
+// regular expression of matches once.
+func (h *stringHandler) Compile(pc policies.PolicyCondition) (Predicate, error) {
+	vs, valueErr := utils.AnyToString(pc.Value)
 
+	var test func(ctx context.Context, as string) (bool, error)
 	switch pc.Operator {
 	case policies.OpContains:
-		return strings.Contains(as, vs), nil
+		test = func(_ context.Context, as string) (bool, error) { return strings.Contains(as, vs), nil }
 	case policies.OpNotContains:
-		return !strings.Contains(as, vs), nil
+		test = func(_ context.Context, as string) (bool, error) { return !strings.Contains(as, vs), nil }
 	case policies.OpStartsWith:
-		return strings.HasPrefix(as, vs), nil
+		test = func(_ context.Context, as string) (bool, error) { return strings.HasPrefix(as, vs), nil }
 	case policies.OpEndsWith:
-		return strings.HasSuffix(as, vs), nil
+		test = func(_ context.Context, as string) (bool, error) { return strings.HasSuffix(as, vs), nil }
 	case policies.OpMatches:
-		re, err := regexp.Compile(vs)
-		if err != nil {
-			return false, err
+		var re *regexp.Regexp
+		var reErr error
+		if valueErr == nil {
+			re, reErr = regexp.Compile(vs)
+		}
+		test = func(ctx context.Context, as string) (bool, error) {
+			if reErr != nil {
+				return false, reErr
+			}
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			return re.MatchString(as), nil
 		}
-		return re.MatchString(as), nil
 	default:
-		return false, fmt.Errorf("unsupported string operator: %s", pc.Operator)
+		test = func(context.Context, string) (bool, error) {
+			return false, fmt.Errorf("unsupported string operator: %s", pc.Operator)
+		}
 	}
+
+	return attribute(pc.Attribute, func(ctx context.Context, attrVal any) (bool, error) {
+		// convert attribute and value to string using helper
+		as, err := utils.AnyToString(attrVal)
+		if err != nil {
+			return false, err
+		}
+		if valueErr != nil {
+			return false, valueErr
+		}
+		return test(ctx, as)
+	}), nil
 }
@@ -27,14 +27,14 @@ func TestStringHandler_Eval(t *testing.T) {
 		assert func(t *testing.T, expected, actual output)
 	}{
 		{
-			name: "when attribute not found should return false",
+			name: "when attribute not found should return a missing attribute error",
 			input: input{
 				pc:   policies.PolicyCondition{Attribute: "txt", Operator: policies.OpContains, Value: "a"},
 				attr: policies.MapAttributes{},
 			},
-			output: output{res: false, err: nil},
+			output: output{res: false, err: policies.ErrMissingAttribute},
 			assert: func(t *testing.T, expected, actual output) {
-				assert.NoError(t, actual.err)
+				assert.ErrorIs(t, actual.err, expected.err)
 				assert.Equal(t, expected.res, actual.res)
 			},
 		},
@@ -1,7 +1,9 @@
 package native
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/tavaresphil/go-policy-engine/pkg/policies"
 	"github.com/tavaresphil/go-policy-engine/pkg/utils"
@@ -13,26 +15,36 @@ func NewTemporalHandler() OperatorHandler {
 	return &TemporalHandler{}
 }
 func (h *TemporalHandler) Eval(pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
-	attrVal, ok := attr.Resolve(pc.Attribute)
-	if !ok {
-		return false, fmt.Errorf("missing required attribute: %s", pc.Attribute)
-	}
+	return h.EvalContext(context.Background(), pc, attr)
+}
 
-	attrTime, err := utils.AnyToTime(attrVal)
-	if err != nil {
-		return false, err
-	}
+func (h *TemporalHandler) EvalContext(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	return evalCompiled(ctx, h, pc, attr)
+}
 
-	valueTime, err := utils.AnyToTime(pc.Value)
-	if err != nil {
-		return false, err
-	}
+// Compile compiles pc, parsing its value as a time once.
+func (h *TemporalHandler) Compile(pc policies.PolicyCondition) (Predicate, error) {
+	valueTime, valueErr := utils.AnyToTime(pc.Value)
+
+	var test func(attrTime time.Time) bool
 	switch pc.Operator {
 	case policies.OpBefore:
-		return attrTime.Before(valueTime), nil
+		test = func(attrTime time.Time) bool { return attrTime.Before(valueTime) }
 	case policies.OpAfter:
-		return attrTime.After(valueTime), nil
-	default:
-		return false, fmt.Errorf("unsupported temporal operator: %s", pc.Operator)
+		test = func(attrTime time.Time) bool { return attrTime.After(valueTime) }
 	}
+
+	return attribute(pc.Attribute, func(_ context.Context, attrVal any) (bool, error) {
+		attrTime, err := utils.AnyToTime(attrVal)
+		if err != nil {
+			return false, err
+		}
+		if valueErr != nil {
+			return false, valueErr
+		}
+		if test == nil {
+			return false, fmt.Errorf("unsupported temporal operator: %s", pc.Operator)
+		}
+		return test(attrTime), nil
+	}), nil
 }
@@ -0,0 +1,144 @@
+package native
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// Trace records how a PolicyCondition was evaluated by Explain. The tree
+// mirrors the condition: every node holds the operator, the resolved attribute
+// value, the expected value, the result and the error of one condition.
+// Children not evaluated because a logical operator short-circuited are
+// marked as Skipped.
+type Trace struct {
+	Operator policies.Operator `json:"operator"`
+	// Attribute is the attribute of the condition, or its Expression
+	// rendered as a call.
+	Attribute string `json:"attribute,omitempty"`
+	// Value is the value resolved for Attribute and Resolved reports whether
+	// the attribute was found.
+	Value    any      `json:"value,omitempty"`
+	Resolved bool     `json:"resolved,omitempty"`
+	Expected any      `json:"expected,omitempty"`
+	Result   bool     `json:"result"`
+	Error    string   `json:"error,omitempty"`
+	Skipped  bool     `json:"skipped,omitempty"`
+	Children []*Trace `json:"children,omitempty"`
+}
+
+// String renders the trace as indented text, one condition per line.
+func (t *Trace) String() string {
+	var sb strings.Builder
+	t.write(&sb, 0)
+	return sb.String()
+}
+
+func (t *Trace) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(string(t.Operator))
+	if t.Attribute != "" {
+		fmt.Fprintf(sb, " %s", t.Attribute)
+		if !t.Skipped {
+			if t.Resolved {
+				fmt.Fprintf(sb, "=%#v", t.Value)
+			} else {
+				sb.WriteString("=<missing>")
+			}
+		}
+	}
+	if t.Expected != nil {
+		fmt.Fprintf(sb, " expected %#v", t.Expected)
+	}
+
+	switch {
+	case t.Skipped:
+		sb.WriteString(" (skipped)")
+	case t.Error != "":
+		fmt.Fprintf(sb, " => error: %s", t.Error)
+	default:
+		fmt.Fprintf(sb, " => %t", t.Result)
+	}
+	sb.WriteString("\n")
+
+	for _, child := range t.Children {
+		child.write(sb, depth+1)
+	}
+}
+
+// Explain evaluates pc like EvalContext and returns a Trace of the
+// evaluation together with the error EvalContext would have returned.
+func (e *NativeEngine) Explain(ctx context.Context, pc policies.PolicyCondition, attr policies.Resolver) (*Trace, error) {
+	root := &Trace{}
+	_, err := e.EvalContext(context.WithValue(ctx, traceKey{}, root), pc, attr)
+	return root.Children[0], err
+}
+
+type traceKey struct{}
+
+func traceFromContext(ctx context.Context) (*Trace, bool) {
+	t, ok := ctx.Value(traceKey{}).(*Trace)
+	return t, ok
+}
+
+func (e *NativeEngine) trace(ctx context.Context, parent *Trace, pc policies.PolicyCondition, attr policies.Resolver) (bool, error) {
+	node := &Trace{Operator: pc.Operator, Attribute: traced(pc), Expected: pc.Value}
+	parent.Children = append(parent.Children, node)
+
+	if pc.Attribute != "" {
+		attr = &recordingResolver{Resolver: attr, node: node}
+	}
+
+	ok, err := e.eval(context.WithValue(ctx, traceKey{}, node), pc, attr)
+	node.Result = ok
+	if err != nil {
+		node.Error = err.Error()
+	}
+
+	if len(node.Children) < len(pc.Conditions) {
+		for _, child := range pc.Conditions[len(node.Children):] {
+			node.Children = append(node.Children, &Trace{
+				Operator:  child.Operator,
+				Attribute: traced(child),
+				Expected:  child.Value,
+				Skipped:   true,
+			})
+		}
+	}
+	return ok, err
+}
+
+// traced returns the attribute of pc, or its expression rendered as a call.
+func traced(pc policies.PolicyCondition) string {
+	if pc.Expression != nil {
+		return pc.Expression.String()
+	}
+	return pc.Attribute
+}
+
+// recordingResolver stores the value resolved for the traced node attribute.
+type recordingResolver struct {
+	policies.Resolver
+	node *Trace
+}
+
+func (r *recordingResolver) Resolve(attribute string) (any, bool) {
+	v, ok := r.Resolver.Resolve(attribute)
+	r.record(attribute, v, ok)
+	return v, ok
+}
+
+func (r *recordingResolver) ResolveContext(ctx context.Context, attribute string) (any, bool, error) {
+	v, ok, err := policies.ResolveContext(ctx, r.Resolver, attribute)
+	r.record(attribute, v, ok)
+	return v, ok, err
+}
+
+func (r *recordingResolver) record(attribute string, v any, ok bool) {
+	if attribute == r.node.Attribute {
+		r.node.Value = v
+		r.node.Resolved = ok
+	}
+}
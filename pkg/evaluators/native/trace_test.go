@@ -0,0 +1,92 @@
+package native_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestNativeEngine_Explain(t *testing.T) {
+	cond := policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+		{Operator: policies.OpOr, Conditions: []policies.PolicyCondition{
+			{Attribute: "missing", Operator: policies.OpEqual, Value: 1},
+			{Attribute: "role", Operator: policies.OpEqual, Value: "admin"},
+		}},
+		{Attribute: "count", Operator: policies.OpGreater, Value: 10},
+		{Attribute: "name", Operator: policies.OpContains, Value: "x"},
+	}}
+	attr := policies.MapAttributes{"role": "admin", "count": 5, "name": "xyz"}
+	eng := native.NewNativeEngine().(*native.NativeEngine)
+
+	trace, err := eng.Explain(context.Background(), cond, attr)
+
+	assert.NoError(t, err)
+	assert.False(t, trace.Result)
+	if assert.Len(t, trace.Children, 3) {
+		or := trace.Children[0]
+		assert.True(t, or.Result)
+		if assert.Len(t, or.Children, 2) {
+			assert.Equal(t, "missing required attribute: missing", or.Children[0].Error)
+			assert.False(t, or.Children[0].Resolved)
+			assert.Equal(t, "admin", or.Children[1].Value)
+			assert.True(t, or.Children[1].Result)
+		}
+
+		gt := trace.Children[1]
+		assert.Equal(t, 5, gt.Value)
+		assert.Equal(t, 10, gt.Expected)
+		assert.False(t, gt.Result)
+
+		assert.True(t, trace.Children[2].Skipped)
+	}
+
+	assert.Equal(t, `and => false
+  or => true
+    eq missing=<missing> expected 1 => error: missing required attribute: missing
+    eq role="admin" expected "admin" => true
+  gt count=5 expected 10 => false
+  contains name expected "x" (skipped)
+`, trace.String())
+
+	b, err := json.Marshal(trace.Children[1])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"operator":"gt","attribute":"count","value":5,"resolved":true,"expected":10,"result":false}`, string(b))
+}
+
+func TestNativeEngine_Explain_Expression(t *testing.T) {
+	cond := policies.PolicyCondition{
+		Expression: policies.Arith(policies.ArithMul, policies.Ref("price"), policies.Ref("quantity")),
+		Operator:   policies.OpLessOrEqual,
+		Value:      policies.Arith(policies.ArithAdd, policies.Ref("limit"), 1),
+	}
+	attr := policies.MapAttributes{"price": 2, "quantity": 5, "limit": 9}
+	eng := native.NewNativeEngine().(*native.NativeEngine)
+
+	trace, err := eng.Explain(context.Background(), cond, attr)
+
+	assert.NoError(t, err)
+	assert.True(t, trace.Result)
+	assert.Equal(t, "lte mul(price, quantity)=10 expected 10 => true\n", trace.String())
+
+	delete(attr, "quantity")
+	trace, err = eng.Explain(context.Background(), cond, attr)
+
+	assert.EqualError(t, err, "missing required attribute: quantity")
+	assert.False(t, trace.Resolved)
+	assert.Contains(t, trace.String(), "lte mul(price, quantity)=<missing>")
+}
+
+func TestNativeEngine_Explain_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	eng := native.NewNativeEngine().(*native.NativeEngine)
+
+	trace, err := eng.Explain(ctx, policies.PolicyCondition{Attribute: "a", Operator: policies.OpEqual, Value: 1}, policies.MapAttributes{})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "context canceled", trace.Error)
+}
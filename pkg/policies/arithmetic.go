@@ -0,0 +1,242 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+// ArithmeticOp is the operation of an Arithmetic expression.
+type ArithmeticOp string
+
+const (
+	ArithAdd ArithmeticOp = "add"
+	ArithSub ArithmeticOp = "sub"
+	ArithMul ArithmeticOp = "mul"
+	ArithDiv ArithmeticOp = "div"
+	ArithMod ArithmeticOp = "mod"
+	ArithMin ArithmeticOp = "min"
+	ArithMax ArithmeticOp = "max"
+	ArithAbs ArithmeticOp = "abs"
+)
+
+// arithmeticArity maps every operation to its minimum and maximum number of
+// arguments; -1 is unbounded. Operations taking more than two arguments fold
+// them from the left.
+var arithmeticArity = map[ArithmeticOp][2]int{
+	ArithAdd: {2, -1},
+	ArithSub: {2, 2},
+	ArithMul: {2, -1},
+	ArithDiv: {2, 2},
+	ArithMod: {2, 2},
+	ArithMin: {2, -1},
+	ArithMax: {2, -1},
+	ArithAbs: {1, 1},
+}
+
+// Apply applies op to args with the functions of package utils, exactly when
+// every argument is an integer or a decimal.
+func (op ArithmeticOp) Apply(args ...any) (any, error) {
+	if err := op.validate(len(args)); err != nil {
+		return nil, err
+	}
+	if op == ArithAbs {
+		v, err := utils.Abs(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return v, nil
+	}
+
+	var fn func(a, b any) (any, error)
+	switch op {
+	case ArithAdd:
+		fn = utils.Add
+	case ArithSub:
+		fn = utils.Sub
+	case ArithMul:
+		fn = utils.Mul
+	case ArithDiv:
+		fn = utils.Div
+	case ArithMod:
+		fn = utils.Mod
+	case ArithMin:
+		fn = utils.Min
+	case ArithMax:
+		fn = utils.Max
+	}
+
+	acc := args[0]
+	for _, arg := range args[1:] {
+		v, err := fn(acc, arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		acc = v
+	}
+	return acc, nil
+}
+
+func (op ArithmeticOp) validate(n int) error {
+	arity, ok := arithmeticArity[op]
+	if !ok {
+		return fmt.Errorf("unknown arithmetic operation: %q", op)
+	}
+	if n < arity[0] || arity[1] != -1 && n > arity[1] {
+		if arity[0] == arity[1] {
+			return fmt.Errorf("arithmetic operation %s expects %d args", op, arity[0])
+		}
+		return fmt.Errorf("arithmetic operation %s expects at least %d args", op, arity[0])
+	}
+	return nil
+}
+
+// Arithmetic is a value computed from numbers and attributes, such as the
+// total of an order. It may appear as a condition value, anywhere an
+// AttributeRef may, and as the Expression of a condition, in place of its
+// Attribute:
+//
+//	{"expression": {"op": "mul", "args": [{"ref": "request.amount"}, {"ref": "request.quantity"}]},
+//	 "operator": "lte", "value": {"ref": "subject.limit"}}
+//
+// Arguments are numbers, references and other Arithmetic expressions.
+type Arithmetic struct {
+	Op   ArithmeticOp `json:"op"`
+	Args []any        `json:"args"`
+}
+
+// Arith returns the Arithmetic expression applying op to args.
+func Arith(op ArithmeticOp, args ...any) *Arithmetic {
+	return &Arithmetic{Op: op, Args: args}
+}
+
+// ArithmeticOf reports whether v is an Arithmetic expression and returns it.
+// It recognises Arithmetic, *Arithmetic and the map[string]any{"op": op,
+// "args": args} form produced by decoding JSON.
+func ArithmeticOf(v any) (*Arithmetic, bool) {
+	switch t := v.(type) {
+	case Arithmetic:
+		return &t, true
+	case *Arithmetic:
+		return t, t != nil
+	case map[string]any:
+		if len(t) != 2 {
+			return nil, false
+		}
+		op, ok := t["op"].(string)
+		if !ok {
+			return nil, false
+		}
+		args, ok := t["args"].([]any)
+		if !ok {
+			return nil, false
+		}
+		return &Arithmetic{Op: ArithmeticOp(op), Args: args}, true
+	default:
+		return nil, false
+	}
+}
+
+// Validate verifies that the operation of a exists, takes as many arguments
+// and that its arguments are valid.
+func (a *Arithmetic) Validate() error {
+	if err := a.Op.validate(len(a.Args)); err != nil {
+		return err
+	}
+	for _, arg := range a.Args {
+		if _, ok := RefOf(arg); !ok {
+			if _, ok := ArithmeticOf(arg); !ok && !isArithmeticLiteral(arg) {
+				return fmt.Errorf("arithmetic operation %s: invalid argument %v", a.Op, arg)
+			}
+		}
+		if err := validateRefs(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isArithmeticLiteral reports whether v may be an argument of an Arithmetic
+// expression as is.
+func isArithmeticLiteral(v any) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Ptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// Clone returns a deep copy of a.
+func (a *Arithmetic) Clone() *Arithmetic {
+	clone := *a
+	if args, ok := cloneValue(a.Args).([]any); ok {
+		clone.Args = args
+	}
+	return &clone
+}
+
+// Paths returns the paths of the attributes a refers to.
+func (a *Arithmetic) Paths() []string {
+	return RefPaths(a.Args)
+}
+
+// Eval returns the value of a, resolving its references with r. As for
+// ResolveRefs, a reference to a missing attribute is an error.
+func (a *Arithmetic) Eval(ctx context.Context, r Resolver) (any, error) {
+	args := make([]any, len(a.Args))
+	for i, arg := range a.Args {
+		v, err := ResolveRefs(ctx, arg, r)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return a.Op.Apply(args...)
+}
+
+// String renders a as a call, such as "mul(request.amount, 2)".
+func (a *Arithmetic) String() string {
+	args := make([]string, len(a.Args))
+	for i, arg := range a.Args {
+		if path, ok := RefOf(arg); ok {
+			args[i] = path
+		} else if x, ok := ArithmeticOf(arg); ok {
+			args[i] = x.String()
+		} else {
+			args[i] = fmt.Sprint(arg)
+		}
+	}
+	return fmt.Sprintf("%s(%s)", a.Op, strings.Join(args, ", "))
+}
+
+// ParseModValue parses the Value of a "mod" condition, which can be:
+// - a divisor, for a remainder of zero
+// - a slice/array with 2 elements: [divisor, remainder]
+func ParseModValue(v any) (divisor any, remainder any, err error) {
+	if v == nil {
+		return nil, nil, fmt.Errorf("mod requires a divisor")
+	}
+	if _, ok := RefOf(v); ok {
+		return v, 0, nil
+	}
+	if _, ok := ArithmeticOf(v); ok {
+		return v, 0, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() != 2 {
+			return nil, nil, fmt.Errorf("mod requires 2 args: divisor, remainder")
+		}
+		return rv.Index(0).Interface(), rv.Index(1).Interface(), nil
+	default:
+		return v, 0, nil
+	}
+}
@@ -0,0 +1,117 @@
+package policies_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestArithmeticOp_Apply(t *testing.T) {
+	tests := []struct {
+		name string
+		op   policies.ArithmeticOp
+		args []any
+		out  any
+		err  string
+	}{
+		{name: "add ints", op: policies.ArithAdd, args: []any{1, 2, 3}, out: int64(6)},
+		{name: "add decimals", op: policies.ArithAdd, args: []any{json.Number("0.1"), "0.2"}, out: big.NewRat(3, 10)},
+		{name: "add float", op: policies.ArithAdd, args: []any{1, 0.5}, out: 1.5},
+		{name: "sub", op: policies.ArithSub, args: []any{1, 3}, out: int64(-2)},
+		{name: "mul", op: policies.ArithMul, args: []any{json.Number("19.99"), 3}, out: big.NewRat(5997, 100)},
+		{name: "div", op: policies.ArithDiv, args: []any{1, 3}, out: big.NewRat(1, 3)},
+		{name: "div by zero", op: policies.ArithDiv, args: []any{1, 0}, err: "div: division by zero"},
+		{name: "mod", op: policies.ArithMod, args: []any{-7, 5}, out: int64(-2)},
+		{name: "min keeps argument", op: policies.ArithMin, args: []any{"10", 2.5, json.Number("3")}, out: 2.5},
+		{name: "max keeps argument", op: policies.ArithMax, args: []any{"10", 2.5}, out: "10"},
+		{name: "abs", op: policies.ArithAbs, args: []any{json.Number("-1.5")}, out: big.NewRat(3, 2)},
+		{name: "arity", op: policies.ArithSub, args: []any{1, 2, 3}, err: "arithmetic operation sub expects 2 args"},
+		{name: "unknown", op: "pow", args: []any{1, 2}, err: `unknown arithmetic operation: "pow"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tt.op.Apply(tt.args...)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
+
+func TestArithmetic_Eval(t *testing.T) {
+	attr := policies.MapAttributes{"order": map[string]any{"amount": json.Number("19.99"), "quantity": 3}}
+	x := policies.Arith(policies.ArithSub,
+		policies.Arith(policies.ArithMul, policies.Ref("order.amount"), policies.Ref("order.quantity")),
+		json.Number("0.97"))
+
+	out, err := x.Eval(context.Background(), attr)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(59), out)
+	assert.Equal(t, "sub(mul(order.amount, order.quantity), 0.97)", x.String())
+	assert.Equal(t, []string{"order.amount", "order.quantity"}, x.Paths())
+
+	_, err = policies.Arith(policies.ArithAdd, policies.Ref("order.other"), 1).Eval(context.Background(), attr)
+	assert.EqualError(t, err, "missing referenced attribute: order.other")
+}
+
+func TestPolicyCondition_Validate_Expression(t *testing.T) {
+	var cond policies.PolicyCondition
+	err := json.Unmarshal([]byte(`{"expression":{"op":"mul","args":[{"ref":"request.amount"},{"ref":"request.quantity"}]},"operator":"lte","value":{"op":"add","args":[{"ref":"subject.limit"},10]}}`), &cond)
+	assert.NoError(t, err)
+	assert.NoError(t, cond.Validate())
+	assert.Equal(t, policies.Arith(policies.ArithMul, map[string]any{"ref": "request.amount"}, map[string]any{"ref": "request.quantity"}), cond.Expression)
+
+	cond.Value = policies.Arith(policies.ArithAbs, 1, 2)
+	assert.EqualError(t, cond.Validate(), "arithmetic operation abs expects 1 args")
+
+	cond.Value = policies.Arith(policies.ArithAdd, policies.Ref("a..b"), 1)
+	assert.EqualError(t, cond.Validate(), `invalid attribute reference: "a..b"`)
+
+	cond.Value = policies.Arith(policies.ArithAdd, true, 1)
+	assert.EqualError(t, cond.Validate(), "arithmetic operation add: invalid argument true")
+
+	cond.Value = 1
+	cond.Attribute = "request.total"
+	assert.EqualError(t, cond.Validate(), "operator lte requires attribute or expression, not both")
+
+	cond.Attribute = ""
+	cond.Operator = policies.OpExists
+	assert.EqualError(t, cond.Validate(), "operator exists does not support expressions")
+}
+
+func TestParseModValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        any
+		divisor   any
+		remainder any
+		err       string
+	}{
+		{name: "divisor", in: 5, divisor: 5, remainder: 0},
+		{name: "divisor and remainder", in: []any{5, 2}, divisor: 5, remainder: 2},
+		{name: "reference", in: policies.Ref("n"), divisor: policies.Ref("n"), remainder: 0},
+		{name: "too many", in: []any{5, 2, 1}, err: "mod requires 2 args: divisor, remainder"},
+		{name: "nil", in: nil, err: "mod requires a divisor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			divisor, remainder, err := policies.ParseModValue(tt.in)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.divisor, divisor)
+			assert.Equal(t, tt.remainder, remainder)
+		})
+	}
+}
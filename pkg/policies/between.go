@@ -0,0 +1,58 @@
+package policies
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ParseBetweenValue parses the Value of a "between" condition, which can be:
+// - a slice/array with 2 elements: [min, max]
+// - a slice/array with 3 elements: [min, max, inclusive(bool)]
+// - a map[string]any with keys "min","max","inclusive"
+func ParseBetweenValue(v any) (min any, max any, inclusive bool, err error) {
+	inclusive = true // default inclusive
+	if v == nil {
+		return nil, nil, false, fmt.Errorf("between requires min and max")
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() < 2 || rv.Len() > 3 {
+			return nil, nil, false, fmt.Errorf("between requires 2 or 3 args: min, max, (inclusive)")
+		}
+		min = rv.Index(0).Interface()
+		max = rv.Index(1).Interface()
+		if rv.Len() == 3 {
+			incVal := rv.Index(2).Interface()
+			b, ok := incVal.(bool)
+			if !ok {
+				return nil, nil, false, fmt.Errorf("inclusive flag must be a boolean")
+			}
+			inclusive = b
+		}
+		return
+	case reflect.Map:
+		// try map[string]any
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, nil, false, fmt.Errorf("unsupported between value map type: %T", v)
+		}
+		var okMin, okMax bool
+		min, okMin = m["min"]
+		max, okMax = m["max"]
+		if !okMin || !okMax {
+			return nil, nil, false, fmt.Errorf("between requires min and max in map form")
+		}
+		if inc, ok := m["inclusive"]; ok {
+			b, ok := inc.(bool)
+			if !ok {
+				return nil, nil, false, fmt.Errorf("inclusive flag must be a boolean")
+			}
+			inclusive = b
+		}
+		return
+	default:
+		return nil, nil, false, fmt.Errorf("unsupported between value type: %v", rv.Kind())
+	}
+}
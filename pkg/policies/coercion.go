@@ -0,0 +1,22 @@
+package policies
+
+import (
+	"context"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+type coercionKey struct{}
+
+// ContextWithCoercion returns a copy of ctx carrying c. Engines set it before
+// calling handlers, which compare values with utils.Equal and utils.Compare
+// under the coercion returned by CoercionOf.
+func ContextWithCoercion(ctx context.Context, c utils.Coercion) context.Context {
+	return context.WithValue(ctx, coercionKey{}, c)
+}
+
+// CoercionOf returns the coercion carried by ctx, or utils.CoerceNumbers.
+func CoercionOf(ctx context.Context) utils.Coercion {
+	c, _ := ctx.Value(coercionKey{}).(utils.Coercion)
+	return c
+}
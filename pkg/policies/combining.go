@@ -0,0 +1,165 @@
+package policies
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrMultipleApplicable is reported by OnlyOneApplicable when more than one
+// policy applies to a request.
+var ErrMultipleApplicable = errors.New("more than one policy is applicable")
+
+// CombiningAlgorithm combines the results of the enforcing policies evaluated
+// for a request into a single Decision. Results are given in repository order.
+//
+// Except for RequireAll, algorithms treat a policy as applicable when its
+// condition matches, in which case its effect is its outcome.
+//
+// Results flagged Indeterminate or carrying an Err are left to the
+// algorithm. The built-in ones fail closed: such a deny policy is treated as
+// if it matched and such an allow policy as if it did not.
+type CombiningAlgorithm interface {
+	Combine(results []PolicyResult) Decision
+}
+
+// CombiningFunc adapts a function to the CombiningAlgorithm interface.
+type CombiningFunc func(results []PolicyResult) Decision
+
+// Combine calls f(results).
+func (f CombiningFunc) Combine(results []PolicyResult) Decision {
+	return f(results)
+}
+
+var (
+	// RequireAll requires every policy to allow the request: deny policies
+	// must not match and allow policies must match. The first policy that
+	// blocks the request decides. This is the Evaluator's default.
+	RequireAll CombiningAlgorithm = CombiningFunc(requireAll)
+	// DenyOverrides denies if any applicable policy denies, otherwise allows
+	// if any applicable policy allows.
+	DenyOverrides CombiningAlgorithm = CombiningFunc(denyOverrides)
+	// PermitOverrides allows if any applicable policy allows, otherwise denies
+	// if any applicable policy denies.
+	PermitOverrides CombiningAlgorithm = CombiningFunc(permitOverrides)
+	// FirstApplicable returns the outcome of the first applicable policy.
+	FirstApplicable CombiningAlgorithm = CombiningFunc(firstApplicable)
+	// PriorityOrdered returns the outcome of the applicable policy with the
+	// highest Policy.Priority, keeping repository order between equal
+	// priorities.
+	PriorityOrdered CombiningAlgorithm = CombiningFunc(priorityOrdered)
+	// OnlyOneApplicable returns the outcome of the single applicable policy
+	// and denies with ErrMultipleApplicable when more than one applies.
+	OnlyOneApplicable CombiningAlgorithm = CombiningFunc(onlyOneApplicable)
+)
+
+var combiningRegistry = map[string]CombiningAlgorithm{
+	"require-all":         RequireAll,
+	"deny-overrides":      DenyOverrides,
+	"permit-overrides":    PermitOverrides,
+	"first-applicable":    FirstApplicable,
+	"priority-ordered":    PriorityOrdered,
+	"only-one-applicable": OnlyOneApplicable,
+}
+
+// CombiningAlgorithmOf returns the built-in CombiningAlgorithm registered
+// under name (for example "deny-overrides") and whether it exists.
+func CombiningAlgorithmOf(name string) (CombiningAlgorithm, bool) {
+	alg, ok := combiningRegistry[name]
+	return alg, ok
+}
+
+func newDecision(results []PolicyResult) Decision {
+	return Decision{Outcome: OutcomeNotApplicable, Policies: results}
+}
+
+// applicable returns the outcome of r, failing closed on deny policies that
+// are indeterminate or failed to evaluate.
+func applicable(r PolicyResult) Outcome {
+	if (r.Indeterminate || r.Err != nil) && r.Effect == EffectDeny {
+		return OutcomeDeny
+	}
+	return r.Outcome
+}
+
+func requireAll(results []PolicyResult) Decision {
+	d := newDecision(results)
+	for _, r := range results {
+		d.decide(r)
+		if applicable(r) == OutcomeDeny || r.Effect == EffectAllow && !r.Matched {
+			d.Outcome = OutcomeDeny
+			return d
+		}
+		d.Outcome = OutcomeAllow
+	}
+	return d
+}
+
+func denyOverrides(results []PolicyResult) Decision {
+	return overrides(results, OutcomeDeny)
+}
+
+func permitOverrides(results []PolicyResult) Decision {
+	return overrides(results, OutcomeAllow)
+}
+
+func overrides(results []PolicyResult, winner Outcome) Decision {
+	d := newDecision(results)
+	for _, r := range results {
+		switch applicable(r) {
+		case winner:
+			d.decide(r)
+			return d
+		case OutcomeNotApplicable:
+		default:
+			if d.Outcome == OutcomeNotApplicable {
+				d.decide(r)
+			}
+		}
+	}
+	return d
+}
+
+func firstApplicable(results []PolicyResult) Decision {
+	d := newDecision(results)
+	for _, r := range results {
+		if applicable(r) != OutcomeNotApplicable {
+			d.decide(r)
+			return d
+		}
+	}
+	return d
+}
+
+func priorityOrdered(results []PolicyResult) Decision {
+	ordered := make([]PolicyResult, len(results))
+	copy(ordered, results)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
+
+	d := firstApplicable(ordered)
+	d.Policies = results
+	return d
+}
+
+func onlyOneApplicable(results []PolicyResult) Decision {
+	d := newDecision(results)
+	var ids []string
+	for _, r := range results {
+		if applicable(r) == OutcomeNotApplicable {
+			continue
+		}
+		ids = append(ids, r.PolicyID)
+		d.decide(r)
+	}
+
+	if len(ids) > 1 {
+		d.Outcome = OutcomeDeny
+		d.PolicyID = ""
+		d.Version = ""
+		d.Errors = append(d.Errors, fmt.Errorf("%w: %s", ErrMultipleApplicable, strings.Join(ids, ", ")))
+	}
+	return d
+}
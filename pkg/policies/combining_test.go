@@ -0,0 +1,166 @@
+package policies_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+func TestCombiningAlgorithms(t *testing.T) {
+	allowAdmin := testPolicy("allow-admin", policies.EffectAllow, eqCond("role", "admin"))
+	allowGuest := testPolicy("allow-guest", policies.EffectAllow, eqCond("role", "guest"))
+	denyBlocked := testPolicy("deny-blocked", policies.EffectDeny, eqCond("blocked", true))
+	denyAdmin := testPolicy("deny-admin", policies.EffectDeny, eqCond("role", "admin"))
+	allowAdminUrgent := testPolicy("allow-admin-urgent", policies.EffectAllow, eqCond("role", "admin"))
+	allowAdminUrgent.ExplicitPriority = utils.Ptr(1000)
+
+	type input struct {
+		alg   policies.CombiningAlgorithm
+		pols  []policies.Policy
+		attrs policies.MapAttributes
+	}
+	type output struct {
+		outcome  policies.Outcome
+		policyID string
+	}
+
+	tests := []struct {
+		name   string
+		input  input
+		output output
+	}{
+		{
+			name:   "require all denies when an allow policy does not match",
+			input:  input{alg: policies.RequireAll, pols: []policies.Policy{allowGuest, denyBlocked}, attrs: policies.MapAttributes{"role": "admin", "blocked": false}},
+			output: output{outcome: policies.OutcomeDeny, policyID: "allow-guest"},
+		},
+		{
+			name:   "deny overrides prefers deny",
+			input:  input{alg: policies.DenyOverrides, pols: []policies.Policy{allowAdmin, denyAdmin}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeDeny, policyID: "deny-admin"},
+		},
+		{
+			name:   "deny overrides allows when only allow applies",
+			input:  input{alg: policies.DenyOverrides, pols: []policies.Policy{allowGuest, allowAdmin, denyBlocked}, attrs: policies.MapAttributes{"role": "admin", "blocked": false}},
+			output: output{outcome: policies.OutcomeAllow, policyID: "allow-admin"},
+		},
+		{
+			name:   "permit overrides prefers allow",
+			input:  input{alg: policies.PermitOverrides, pols: []policies.Policy{denyAdmin, allowAdmin}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeAllow, policyID: "allow-admin"},
+		},
+		{
+			name:   "permit overrides is not applicable when nothing matches",
+			input:  input{alg: policies.PermitOverrides, pols: []policies.Policy{allowGuest}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeNotApplicable},
+		},
+		{
+			name:   "first applicable keeps repository order",
+			input:  input{alg: policies.FirstApplicable, pols: []policies.Policy{allowGuest, allowAdmin, denyAdmin}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeAllow, policyID: "allow-admin"},
+		},
+		{
+			name:   "priority ordered uses the computed priority",
+			input:  input{alg: policies.PriorityOrdered, pols: []policies.Policy{allowAdmin, denyAdmin}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeDeny, policyID: "deny-admin"},
+		},
+		{
+			name:   "priority ordered honours the explicit priority",
+			input:  input{alg: policies.PriorityOrdered, pols: []policies.Policy{denyAdmin, allowAdminUrgent}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeAllow, policyID: "allow-admin-urgent"},
+		},
+		{
+			name:   "only one applicable returns the single match",
+			input:  input{alg: policies.OnlyOneApplicable, pols: []policies.Policy{allowGuest, allowAdmin}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeAllow, policyID: "allow-admin"},
+		},
+		{
+			name:   "only one applicable denies on several matches",
+			input:  input{alg: policies.OnlyOneApplicable, pols: []policies.Policy{allowAdmin, denyAdmin}, attrs: policies.MapAttributes{"role": "admin"}},
+			output: output{outcome: policies.OutcomeDeny},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := policies.NewEvaluator(native.NewNativeEngine(), staticRepo(tt.input.pols...),
+				policies.WithCombiningAlgorithm(tt.input.alg))
+
+			d, err := ev.Eval(context.Background(), policies.EvaluatorRequest{Resource: "doc", Context: tt.input.attrs})
+
+			assert.Equal(t, tt.output.outcome, d.Outcome)
+			assert.Equal(t, tt.output.policyID, d.PolicyID)
+			assert.Equal(t, tt.output.outcome == policies.OutcomeDeny, policies.IsDenied(err))
+			assert.Len(t, d.Policies, len(tt.input.pols))
+		})
+	}
+}
+
+func TestOnlyOneApplicable_ReportsConflict(t *testing.T) {
+	d := policies.OnlyOneApplicable.Combine([]policies.PolicyResult{
+		{PolicyID: "a", Effect: policies.EffectAllow, Matched: true, Outcome: policies.OutcomeAllow},
+		{PolicyID: "b", Effect: policies.EffectDeny, Matched: true, Outcome: policies.OutcomeDeny},
+	})
+
+	if assert.Len(t, d.Errors, 1) {
+		assert.ErrorIs(t, d.Errors[0], policies.ErrMultipleApplicable)
+		assert.Contains(t, d.Errors[0].Error(), "a, b")
+	}
+}
+
+func TestCombiningAlgorithms_Indeterminate(t *testing.T) {
+	allow := policies.PolicyResult{PolicyID: "allow", Effect: policies.EffectAllow, Matched: true, Outcome: policies.OutcomeAllow}
+	unknownAllow := policies.PolicyResult{PolicyID: "unknown-allow", Effect: policies.EffectAllow, Outcome: policies.OutcomeNotApplicable, Indeterminate: true}
+	unknownDeny := policies.PolicyResult{PolicyID: "unknown-deny", Effect: policies.EffectDeny, Outcome: policies.OutcomeNotApplicable, Indeterminate: true}
+	failedAllow := policies.PolicyResult{PolicyID: "failed-allow", Effect: policies.EffectAllow, Outcome: policies.OutcomeNotApplicable, Err: errors.New("boom")}
+	failedDeny := policies.PolicyResult{PolicyID: "failed-deny", Effect: policies.EffectDeny, Outcome: policies.OutcomeNotApplicable, Err: errors.New("boom")}
+	urgentUnknownDeny := unknownDeny
+	urgentUnknownDeny.Priority = 10
+
+	tests := []struct {
+		name     string
+		alg      policies.CombiningAlgorithm
+		results  []policies.PolicyResult
+		outcome  policies.Outcome
+		policyID string
+	}{
+		{name: "require all denies on an indeterminate deny policy", alg: policies.RequireAll, results: []policies.PolicyResult{allow, unknownDeny}, outcome: policies.OutcomeDeny, policyID: "unknown-deny"},
+		{name: "require all denies on an indeterminate allow policy", alg: policies.RequireAll, results: []policies.PolicyResult{unknownAllow, allow}, outcome: policies.OutcomeDeny, policyID: "unknown-allow"},
+		{name: "deny overrides denies on an indeterminate deny policy", alg: policies.DenyOverrides, results: []policies.PolicyResult{allow, unknownDeny}, outcome: policies.OutcomeDeny, policyID: "unknown-deny"},
+		{name: "permit overrides still prefers allow", alg: policies.PermitOverrides, results: []policies.PolicyResult{unknownDeny, allow}, outcome: policies.OutcomeAllow, policyID: "allow"},
+		{name: "permit overrides ignores an indeterminate allow policy", alg: policies.PermitOverrides, results: []policies.PolicyResult{unknownAllow}, outcome: policies.OutcomeNotApplicable},
+		{name: "first applicable skips an indeterminate allow policy", alg: policies.FirstApplicable, results: []policies.PolicyResult{unknownAllow, allow}, outcome: policies.OutcomeAllow, policyID: "allow"},
+		{name: "deny overrides ignores an indeterminate allow policy", alg: policies.DenyOverrides, results: []policies.PolicyResult{unknownAllow}, outcome: policies.OutcomeNotApplicable},
+		{name: "permit overrides denies on an indeterminate deny policy", alg: policies.PermitOverrides, results: []policies.PolicyResult{unknownAllow, unknownDeny}, outcome: policies.OutcomeDeny, policyID: "unknown-deny"},
+		{name: "first applicable denies on an indeterminate deny policy", alg: policies.FirstApplicable, results: []policies.PolicyResult{unknownDeny, allow}, outcome: policies.OutcomeDeny, policyID: "unknown-deny"},
+		{name: "priority ordered denies on an indeterminate deny policy", alg: policies.PriorityOrdered, results: []policies.PolicyResult{allow, urgentUnknownDeny}, outcome: policies.OutcomeDeny, policyID: "unknown-deny"},
+		{name: "priority ordered skips an indeterminate allow policy", alg: policies.PriorityOrdered, results: []policies.PolicyResult{unknownAllow, allow}, outcome: policies.OutcomeAllow, policyID: "allow"},
+		{name: "only one applicable denies on an indeterminate deny policy", alg: policies.OnlyOneApplicable, results: []policies.PolicyResult{unknownAllow, unknownDeny}, outcome: policies.OutcomeDeny, policyID: "unknown-deny"},
+		{name: "deny overrides denies on a failed deny policy", alg: policies.DenyOverrides, results: []policies.PolicyResult{allow, failedDeny}, outcome: policies.OutcomeDeny, policyID: "failed-deny"},
+		{name: "permit overrides ignores a failed allow policy", alg: policies.PermitOverrides, results: []policies.PolicyResult{failedAllow, allow}, outcome: policies.OutcomeAllow, policyID: "allow"},
+		{name: "only one applicable ignores an indeterminate allow policy", alg: policies.OnlyOneApplicable, results: []policies.PolicyResult{unknownAllow, allow}, outcome: policies.OutcomeAllow, policyID: "allow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.alg.Combine(tt.results)
+
+			assert.Equal(t, tt.outcome, d.Outcome)
+			assert.Equal(t, tt.policyID, d.PolicyID)
+		})
+	}
+}
+
+func TestCombiningAlgorithmOf(t *testing.T) {
+	alg, ok := policies.CombiningAlgorithmOf("deny-overrides")
+	assert.True(t, ok)
+	assert.NotNil(t, alg)
+
+	_, ok = policies.CombiningAlgorithmOf("unknown")
+	assert.False(t, ok)
+}
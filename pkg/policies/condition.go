@@ -1,13 +1,24 @@
 package policies
 
-import "fmt"
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
 
 // PolicyCondition represents a condition used in a policy. It is either
 // a leaf condition (defined by Attribute, Operator and Value) or a logical
 // compound condition containing child Conditions (used for operators like
-// "and", "or" and "not").
+// "and", "or" and "not"). A leaf condition may test the value of an
+// Arithmetic Expression instead of an Attribute; it follows the
+// MissingAttributes of the engine when an attribute of the expression is
+// missing.
 type PolicyCondition struct {
 	Attribute  string            `json:"attribute"`
+	Expression *Arithmetic       `json:"expression,omitempty"`
 	Operator   Operator          `json:"operator"`
 	Value      any               `json:"value"`
 	Conditions []PolicyCondition `json:"conditions"`
@@ -15,8 +26,10 @@ type PolicyCondition struct {
 
 // Validate verifies that the condition is well-formed for the configured
 // operator. It checks operator existence, arity for logical operators and
-// presence of Attribute/Value for non-logical operators and validates
-// child conditions recursively.
+// presence of Attribute or Expression and Value for non-logical operators
+// and validates child conditions recursively. Value is optional for
+// operators whose spec takes a single argument. Attribute references in
+// Value must name a path.
 func (c PolicyCondition) Validate() error {
 	spec, ok := OperatorSpecOf(c.Operator)
 	if !ok {
@@ -38,13 +51,119 @@ func (c PolicyCondition) Validate() error {
 		return nil
 	}
 
-	if c.Attribute == "" {
+	if c.Expression != nil {
+		if c.Attribute != "" {
+			return fmt.Errorf("operator %s requires attribute or expression, not both", c.Operator)
+		}
+		if spec.Kind == KindPresence {
+			return fmt.Errorf("operator %s does not support expressions", c.Operator)
+		}
+		if err := c.Expression.Validate(); err != nil {
+			return err
+		}
+	} else if c.Attribute == "" {
 		return fmt.Errorf("operator %s requires attribute", c.Operator)
 	}
 
-	if c.Value == nil {
+	if c.Value == nil && spec.MinArgs > 1 {
 		return fmt.Errorf("operator %s requires value", c.Operator)
 	}
 
-	return nil
+	return validateRefs(c.Value)
+}
+
+// Clone returns a deep copy of the condition: its Expression, child
+// Conditions and the slices and maps of its Value are copied, so that
+// modifying them leaves c unchanged. Other pointers in Value are shared.
+func (c PolicyCondition) Clone() PolicyCondition {
+	clone := c
+	if c.Expression != nil {
+		clone.Expression = c.Expression.Clone()
+	}
+	clone.Value = cloneValue(c.Value)
+	if c.Conditions != nil {
+		clone.Conditions = make([]PolicyCondition, len(c.Conditions))
+		for i, child := range c.Conditions {
+			clone.Conditions[i] = child.Clone()
+		}
+	}
+	return clone
+}
+
+// cloneValue returns a deep copy of the slices, maps and Arithmetic
+// expressions of v.
+func cloneValue(v any) any {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case *Arithmetic:
+		if t == nil {
+			return t
+		}
+		return t.Clone()
+	case Arithmetic:
+		return *t.Clone()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if elem := cloneValue(rv.Index(i).Interface()); elem != nil {
+				out.Index(i).Set(reflect.ValueOf(elem))
+			}
+		}
+		return out.Interface()
+	case reflect.Map:
+		if rv.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			elem := reflect.Zero(rv.Type().Elem())
+			if c := cloneValue(iter.Value().Interface()); c != nil {
+				elem = reflect.ValueOf(c)
+			}
+			out.SetMapIndex(iter.Key(), elem)
+		}
+		return out.Interface()
+	default:
+		return v
+	}
+}
+
+type conditionKeyKey struct{}
+
+// ContextWithConditionKey returns a copy of ctx telling engines that the
+// condition they evaluate is identified by key, so that engines caching
+// compiled conditions look it up by key rather than by encoding its content.
+// Conditions with different content must never share a key. An empty key
+// clears the one of ctx.
+func ContextWithConditionKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, conditionKeyKey{}, key)
+}
+
+// ConditionKey returns the condition key carried by ctx, if any.
+func ConditionKey(ctx context.Context) (string, bool) {
+	key, _ := ctx.Value(conditionKeyKey{}).(string)
+	return key, key != ""
+}
+
+// Fingerprint returns a stable hash of the condition, identifying it across
+// processes. It is computed from the JSON encoding, so values with the same
+// JSON representation share a fingerprint: int 5, float64 5 and
+// json.Number("5") do, although engines may compare them differently. It is
+// therefore not a cache key for compiled forms of the condition.
+func (c PolicyCondition) Fingerprint() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("fingerprint condition: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
 }
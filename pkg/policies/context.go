@@ -1,8 +1,10 @@
 package policies
 
 import (
+	"context"
 	"reflect"
 	"strings"
+	"sync"
 )
 
 // Resolver resolves attribute values by name. The attribute name may be a dotted path
@@ -15,6 +17,87 @@ type Resolver interface {
 	Resolve(attribute string) (any, bool)
 }
 
+// ContextResolver is a Resolver whose lookups honour the cancellation and
+// deadline of a context.Context, for example when attributes are fetched from
+// a remote service. Lookup failures are reported through the error.
+type ContextResolver interface {
+	Resolver
+	ResolveContext(ctx context.Context, attribute string) (any, bool, error)
+}
+
+// ResolveContext resolves attribute with r, using ResolveContext when r is a
+// ContextResolver. For plain Resolvers it returns ctx.Err() if ctx is done
+// before the lookup.
+func ResolveContext(ctx context.Context, r Resolver, attribute string) (any, bool, error) {
+	if cr, ok := r.(ContextResolver); ok {
+		return cr.ResolveContext(ctx, attribute)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	v, ok := r.Resolve(attribute)
+	return v, ok, nil
+}
+
+// ResolverWithContext binds ctx to r so that code that only knows the
+// Resolver interface still propagates ctx to a ContextResolver. Once ctx is
+// done, or when a lookup fails, attributes resolve as missing; EvalBound
+// reports such failures instead.
+func ResolverWithContext(ctx context.Context, r Resolver) Resolver {
+	return &boundResolver{ctx: ctx, r: r}
+}
+
+// EvalBound calls eval with r bound to ctx (see ResolverWithContext) for
+// engines and handlers that only know the Resolver interface. A lookup that
+// failed during eval, or ctx being done after it, fails the evaluation
+// rather than letting the attribute pass for missing.
+func EvalBound(ctx context.Context, r Resolver, eval func(Resolver) (bool, error)) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	b := &boundResolver{ctx: ctx, r: r}
+	ok, err := eval(b)
+	if failure := b.failure(); failure != nil {
+		return false, failure
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return ok, err
+}
+
+type boundResolver struct {
+	ctx context.Context
+	r   Resolver
+
+	mu  sync.Mutex
+	err error
+}
+
+func (b *boundResolver) Resolve(attribute string) (any, bool) {
+	v, ok, err := ResolveContext(b.ctx, b.r, attribute)
+	if err != nil {
+		b.mu.Lock()
+		if b.err == nil {
+			b.err = err
+		}
+		b.mu.Unlock()
+		return nil, false
+	}
+	return v, ok
+}
+
+func (b *boundResolver) ResolveContext(ctx context.Context, attribute string) (any, bool, error) {
+	return ResolveContext(ctx, b.r, attribute)
+}
+
+// failure returns the first error of a lookup made through Resolve.
+func (b *boundResolver) failure() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
 // MapAttributes is a map-based implementation of Resolver that supports dotted paths
 // to traverse nested maps and structs.
 type MapAttributes map[string]any
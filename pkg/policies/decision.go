@@ -0,0 +1,161 @@
+package policies
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Outcome is the result of evaluating one or more policies for a request.
+type Outcome string
+
+const (
+	// OutcomeAllow means the request is permitted.
+	OutcomeAllow Outcome = "allow"
+	// OutcomeDeny means the request is denied.
+	OutcomeDeny Outcome = "deny"
+	// OutcomeNotApplicable means no policy applied to the request.
+	OutcomeNotApplicable Outcome = "not_applicable"
+)
+
+// PolicyResult records how a single policy evaluated for a request.
+type PolicyResult struct {
+	PolicyID string  `json:"policy_id"`
+	Version  string  `json:"version,omitempty"`
+	Effect   Effect  `json:"effect"`
+	DryRun   bool    `json:"dry_run,omitempty"`
+	Priority int     `json:"priority"`
+	Matched  bool    `json:"matched"`
+	Outcome  Outcome `json:"outcome"`
+	// Indeterminate is set when the condition could be neither true nor
+	// false, for example on a missing attribute under MissingIndeterminate.
+	// Err then holds the cause.
+	Indeterminate bool `json:"indeterminate,omitempty"`
+	// Err holds the error raised while evaluating the condition. The
+	// CombiningAlgorithm decides how such results count.
+	Err error `json:"-"`
+}
+
+func newPolicyResult(p Policy) PolicyResult {
+	return PolicyResult{
+		PolicyID: p.ID,
+		Version:  p.Version,
+		Effect:   p.Effect,
+		DryRun:   p.DryRun,
+		Priority: p.Priority(),
+		Outcome:  OutcomeNotApplicable,
+	}
+}
+
+func (r *PolicyResult) match(matched bool) {
+	r.Matched = matched
+	r.Outcome = OutcomeNotApplicable
+	if matched {
+		r.Outcome = Outcome(r.Effect)
+	}
+}
+
+// MarshalJSON renders the result including its evaluation error, if any.
+func (r PolicyResult) MarshalJSON() ([]byte, error) {
+	type alias PolicyResult
+	return json.Marshal(struct {
+		alias
+		Error string `json:"error,omitempty"`
+	}{alias: alias(r), Error: errorString(r.Err)})
+}
+
+// Decision is the structured result returned by an Evaluator. It carries the
+// final outcome, the policy that decided it and the individual outcome of
+// every policy that was considered.
+type Decision struct {
+	Outcome Outcome `json:"outcome"`
+	// PolicyID and Version identify the policy that decided the outcome.
+	// Both are empty when no single policy decided it.
+	PolicyID string `json:"policy_id,omitempty"`
+	Version  string `json:"version,omitempty"`
+	// Policies holds the results of the enforcing policies.
+	Policies []PolicyResult `json:"policies,omitempty"`
+	// DryRuns holds the would-be results of dry-run policies. They never
+	// influence Outcome.
+	DryRuns []PolicyResult `json:"dry_runs,omitempty"`
+	// Inactive holds the policies skipped because their Period does not
+	// contain EvaluatedAt.
+	Inactive []PolicyResult `json:"inactive,omitempty"`
+	// NotLive holds the policies skipped because their State is not live,
+	// such as drafts and retired policies.
+	NotLive []PolicyResult `json:"not_live,omitempty"`
+	// EvaluatedAt is the instant the policies were evaluated at.
+	EvaluatedAt time.Time `json:"evaluated_at"`
+	// Errors holds the errors raised while evaluating policies.
+	Errors []error `json:"-"`
+}
+
+// Allowed reports whether the decision permits the request.
+func (d Decision) Allowed() bool {
+	return d.Outcome == OutcomeAllow
+}
+
+// Denied reports whether the decision denies the request.
+func (d Decision) Denied() bool {
+	return d.Outcome == OutcomeDeny
+}
+
+// Err returns an *ErrDenied wrapping d when the decision is a denial and nil
+// otherwise.
+func (d Decision) Err() error {
+	if !d.Denied() {
+		return nil
+	}
+	return &ErrDenied{Decision: d}
+}
+
+func (d *Decision) decide(r PolicyResult) {
+	d.Outcome = applicable(r)
+	d.PolicyID = r.PolicyID
+	d.Version = r.Version
+}
+
+// MarshalJSON renders the decision including its evaluation errors.
+func (d Decision) MarshalJSON() ([]byte, error) {
+	type alias Decision
+	errs := make([]string, 0, len(d.Errors))
+	for _, err := range d.Errors {
+		errs = append(errs, err.Error())
+	}
+	return json.Marshal(struct {
+		alias
+		Errors []string `json:"errors,omitempty"`
+	}{alias: alias(d), Errors: errs})
+}
+
+// ErrDenied is returned by an Evaluator when a request is denied. It wraps the
+// Decision that caused the denial, and unwraps to its Errors.
+type ErrDenied struct {
+	Decision Decision
+}
+
+func (e *ErrDenied) Error() string {
+	if e.Decision.PolicyID == "" {
+		return "request denied"
+	}
+	return fmt.Sprintf("request denied by policy %s", e.Decision.PolicyID)
+}
+
+// Unwrap returns the errors raised while reaching the decision.
+func (e *ErrDenied) Unwrap() []error {
+	return e.Decision.Errors
+}
+
+// IsDenied reports whether err is, or wraps, an *ErrDenied.
+func IsDenied(err error) bool {
+	var denied *ErrDenied
+	return errors.As(err, &denied)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
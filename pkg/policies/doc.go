@@ -4,4 +4,20 @@
 // PolicyCondition that is evaluated against an attribute context. The
 // package provides types and helpers to validate, evaluate and manipulate
 // policies and conditions.
+//
+// Conditions evaluate to true, false or, when they cannot be decided, to an
+// unknown result reported as an error. Logical operators combine them with
+// Kleene's three-valued logic, so that the result never depends on the order
+// of the conditions:
+//
+//   - and is false when any condition is false, otherwise unknown when any
+//     is unknown, otherwise true;
+//   - or is true when any condition is true, otherwise unknown when any is
+//     unknown, otherwise false;
+//   - not of an unknown condition is unknown.
+//
+// An unknown result is indeterminate when its causes all match
+// ErrIndeterminate (see Unknown). The Evaluator records indeterminate
+// policies, like policies whose evaluation failed, in their PolicyResult and
+// leaves their outcome to the CombiningAlgorithm.
 package policies
@@ -2,66 +2,269 @@ package policies
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 )
 
 type Engine interface {
 	Eval(cond PolicyCondition, ctx Resolver) (bool, error)
 }
 
+// ContextEngine is an Engine that honours the cancellation and deadline of
+// ctx and propagates it to every handler and Resolver it calls.
+type ContextEngine interface {
+	Engine
+	EvalContext(ctx context.Context, cond PolicyCondition, r Resolver) (bool, error)
+}
+
+// AsContextEngine returns eng as a ContextEngine. Engines that do not
+// implement the interface are adapted: they are called through EvalBound, so
+// that a failed lookup or ctx being done fails the evaluation.
+func AsContextEngine(eng Engine) ContextEngine {
+	if ce, ok := eng.(ContextEngine); ok {
+		return ce
+	}
+	return engineAdapter{eng}
+}
+
+type engineAdapter struct {
+	Engine
+}
+
+func (a engineAdapter) EvalContext(ctx context.Context, cond PolicyCondition, r Resolver) (bool, error) {
+	return EvalBound(ctx, r, func(r Resolver) (bool, error) {
+		return a.Eval(cond, r)
+	})
+}
+
 type PolicyRepository interface {
 	FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]Policy, error)
 }
 
+// EvaluatorRequest is a request to evaluate: a Subject performing an Action
+// on a Resource, in an environment described by Context or Resolver.
 type EvaluatorRequest struct {
+	Subject    Subject
+	Action     string
 	Resource   string
 	ResourceID string
 	Context    MapAttributes
+	// Resolver, when set, is used instead of Context to resolve attributes.
+	// A ContextResolver receives the context passed to Evaluator.Eval.
+	Resolver Resolver
+	// Time is the instant the request is evaluated at. Policies whose Period
+	// does not contain it are skipped. The Evaluator's clock is used when zero.
+	Time time.Time
+}
+
+// Target returns the subject, action and resource of the request.
+func (r EvaluatorRequest) Target() Target {
+	return Target{Subject: r.Subject, Action: r.Action, Resource: r.Resource, ResourceID: r.ResourceID}
 }
 
 // Evaluator is a higher level component that retrieves policies from a
 // repository and executes them using an Engine against a request context.
+//
+// Eval returns the Decision reached for the request. When the request is
+// denied, including when evaluating a policy failed, the returned error is an
+// *ErrDenied wrapping that Decision; other errors report failures to
+// retrieve policies.
 type Evaluator interface {
-	Eval(ctx context.Context, req EvaluatorRequest) error
+	Eval(ctx context.Context, req EvaluatorRequest) (Decision, error)
+}
+
+// DecisionLogger receives every Decision reached by an Evaluator, including
+// the would-be outcomes of dry-run policies. It is called synchronously, so
+// implementations should not block.
+type DecisionLogger interface {
+	LogDecision(ctx context.Context, req EvaluatorRequest, d Decision)
+}
+
+// DecisionLoggerFunc adapts a function to the DecisionLogger interface.
+type DecisionLoggerFunc func(ctx context.Context, req EvaluatorRequest, d Decision)
+
+// LogDecision calls f(ctx, req, d).
+func (f DecisionLoggerFunc) LogDecision(ctx context.Context, req EvaluatorRequest, d Decision) {
+	f(ctx, req, d)
 }
 
 type evaluator struct {
-	eng  Engine
-	repo PolicyRepository
+	eng         ContextEngine
+	repo        PolicyRepository
+	combining   CombiningAlgorithm
+	logger      DecisionLogger
+	now         func() time.Time
+	strict      bool
+	versionKeys bool
+}
+
+// EvaluatorOption configures an Evaluator built by NewEvaluator.
+type EvaluatorOption func(*evaluator)
+
+// WithCombiningAlgorithm sets the algorithm used to combine policy results.
+// It defaults to RequireAll.
+func WithCombiningAlgorithm(alg CombiningAlgorithm) EvaluatorOption {
+	return func(e *evaluator) {
+		e.combining = alg
+	}
+}
+
+// WithDecisionLogger sets a DecisionLogger notified of every Decision.
+func WithDecisionLogger(l DecisionLogger) EvaluatorOption {
+	return func(e *evaluator) {
+		e.logger = l
+	}
+}
+
+// WithClock sets the clock used to evaluate requests that carry no Time. It
+// defaults to time.Now.
+func WithClock(now func() time.Time) EvaluatorOption {
+	return func(e *evaluator) {
+		e.now = now
+	}
+}
+
+// WithStrictLifecycle makes the Evaluator evaluate only policies explicitly
+// approved or active. By default policies whose State is unset are evaluated
+// as if they were active, as they were before lifecycle states existed; with
+// this option they are skipped and reported in Decision.NotLive.
+func WithStrictLifecycle() EvaluatorOption {
+	return func(e *evaluator) {
+		e.strict = true
+	}
+}
+
+// WithVersionKeys makes the Evaluator identify the condition of every policy
+// having a Version by its ID and Version (see ContextWithConditionKey), so
+// that engines caching compiled conditions do not encode it on every
+// evaluation. Use it only when the Version of a policy changes whenever its
+// condition does, as with history.Repository, and the engine is not shared
+// with Evaluators of other repositories.
+func WithVersionKeys() EvaluatorOption {
+	return func(e *evaluator) {
+		e.versionKeys = true
+	}
 }
 
 // NewEvaluator constructs a new Evaluator from an Engine and a PolicyRepository.
-func NewEvaluator(eng Engine, repo PolicyRepository) Evaluator {
-	return &evaluator{
-		eng:  eng,
-		repo: repo,
+func NewEvaluator(eng Engine, repo PolicyRepository, opts ...EvaluatorOption) Evaluator {
+	e := &evaluator{
+		eng:       AsContextEngine(eng),
+		repo:      repo,
+		combining: RequireAll,
+		now:       time.Now,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Eval evaluates every policy found for the request that is active at the
+// request time and targets its subject and action (see FindByTarget);
+// inactive policies are reported in Decision.Inactive, and those that are
+// not live (see State) in Decision.NotLive. Dry-run policies are evaluated
+// in shadow mode: their would-be outcome is recorded in Decision.DryRuns but
+// never influences the final outcome nor Decision.Errors.
+// Policies whose condition fails to evaluate, or is indeterminate (see
+// ErrIndeterminate), keep the error in their PolicyResult and it is added to
+// Decision.Errors without aborting the evaluation; the CombiningAlgorithm
+// then fails closed: such a deny policy denies as if it matched, while such
+// an allow policy is ignored as if it did not.
+func (e *evaluator) Eval(ctx context.Context, req EvaluatorRequest) (Decision, error) {
+	decision, err := e.eval(ctx, req)
+	if e.logger != nil {
+		e.logger.LogDecision(ctx, req, decision)
+	}
+	return decision, err
 }
 
-func (e *evaluator) Eval(ctx context.Context, req EvaluatorRequest) error {
-	pols, err := e.repo.FindByResourceAndResourceID(ctx, req.Resource, req.ResourceID)
+func (e *evaluator) eval(ctx context.Context, req EvaluatorRequest) (Decision, error) {
+	at := req.Time
+	if at.IsZero() {
+		at = e.now()
+	}
+
+	pols, err := FindByTarget(ContextWithEvaluationTime(ctx, at), e.repo, req.Target())
 	if err != nil {
-		return err
+		return Decision{Outcome: OutcomeNotApplicable, EvaluatedAt: at, Errors: []error{err}}, err
 	}
 
+	var resolver Resolver = req.Context
+	if req.Resolver != nil {
+		resolver = req.Resolver
+	}
+
+	var results, dryRuns, inactive, notLive []PolicyResult
+	var failed, indeterminate []error
 	for _, pol := range pols {
-		ok, err := e.eng.Eval(pol.Condition, req.Context)
-		if err != nil {
-			return err
+		if err := ctx.Err(); err != nil {
+			decision := Decision{
+				Outcome:     OutcomeDeny,
+				Policies:    results,
+				DryRuns:     dryRuns,
+				Inactive:    inactive,
+				NotLive:     notLive,
+				EvaluatedAt: at,
+				Errors:      []error{err},
+			}
+			return decision, decision.Err()
+		}
+
+		res := newPolicyResult(pol)
+		if !e.isLive(pol) {
+			notLive = append(notLive, res)
+			continue
+		}
+		if !pol.IsActiveAt(at) {
+			inactive = append(inactive, res)
+			continue
 		}
 
-		allowed := ok
-		if pol.Effect == EffectDeny {
-			allowed = !allowed
+		ok, err := e.eng.EvalContext(e.conditionContext(ctx, pol), pol.Condition, resolver)
+		switch {
+		case errors.Is(err, ErrIndeterminate):
+			res.Indeterminate = true
+			res.Err = err
+		case err != nil:
+			res.Err = err
+		default:
+			res.match(ok)
 		}
 
 		if pol.DryRun {
-			return nil
+			dryRuns = append(dryRuns, res)
+			continue
 		}
-
-		if !allowed {
-			return fmt.Errorf("execution is dained")
+		results = append(results, res)
+		switch {
+		case res.Indeterminate:
+			indeterminate = append(indeterminate, fmt.Errorf("policy %s: %w", pol.ID, err))
+		case err != nil:
+			failed = append(failed, fmt.Errorf("policy %s: %w", pol.ID, err))
 		}
 	}
-	return nil
+
+	decision := e.combining.Combine(results)
+	decision.DryRuns = dryRuns
+	decision.Errors = append(decision.Errors, failed...)
+	decision.Errors = append(decision.Errors, indeterminate...)
+	decision.Inactive = inactive
+	decision.NotLive = notLive
+	decision.EvaluatedAt = at
+	return decision, decision.Err()
+}
+
+// conditionContext returns ctx carrying the condition key of pol, when the
+// Evaluator uses WithVersionKeys.
+func (e *evaluator) conditionContext(ctx context.Context, pol Policy) context.Context {
+	if !e.versionKeys || pol.Version == "" {
+		return ctx
+	}
+	return ContextWithConditionKey(ctx, pol.ID+"\x00"+pol.Version)
+}
+
+func (e *evaluator) isLive(p Policy) bool {
+	return p.IsLive() || !e.strict && p.State == ""
 }
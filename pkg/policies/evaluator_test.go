@@ -0,0 +1,527 @@
+package policies_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
+)
+
+type repoFunc func(ctx context.Context, resource, resourceID string) ([]policies.Policy, error)
+
+func (f repoFunc) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	return f(ctx, resource, resourceID)
+}
+
+func staticRepo(pols ...policies.Policy) policies.PolicyRepository {
+	return repoFunc(func(context.Context, string, string) ([]policies.Policy, error) {
+		return pols, nil
+	})
+}
+
+func testPolicy(id string, effect policies.Effect, cond policies.PolicyCondition) policies.Policy {
+	return policies.Policy{
+		ID:        id,
+		Resource:  "doc",
+		Effect:    effect,
+		Condition: cond,
+		Version:   "1",
+		State:     policies.StateActive,
+		Period:    timerange.MustNew(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), nil),
+	}
+}
+
+func withState(p policies.Policy, s policies.State) policies.Policy {
+	p.State = s
+	return p
+}
+
+func withTarget(p policies.Policy, subject, action string) policies.Policy {
+	p.Subjects = []string{subject}
+	p.Actions = []string{action}
+	return p
+}
+
+func eqCond(attr string, v any) policies.PolicyCondition {
+	return policies.PolicyCondition{Attribute: attr, Operator: policies.OpEqual, Value: v}
+}
+
+func TestEvaluator_Eval(t *testing.T) {
+	type input struct {
+		repo policies.PolicyRepository
+		req  policies.EvaluatorRequest
+	}
+
+	tests := []struct {
+		name   string
+		input  input
+		assert func(t *testing.T, d policies.Decision, err error)
+	}{
+		{
+			name:  "when no policy applies should be not applicable",
+			input: input{repo: staticRepo(), req: policies.EvaluatorRequest{Resource: "doc"}},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, policies.OutcomeNotApplicable, d.Outcome)
+				assert.Empty(t, d.PolicyID)
+			},
+		},
+		{
+			name: "when allow policy matches should allow",
+			input: input{
+				repo: staticRepo(testPolicy("p1", policies.EffectAllow, eqCond("role", "admin"))),
+				req:  policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.NoError(t, err)
+				assert.True(t, d.Allowed())
+				assert.Equal(t, "p1", d.PolicyID)
+				assert.Equal(t, "1", d.Version)
+				if assert.Len(t, d.Policies, 1) {
+					assert.True(t, d.Policies[0].Matched)
+				}
+			},
+		},
+		{
+			name: "when deny policy matches should return ErrDenied",
+			input: input{
+				repo: staticRepo(
+					testPolicy("p1", policies.EffectAllow, eqCond("role", "admin")),
+					testPolicy("p2", policies.EffectDeny, eqCond("blocked", true)),
+				),
+				req: policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin", "blocked": true}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				var denied *policies.ErrDenied
+				if assert.ErrorAs(t, err, &denied) {
+					assert.Equal(t, "p2", denied.Decision.PolicyID)
+				}
+				assert.True(t, policies.IsDenied(err))
+				assert.True(t, d.Denied())
+				assert.Len(t, d.Policies, 2)
+			},
+		},
+		{
+			name: "when condition fails should record the error",
+			input: input{
+				repo: staticRepo(testPolicy("p1", policies.EffectAllow, eqCond("role", "admin"))),
+				req:  policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.True(t, policies.IsDenied(err))
+				assert.True(t, d.Denied())
+				assert.ErrorIs(t, err, policies.ErrMissingAttribute)
+				assert.Len(t, d.Errors, 1)
+				if assert.Len(t, d.Policies, 1) {
+					assert.Error(t, d.Policies[0].Err)
+				}
+			},
+		},
+		{
+			name: "when deny policy fails should deny and keep evaluating",
+			input: input{
+				repo: staticRepo(
+					testPolicy("p1", policies.EffectDeny, eqCond("blocked", true)),
+					testPolicy("p2", policies.EffectAllow, eqCond("role", "admin")),
+					testPolicy("shadow", policies.EffectDeny, eqCond("role", "admin")).WithDryRun(true),
+				),
+				req: policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.True(t, policies.IsDenied(err))
+				assert.Equal(t, "p1", d.PolicyID)
+				if assert.Len(t, d.Errors, 1) {
+					assert.ErrorContains(t, d.Errors[0], "policy p1")
+				}
+				if assert.Len(t, d.Policies, 2) {
+					assert.True(t, d.Policies[1].Matched)
+				}
+				if assert.Len(t, d.DryRuns, 1) {
+					assert.Equal(t, policies.OutcomeDeny, d.DryRuns[0].Outcome)
+				}
+			},
+		},
+		{
+			name: "when dry-run policy precedes enforcing ones should keep evaluating",
+			input: input{
+				repo: staticRepo(
+					testPolicy("shadow", policies.EffectDeny, eqCond("role", "admin")).WithDryRun(true),
+					testPolicy("p1", policies.EffectDeny, eqCond("blocked", true)),
+				),
+				req: policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin", "blocked": true}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.True(t, policies.IsDenied(err))
+				assert.Equal(t, "p1", d.PolicyID)
+				if assert.Len(t, d.DryRuns, 1) {
+					assert.Equal(t, policies.OutcomeDeny, d.DryRuns[0].Outcome)
+				}
+			},
+		},
+		{
+			name: "when dry-run policy would deny should not influence the decision",
+			input: input{
+				repo: staticRepo(
+					testPolicy("p1", policies.EffectAllow, eqCond("role", "admin")),
+					testPolicy("shadow", policies.EffectDeny, eqCond("role", "admin")).WithDryRun(true),
+				),
+				req: policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.NoError(t, err)
+				assert.True(t, d.Allowed())
+				assert.Len(t, d.Policies, 1)
+				if assert.Len(t, d.DryRuns, 1) {
+					assert.Equal(t, "shadow", d.DryRuns[0].PolicyID)
+					assert.Equal(t, policies.OutcomeDeny, d.DryRuns[0].Outcome)
+				}
+			},
+		},
+		{
+			name: "when dry-run policy fails should record the error and continue",
+			input: input{
+				repo: staticRepo(
+					testPolicy("shadow", policies.EffectDeny, eqCond("missing", true)).WithDryRun(true),
+					testPolicy("p1", policies.EffectAllow, eqCond("role", "admin")),
+				),
+				req: policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.NoError(t, err)
+				assert.True(t, d.Allowed())
+				assert.Empty(t, d.Errors)
+				if assert.Len(t, d.DryRuns, 1) {
+					assert.Error(t, d.DryRuns[0].Err)
+				}
+			},
+		},
+		{
+			name: "when policies are not live should ignore them",
+			input: input{
+				repo: staticRepo(
+					withState(testPolicy("draft", policies.EffectDeny, eqCond("role", "admin")), policies.StateDraft),
+					withState(testPolicy("retired", policies.EffectDeny, eqCond("role", "admin")), policies.StateRetired),
+					withState(testPolicy("approved", policies.EffectAllow, eqCond("role", "admin")), policies.StateApproved),
+				),
+				req: policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.NoError(t, err)
+				assert.True(t, d.Allowed())
+				assert.Len(t, d.Policies, 1)
+				assert.Empty(t, d.Inactive)
+				if assert.Len(t, d.NotLive, 2) {
+					assert.Equal(t, "draft", d.NotLive[0].PolicyID)
+					assert.Equal(t, "retired", d.NotLive[1].PolicyID)
+				}
+			},
+		},
+		{
+			name: "when policy state is unset should evaluate it as active",
+			input: input{
+				repo: staticRepo(withState(testPolicy("legacy", policies.EffectAllow, eqCond("role", "admin")), "")),
+				req:  policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.NoError(t, err)
+				assert.True(t, d.Allowed())
+				assert.Equal(t, "legacy", d.PolicyID)
+				assert.Empty(t, d.NotLive)
+			},
+		},
+		{
+			name: "when policies target other subjects or actions should ignore them",
+			input: input{
+				repo: staticRepo(
+					withTarget(testPolicy("writers", policies.EffectDeny, eqCond("role", "admin")), "group:writers", "write"),
+					withTarget(testPolicy("readers", policies.EffectAllow, eqCond("role", "admin")), "group:readers", "read"),
+				),
+				req: policies.EvaluatorRequest{
+					Subject:  policies.Subject{ID: "alice", Groups: []string{"readers"}},
+					Action:   "read",
+					Resource: "doc",
+					Context:  policies.MapAttributes{"role": "admin"},
+				},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.NoError(t, err)
+				assert.True(t, d.Allowed())
+				assert.Equal(t, "readers", d.PolicyID)
+				assert.Len(t, d.Policies, 1)
+			},
+		},
+		{
+			name: "when repository fails should return its error",
+			input: input{
+				repo: repoFunc(func(context.Context, string, string) ([]policies.Policy, error) {
+					return nil, errors.New("boom")
+				}),
+				req: policies.EvaluatorRequest{Resource: "doc"},
+			},
+			assert: func(t *testing.T, d policies.Decision, err error) {
+				assert.EqualError(t, err, "boom")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := policies.NewEvaluator(native.NewNativeEngine(), tt.input.repo)
+
+			d, err := ev.Eval(context.Background(), tt.input.req)
+
+			tt.assert(t, d, err)
+		})
+	}
+}
+
+func TestEvaluator_WithStrictLifecycle(t *testing.T) {
+	repo := staticRepo(
+		withState(testPolicy("legacy", policies.EffectDeny, eqCond("role", "admin")), ""),
+		withState(testPolicy("approved", policies.EffectAllow, eqCond("role", "admin")), policies.StateApproved),
+	)
+	ev := policies.NewEvaluator(native.NewNativeEngine(), repo, policies.WithStrictLifecycle())
+
+	d, err := ev.Eval(context.Background(), policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}})
+
+	assert.NoError(t, err)
+	assert.True(t, d.Allowed())
+	assert.Equal(t, "approved", d.PolicyID)
+	if assert.Len(t, d.NotLive, 1) {
+		assert.Equal(t, "legacy", d.NotLive[0].PolicyID)
+	}
+}
+
+func TestEvaluator_Indeterminate(t *testing.T) {
+	repo := staticRepo(
+		testPolicy("allow-admin", policies.EffectAllow, eqCond("role", "admin")),
+		testPolicy("deny-region", policies.EffectDeny, policies.PolicyCondition{
+			Operator: policies.OpAnd,
+			Conditions: []policies.PolicyCondition{
+				eqCond("region", "eu"),
+				eqCond("zone", "a"),
+			},
+		}),
+	)
+	eng := native.NewNativeEngine(native.WithMissingAttributes(policies.MissingIndeterminate))
+	req := policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}}
+
+	t.Run("when the algorithm fails closed should deny", func(t *testing.T) {
+		d, err := policies.NewEvaluator(eng, repo).Eval(context.Background(), req)
+
+		assert.True(t, policies.IsDenied(err))
+		assert.Equal(t, "deny-region", d.PolicyID)
+		if assert.Len(t, d.Policies, 2) {
+			assert.True(t, d.Policies[1].Indeterminate)
+			assert.Equal(t, policies.OutcomeNotApplicable, d.Policies[1].Outcome)
+			assert.ErrorIs(t, d.Policies[1].Err, policies.ErrIndeterminate)
+		}
+		if assert.Len(t, d.Errors, 1) {
+			assert.EqualError(t, d.Errors[0], "policy deny-region: indeterminate: missing attribute: region; indeterminate: missing attribute: zone")
+			assert.ErrorIs(t, d.Errors[0], policies.ErrIndeterminate)
+		}
+	})
+
+	t.Run("when allow overrides should allow", func(t *testing.T) {
+		d, err := policies.NewEvaluator(eng, repo, policies.WithCombiningAlgorithm(policies.PermitOverrides)).Eval(context.Background(), req)
+
+		assert.NoError(t, err)
+		assert.True(t, d.Allowed())
+		assert.Len(t, d.Errors, 1)
+	})
+}
+
+func TestDecision_MarshalJSON(t *testing.T) {
+	d := policies.Decision{
+		Outcome:  policies.OutcomeDeny,
+		PolicyID: "p1",
+		Policies: []policies.PolicyResult{{PolicyID: "p1", Effect: policies.EffectDeny, Outcome: policies.OutcomeDeny, Err: errors.New("bad")}},
+		Errors:   []error{errors.New("bad")},
+	}
+
+	b, err := d.MarshalJSON()
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"outcome": "deny",
+		"policy_id": "p1",
+		"policies": [{"policy_id": "p1", "effect": "deny", "priority": 0, "matched": false, "outcome": "deny", "error": "bad"}],
+		"evaluated_at": "0001-01-01T00:00:00Z",
+		"errors": ["bad"]
+	}`, string(b))
+}
+
+func TestEvaluator_DecisionLogger(t *testing.T) {
+	var logged []policies.Decision
+	logger := policies.DecisionLoggerFunc(func(_ context.Context, _ policies.EvaluatorRequest, d policies.Decision) {
+		logged = append(logged, d)
+	})
+	repo := staticRepo(testPolicy("shadow", policies.EffectDeny, eqCond("role", "admin")).WithDryRun(true))
+	ev := policies.NewEvaluator(native.NewNativeEngine(), repo, policies.WithDecisionLogger(logger))
+
+	d, err := ev.Eval(context.Background(), policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, policies.OutcomeNotApplicable, d.Outcome)
+	if assert.Len(t, logged, 1) && assert.Len(t, logged[0].DryRuns, 1) {
+		assert.Equal(t, policies.OutcomeDeny, logged[0].DryRuns[0].Outcome)
+	}
+}
+
+func TestEvaluator_Period(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	expired := testPolicy("expired", policies.EffectDeny, eqCond("role", "admin"))
+	expired.Period = timerange.MustNew(jan, &feb)
+	scheduled := testPolicy("scheduled", policies.EffectDeny, eqCond("role", "admin"))
+	scheduled.Period = timerange.MustNew(mar, nil)
+	repo := staticRepo(expired, scheduled)
+
+	tests := []struct {
+		name     string
+		clock    time.Time
+		at       time.Time
+		policyID string
+		inactive []string
+	}{
+		{name: "when clock is inside the first period should apply it", clock: jan.Add(time.Hour), policyID: "expired", inactive: []string{"scheduled"}},
+		{name: "when clock is between periods should skip both", clock: feb.Add(time.Hour), inactive: []string{"expired", "scheduled"}},
+		{name: "when request time is set should override the clock", clock: feb.Add(time.Hour), at: mar, policyID: "scheduled", inactive: []string{"expired"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := policies.NewEvaluator(native.NewNativeEngine(), repo,
+				policies.WithCombiningAlgorithm(policies.DenyOverrides),
+				policies.WithClock(func() time.Time { return tt.clock }))
+
+			d, _ := ev.Eval(context.Background(), policies.EvaluatorRequest{
+				Resource: "doc",
+				Context:  policies.MapAttributes{"role": "admin"},
+				Time:     tt.at,
+			})
+
+			assert.Equal(t, tt.policyID, d.PolicyID)
+			var inactive []string
+			for _, r := range d.Inactive {
+				inactive = append(inactive, r.PolicyID)
+			}
+			assert.Equal(t, tt.inactive, inactive)
+			if tt.at.IsZero() {
+				assert.Equal(t, tt.clock, d.EvaluatedAt)
+			} else {
+				assert.Equal(t, tt.at, d.EvaluatedAt)
+			}
+		})
+	}
+}
+
+type legacyEngine struct {
+	resolved []any
+}
+
+func (e *legacyEngine) Eval(cond policies.PolicyCondition, r policies.Resolver) (bool, error) {
+	v, ok := r.Resolve(cond.Attribute)
+	e.resolved = append(e.resolved, v)
+	return ok, nil
+}
+
+type ctxResolver struct{}
+
+func (ctxResolver) Resolve(string) (any, bool) {
+	return nil, false
+}
+
+func (ctxResolver) ResolveContext(ctx context.Context, _ string) (any, bool, error) {
+	return ctx.Value(ctxKey{}), true, nil
+}
+
+type ctxKey struct{}
+
+type failingResolver struct{}
+
+func (failingResolver) Resolve(string) (any, bool) {
+	return nil, false
+}
+
+func (failingResolver) ResolveContext(context.Context, string) (any, bool, error) {
+	return nil, false, errors.New("database unavailable")
+}
+
+func TestEvaluator_Context(t *testing.T) {
+	repo := staticRepo(testPolicy("p1", policies.EffectAllow, eqCond("role", "admin")))
+
+	t.Run("when engine is not context aware should bind the context to the resolver", func(t *testing.T) {
+		eng := &legacyEngine{}
+		ev := policies.NewEvaluator(eng, repo)
+		ctx := context.WithValue(context.Background(), ctxKey{}, "from-ctx")
+
+		d, err := ev.Eval(ctx, policies.EvaluatorRequest{Resource: "doc", Resolver: ctxResolver{}})
+
+		assert.NoError(t, err)
+		assert.True(t, d.Allowed())
+		assert.Equal(t, []any{"from-ctx"}, eng.resolved)
+	})
+
+	t.Run("when resolver fails should fail closed", func(t *testing.T) {
+		ev := policies.NewEvaluator(&legacyEngine{}, repo)
+
+		d, err := ev.Eval(context.Background(), policies.EvaluatorRequest{Resource: "doc", Resolver: failingResolver{}})
+
+		assert.True(t, policies.IsDenied(err))
+		assert.True(t, d.Denied())
+		if assert.Len(t, d.Policies, 1) {
+			assert.ErrorContains(t, d.Policies[0].Err, "database unavailable")
+		}
+	})
+
+	t.Run("when context is cancelled should stop evaluating", func(t *testing.T) {
+		ev := policies.NewEvaluator(native.NewNativeEngine(), repo)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		d, err := ev.Eval(ctx, policies.EvaluatorRequest{Resource: "doc", Context: policies.MapAttributes{"role": "admin"}})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, policies.IsDenied(err))
+		assert.True(t, d.Denied())
+	})
+}
+
+type keyEngine struct {
+	keys []string
+}
+
+func (e *keyEngine) Eval(policies.PolicyCondition, policies.Resolver) (bool, error) {
+	return true, nil
+}
+
+func (e *keyEngine) EvalContext(ctx context.Context, _ policies.PolicyCondition, _ policies.Resolver) (bool, error) {
+	key, _ := policies.ConditionKey(ctx)
+	e.keys = append(e.keys, key)
+	return true, nil
+}
+
+func TestEvaluator_WithVersionKeys(t *testing.T) {
+	unversioned := testPolicy("p2", policies.EffectAllow, eqCond("role", "admin"))
+	unversioned.Version = ""
+	repo := staticRepo(testPolicy("p1", policies.EffectAllow, eqCond("role", "admin")), unversioned)
+	req := policies.EvaluatorRequest{Resource: "doc"}
+
+	eng := &keyEngine{}
+	_, err := policies.NewEvaluator(eng, repo).Eval(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", ""}, eng.keys)
+
+	eng = &keyEngine{}
+	_, err = policies.NewEvaluator(eng, repo, policies.WithVersionKeys()).Eval(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"p1\x001", ""}, eng.keys)
+}
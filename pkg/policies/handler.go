@@ -1,7 +1,36 @@
 package policies
 
+import "context"
+
 // OperatorHandler evaluates a PolicyCondition against an attribute context.
 // Implementations provide handlers for operator kinds or specific operators.
 type OperatorHandler interface {
 	Eval(cond PolicyCondition, ctx Resolver) (bool, error)
 }
+
+// ContextOperatorHandler is an OperatorHandler that honours the cancellation
+// and deadline of ctx and propagates it to the Resolver.
+type ContextOperatorHandler interface {
+	OperatorHandler
+	EvalContext(ctx context.Context, cond PolicyCondition, r Resolver) (bool, error)
+}
+
+// AsContextHandler returns h as a ContextOperatorHandler. Handlers that do not
+// implement the interface are adapted: they are called through EvalBound, so
+// that a failed lookup or ctx being done fails the evaluation.
+func AsContextHandler(h OperatorHandler) ContextOperatorHandler {
+	if ch, ok := h.(ContextOperatorHandler); ok {
+		return ch
+	}
+	return handlerAdapter{h}
+}
+
+type handlerAdapter struct {
+	OperatorHandler
+}
+
+func (a handlerAdapter) EvalContext(ctx context.Context, cond PolicyCondition, r Resolver) (bool, error) {
+	return EvalBound(ctx, r, func(r Resolver) (bool, error) {
+		return a.Eval(cond, r)
+	})
+}
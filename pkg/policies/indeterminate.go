@@ -0,0 +1,59 @@
+package policies
+
+import (
+	"errors"
+	"strings"
+)
+
+// IndeterminateError is the result of a logical condition left undecided by
+// several indeterminate conditions. It matches ErrIndeterminate and each of
+// its causes.
+type IndeterminateError struct {
+	Causes []error
+}
+
+func (e *IndeterminateError) Error() string {
+	msgs := make([]string, len(e.Causes))
+	for i, err := range e.Causes {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is matches ErrIndeterminate.
+func (e *IndeterminateError) Is(target error) bool {
+	return target == ErrIndeterminate
+}
+
+// Unwrap returns the causes of e.
+func (e *IndeterminateError) Unwrap() []error {
+	return e.Causes
+}
+
+// Unknown returns the error of a logical condition left undecided by the
+// errors of its conditions: the only error when there is one, the errors
+// not matching ErrIndeterminate when there are some, and an
+// *IndeterminateError otherwise. It returns nil when causes is empty.
+func Unknown(causes ...error) error {
+	switch len(causes) {
+	case 0:
+		return nil
+	case 1:
+		return causes[0]
+	}
+
+	var failures []error
+	for _, err := range causes {
+		if !errors.Is(err, ErrIndeterminate) {
+			failures = append(failures, err)
+		}
+	}
+	switch len(failures) {
+	case 0:
+		return &IndeterminateError{Causes: causes}
+	case 1:
+		return failures[0]
+	default:
+		return errors.Join(failures...)
+	}
+}
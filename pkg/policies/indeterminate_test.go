@@ -0,0 +1,40 @@
+package policies_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestUnknown(t *testing.T) {
+	boom := errors.New("boom")
+	region := &policies.MissingAttributeError{Attribute: "region", Indeterminate: true}
+	zone := &policies.MissingAttributeError{Attribute: "zone", Indeterminate: true}
+
+	tests := []struct {
+		name          string
+		causes        []error
+		err           string
+		indeterminate bool
+	}{
+		{name: "when there are no causes should be nil"},
+		{name: "when there is one cause should return it", causes: []error{boom}, err: "boom"},
+		{name: "when all causes are indeterminate should be indeterminate", causes: []error{region, zone}, err: "indeterminate: missing attribute: region; indeterminate: missing attribute: zone", indeterminate: true},
+		{name: "when a cause is not indeterminate should return it", causes: []error{region, boom}, err: "boom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policies.Unknown(tt.causes...)
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+			assert.Equal(t, tt.indeterminate, errors.Is(err, policies.ErrIndeterminate))
+		})
+	}
+}
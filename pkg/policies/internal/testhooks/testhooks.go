@@ -0,0 +1,7 @@
+// Package testhooks gives package policiestest access to unexported state of
+// package policies.
+package testhooks
+
+// UnregisterOperator removes an operator added with policies.RegisterOperator.
+// It is set by package policies.
+var UnregisterOperator func(op string)
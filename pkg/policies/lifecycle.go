@@ -0,0 +1,92 @@
+package policies
+
+import (
+	"errors"
+	"fmt"
+)
+
+// State is the lifecycle state of a policy. Only live policies, approved or
+// active, are evaluated by the Evaluator. The empty State is unset, as for
+// policies created before lifecycle states existed: the Evaluator evaluates
+// such policies as active, unless it is built WithStrictLifecycle, and they
+// move through the lifecycle as drafts.
+type State string
+
+const (
+	// StateDraft is a policy being written. It can be submitted for review.
+	StateDraft State = "draft"
+	// StateInReview is a policy waiting for approval. It is approved, or
+	// rejected back to draft.
+	StateInReview State = "in_review"
+	// StateApproved is a reviewed policy. It is live and can be activated.
+	StateApproved State = "approved"
+	// StateActive is a policy in use.
+	StateActive State = "active"
+	// StateRetired is a policy withdrawn from use. It is final.
+	StateRetired State = "retired"
+)
+
+// ErrInvalidTransition is returned when a policy cannot move from its state
+// to the requested one.
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// transitions lists the states each state can move to.
+var transitions = map[State][]State{
+	StateDraft:    {StateInReview},
+	StateInReview: {StateDraft, StateApproved},
+	StateApproved: {StateDraft, StateActive, StateRetired},
+	StateActive:   {StateRetired},
+	StateRetired:  nil,
+}
+
+// Valid reports whether s is a known state or empty.
+func (s State) Valid() bool {
+	if s == "" {
+		return true
+	}
+	_, ok := transitions[s]
+	return ok
+}
+
+// IsLive reports whether policies in state s are evaluated. The empty State
+// is not live.
+func (s State) IsLive() bool {
+	switch s {
+	case StateApproved, StateActive:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether a policy in state s can move to next.
+func (s State) CanTransitionTo(next State) bool {
+	for _, to := range transitions[s.normalize()] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
+func (s State) normalize() State {
+	if s == "" {
+		return StateDraft
+	}
+	return s
+}
+
+// Transition moves the policy to state to, or fails with
+// ErrInvalidTransition when its current state does not allow it.
+func (p *Policy) Transition(to State) error {
+	if !p.State.CanTransitionTo(to) {
+		return fmt.Errorf("policy %s from %s to %s: %w", p.ID, p.State.normalize(), to, ErrInvalidTransition)
+	}
+	p.State = to
+	return nil
+}
+
+// IsLive reports whether the lifecycle state of the policy lets it be
+// evaluated.
+func (p Policy) IsLive() bool {
+	return p.State.IsLive()
+}
@@ -0,0 +1,57 @@
+package policies_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestPolicy_Transition(t *testing.T) {
+	tests := []struct {
+		name string
+		from policies.State
+		to   policies.State
+		ok   bool
+	}{
+		{name: "draft to review", from: policies.StateDraft, to: policies.StateInReview, ok: true},
+		{name: "draft to active", from: policies.StateDraft, to: policies.StateActive},
+		{name: "review rejected", from: policies.StateInReview, to: policies.StateDraft, ok: true},
+		{name: "review approved", from: policies.StateInReview, to: policies.StateApproved, ok: true},
+		{name: "approved to active", from: policies.StateApproved, to: policies.StateActive, ok: true},
+		{name: "active to retired", from: policies.StateActive, to: policies.StateRetired, ok: true},
+		{name: "active to draft", from: policies.StateActive, to: policies.StateDraft},
+		{name: "unset state is a draft", from: "", to: policies.StateInReview, ok: true},
+		{name: "unset state is not active", from: "", to: policies.StateRetired},
+		{name: "retired is final", from: policies.StateRetired, to: policies.StateDraft},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policies.Policy{ID: "p", State: tt.from}
+			err := p.Transition(tt.to)
+			if !tt.ok {
+				assert.ErrorIs(t, err, policies.ErrInvalidTransition)
+				assert.Equal(t, tt.from, p.State)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.to, p.State)
+		})
+	}
+}
+
+func TestState_IsLive(t *testing.T) {
+	live := map[policies.State]bool{
+		"":                      false,
+		policies.StateDraft:     false,
+		policies.StateInReview:  false,
+		policies.StateApproved:  true,
+		policies.StateActive:    true,
+		policies.StateRetired:   false,
+		policies.State("other"): false,
+	}
+	for s, want := range live {
+		assert.Equal(t, want, s.IsLive(), s)
+	}
+}
@@ -0,0 +1,100 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// MissingAttributes sets how a condition evaluates when its attribute is not
+// found by the Resolver. Engines apply it to every operator but exists and
+// not_exists, which test for presence.
+type MissingAttributes int
+
+const (
+	// MissingError fails the condition with a *MissingAttributeError. It is
+	// the default.
+	MissingError MissingAttributes = iota
+	// MissingFalse makes the condition false, whatever its operator: nin and
+	// not_contains are false as well.
+	MissingFalse
+	// MissingIndeterminate fails the condition with a *MissingAttributeError
+	// matching ErrIndeterminate, so that callers can tell missing data from
+	// other failures.
+	MissingIndeterminate
+)
+
+var missingNames = [...]string{
+	MissingError:         "error",
+	MissingFalse:         "false",
+	MissingIndeterminate: "indeterminate",
+}
+
+func (m MissingAttributes) String() string {
+	if m >= 0 && int(m) < len(missingNames) {
+		return missingNames[m]
+	}
+	return fmt.Sprintf("MissingAttributes(%d)", int(m))
+}
+
+var (
+	// ErrMissingAttribute is matched by every *MissingAttributeError.
+	ErrMissingAttribute = errors.New("missing attribute")
+	// ErrIndeterminate is matched by errors of conditions that can be
+	// neither true nor false, such as those on missing attributes under
+	// MissingIndeterminate.
+	ErrIndeterminate = errors.New("indeterminate")
+)
+
+// MissingAttributeError reports a condition on a missing attribute.
+type MissingAttributeError struct {
+	Attribute     string
+	Indeterminate bool
+}
+
+func (e *MissingAttributeError) Error() string {
+	if e.Indeterminate {
+		return "indeterminate: missing attribute: " + e.Attribute
+	}
+	return "missing required attribute: " + e.Attribute
+}
+
+// Is matches ErrMissingAttribute, and ErrIndeterminate when the error was
+// raised under MissingIndeterminate.
+func (e *MissingAttributeError) Is(target error) bool {
+	return target == ErrMissingAttribute || e.Indeterminate && target == ErrIndeterminate
+}
+
+// Missing returns the result of a condition on the missing attribute.
+func (m MissingAttributes) Missing(attribute string) (bool, error) {
+	switch m {
+	case MissingFalse:
+		return false, nil
+	case MissingIndeterminate:
+		return false, &MissingAttributeError{Attribute: attribute, Indeterminate: true}
+	default:
+		return false, &MissingAttributeError{Attribute: attribute}
+	}
+}
+
+type missingAttributesKey struct{}
+
+// ContextWithMissingAttributes returns a copy of ctx carrying m. Engines set
+// it before calling handlers, which read it with MissingAttributesOf.
+func ContextWithMissingAttributes(ctx context.Context, m MissingAttributes) context.Context {
+	return context.WithValue(ctx, missingAttributesKey{}, m)
+}
+
+// MissingAttributesOf returns the MissingAttributes carried by ctx, if any.
+func MissingAttributesOf(ctx context.Context) (MissingAttributes, bool) {
+	m, ok := ctx.Value(missingAttributesKey{}).(MissingAttributes)
+	return m, ok
+}
+
+// MissingAttribute returns the result of a condition on the missing
+// attribute under the MissingAttributes carried by ctx, or MissingError.
+// Handlers call it when their attribute is not found.
+func MissingAttribute(ctx context.Context, attribute string) (bool, error) {
+	m, _ := MissingAttributesOf(ctx)
+	return m.Missing(attribute)
+}
@@ -0,0 +1,52 @@
+package policies_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestMissingAttributes_Missing(t *testing.T) {
+	tests := []struct {
+		name          string
+		m             policies.MissingAttributes
+		err           string
+		indeterminate bool
+	}{
+		{name: "when error should fail with a missing attribute error", m: policies.MissingError, err: "missing required attribute: age"},
+		{name: "when false should be false", m: policies.MissingFalse},
+		{name: "when indeterminate should fail as indeterminate", m: policies.MissingIndeterminate, err: "indeterminate: missing attribute: age", indeterminate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.m.Missing("age")
+
+			assert.False(t, res)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+			assert.ErrorIs(t, err, policies.ErrMissingAttribute)
+			assert.Equal(t, tt.indeterminate, errors.Is(err, policies.ErrIndeterminate))
+		})
+	}
+}
+
+func TestMissingAttribute_Context(t *testing.T) {
+	_, err := policies.MissingAttribute(context.Background(), "age")
+	assert.EqualError(t, err, "missing required attribute: age")
+
+	ctx := policies.ContextWithMissingAttributes(context.Background(), policies.MissingFalse)
+	m, ok := policies.MissingAttributesOf(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, policies.MissingFalse, m)
+
+	res, err := policies.MissingAttribute(ctx, "age")
+	assert.NoError(t, err)
+	assert.False(t, res)
+}
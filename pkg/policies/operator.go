@@ -46,4 +46,8 @@ const (
 	OpNotSubset  Operator = "not_subset"
 	OpIntersects Operator = "intersects"
 	OpDisjoint   Operator = "disjoint"
+
+	// Presence
+	OpExists    Operator = "exists"
+	OpNotExists Operator = "not_exists"
 )
@@ -1,5 +1,13 @@
 package policies
 
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies/internal/testhooks"
+)
+
 // OperatorKind groups operators by their execution semantics (comparison, string,
 // temporal, logical, etc.). Handlers may be registered by kind to implement
 // behavior shared by several operators.
@@ -13,8 +21,33 @@ const (
 	KindTemporal
 	KindArithmetic
 	KindLogical
+	// KindPresence tests whether an attribute is found, regardless of the
+	// MissingAttributes of the engine.
+	KindPresence
+	// KindCustom is meant for operators registered with RegisterOperator
+	// whose handlers are bound per operator rather than per kind.
+	KindCustom
 )
 
+var kindNames = [...]string{
+	KindComparison: "comparison",
+	KindRange:      "range",
+	KindSet:        "set",
+	KindString:     "string",
+	KindTemporal:   "temporal",
+	KindArithmetic: "arithmetic",
+	KindLogical:    "logical",
+	KindPresence:   "presence",
+	KindCustom:     "custom",
+}
+
+func (k OperatorKind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("OperatorKind(%d)", int(k))
+}
+
 // OperatorSpec describes an operator's kind and its arity constraints (min/max
 // arguments). MaxArgs == -1 indicates an unbounded number of arguments.
 type OperatorSpec struct {
@@ -25,10 +58,67 @@ type OperatorSpec struct {
 
 // OperatorSpecOf returns the OperatorSpec for a given Operator and whether it exists.
 func OperatorSpecOf(op Operator) (OperatorSpec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 	spec, ok := operatorRegistry[op]
 	return spec, ok
 }
 
+// RegisterOperator adds op to the operator registry so that it is accepted by
+// PolicyCondition.Validate and dispatched by the engines. It fails when op is
+// empty, already registered or spec has invalid arity. Engines still need a
+// handler or builder for op, either by spec.Kind or for op itself.
+func RegisterOperator(op Operator, spec OperatorSpec) error {
+	if op == "" {
+		return fmt.Errorf("operator name is required")
+	}
+	if spec.MinArgs < 0 || (spec.MaxArgs != -1 && spec.MaxArgs < spec.MinArgs) {
+		return fmt.Errorf("operator %s has invalid arity %d..%d", op, spec.MinArgs, spec.MaxArgs)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := operatorRegistry[op]; ok {
+		return fmt.Errorf("operator %s already registered", op)
+	}
+	operatorRegistry[op] = spec
+	return nil
+}
+
+// MustRegisterOperator is like RegisterOperator but panics on error. It is
+// intended for package initialisation.
+func MustRegisterOperator(op Operator, spec OperatorSpec) {
+	if err := RegisterOperator(op, spec); err != nil {
+		panic(err)
+	}
+}
+
+func init() {
+	testhooks.UnregisterOperator = func(op string) { unregisterOperator(Operator(op)) }
+}
+
+// unregisterOperator removes op from the operator registry. Tests reach it
+// through policiestest.RegisterOperator.
+func unregisterOperator(op Operator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(operatorRegistry, op)
+}
+
+// Operators returns every registered operator sorted by name.
+func Operators() []Operator {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ops := make([]Operator, 0, len(operatorRegistry))
+	for op := range operatorRegistry {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}
+
+var registryMu sync.RWMutex
+
 var operatorRegistry = map[Operator]OperatorSpec{
 	// Comparison operators
 	OpEqual:          {Kind: KindComparison, MinArgs: 2, MaxArgs: 2},
@@ -67,4 +157,8 @@ var operatorRegistry = map[Operator]OperatorSpec{
 	OpNotSubset:  {Kind: KindSet, MinArgs: 2, MaxArgs: 2}, // subconjunto, conjunto
 	OpIntersects: {Kind: KindSet, MinArgs: 2, MaxArgs: 2}, // conjunto, conjunto
 	OpDisjoint:   {Kind: KindSet, MinArgs: 2, MaxArgs: 2}, // conjunto, conjunto
+
+	// Presence operators
+	OpExists:    {Kind: KindPresence, MinArgs: 1, MaxArgs: 1},
+	OpNotExists: {Kind: KindPresence, MinArgs: 1, MaxArgs: 1},
 }
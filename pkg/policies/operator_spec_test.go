@@ -0,0 +1,47 @@
+package policies_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies/policiestest"
+)
+
+func TestRegisterOperator(t *testing.T) {
+	tests := []struct {
+		name string
+		op   policies.Operator
+		spec policies.OperatorSpec
+		err  string
+	}{
+		{name: "when operator is new should register it", op: "test_in_cidr", spec: policies.OperatorSpec{Kind: policies.KindCustom, MinArgs: 2, MaxArgs: 2}},
+		{name: "when operator exists should fail", op: policies.OpEqual, spec: policies.OperatorSpec{Kind: policies.KindCustom, MinArgs: 2, MaxArgs: 2}, err: "operator eq already registered"},
+		{name: "when name is empty should fail", op: "", err: "operator name is required"},
+		{name: "when arity is invalid should fail", op: "test_bad", spec: policies.OperatorSpec{MinArgs: 3, MaxArgs: 2}, err: "operator test_bad has invalid arity 3..2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policiestest.RegisterOperator(t, tt.op, tt.spec)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			spec, ok := policies.OperatorSpecOf(tt.op)
+			assert.True(t, ok)
+			assert.Equal(t, tt.spec, spec)
+			assert.Contains(t, policies.Operators(), tt.op)
+		})
+	}
+}
+
+func TestPolicyCondition_Validate_RegisteredOperator(t *testing.T) {
+	policiestest.MustRegisterOperator(t, "test_is_weekday", policies.OperatorSpec{Kind: policies.KindCustom, MinArgs: 1, MaxArgs: 1})
+
+	assert.NoError(t, policies.PolicyCondition{Attribute: "day", Operator: "test_is_weekday"}.Validate())
+	assert.EqualError(t, policies.PolicyCondition{Operator: "test_is_weekday"}.Validate(), "operator test_is_weekday requires attribute")
+	assert.EqualError(t, policies.PolicyCondition{Attribute: "day", Operator: "test_unknown"}.Validate(), "unknown operator: test_unknown")
+}
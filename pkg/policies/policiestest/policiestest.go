@@ -0,0 +1,31 @@
+// Package policiestest provides helpers for tests of code using package
+// policies.
+package policiestest
+
+import (
+	"testing"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies/internal/testhooks"
+)
+
+// RegisterOperator adds op to the operator registry with
+// policies.RegisterOperator for the duration of t: op is removed when t and
+// its subtests complete, so that tests registering operators can run again,
+// as with go test -count.
+func RegisterOperator(t testing.TB, op policies.Operator, spec policies.OperatorSpec) error {
+	t.Helper()
+	if err := policies.RegisterOperator(op, spec); err != nil {
+		return err
+	}
+	t.Cleanup(func() { testhooks.UnregisterOperator(string(op)) })
+	return nil
+}
+
+// MustRegisterOperator is like RegisterOperator but fails t on error.
+func MustRegisterOperator(t testing.TB, op policies.Operator, spec policies.OperatorSpec) {
+	t.Helper()
+	if err := RegisterOperator(t, op, spec); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@ package policies
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
@@ -19,16 +20,28 @@ const (
 
 // Policy represents an access control policy for a resource (and optionally a
 // resource ID). Policies have an effect (allow/deny), an active period and a
-// root condition that determines applicability.
+// root condition that determines applicability. Resource is a pattern that
+// also matches the children of the resources it names; see MatchResource.
 type Policy struct {
-	ID         string               `json:"id,omitempty"`
-	Resource   string               `json:"resource,omitempty"`
-	ResourceID string               `json:"resource_id,omitempty"`
-	Effect     Effect               `json:"effect,omitempty"`
-	Condition  PolicyCondition      `json:"condition,omitempty"`
-	Version    string               `json:"version,omitempty"`
-	DryRun     bool                 `json:"dry_run,omitempty"`
-	Period     *timerange.TimeRange `json:"period,omitempty"`
+	ID         string          `json:"id,omitempty"`
+	Resource   string          `json:"resource,omitempty"`
+	ResourceID string          `json:"resource_id,omitempty"`
+	Effect     Effect          `json:"effect,omitempty"`
+	Condition  PolicyCondition `json:"condition,omitempty"`
+	// Version identifies the content of the policy in decisions, and its
+	// condition for Evaluators built WithVersionKeys. Repositories keeping a
+	// history, such as history.Repository, replace it with the number of the
+	// revision they store.
+	Version string               `json:"version,omitempty"`
+	DryRun  bool                 `json:"dry_run,omitempty"`
+	State   State                `json:"state,omitempty"`
+	Period  *timerange.TimeRange `json:"period,omitempty"`
+	// Subjects and Actions restrict the requests the policy applies to; see
+	// MatchesSubject and MatchesAction. Empty means any.
+	Subjects []string `json:"subjects,omitempty"`
+	Actions  []string `json:"actions,omitempty"`
+	// ExplicitPriority, when set, overrides the score computed by Priority.
+	ExplicitPriority *int `json:"priority,omitempty"`
 }
 
 func (p Policy) IsActiveAt(t time.Time) bool {
@@ -61,12 +74,21 @@ func (p Policy) Validate() error {
 	if p.Resource == "" {
 		return fmt.Errorf("policy resource is required")
 	}
+	if err := validateResourcePattern(p.Resource); err != nil {
+		return err
+	}
 	if p.Effect != EffectAllow && p.Effect != EffectDeny {
 		return fmt.Errorf("invalid effect: %s", p.Effect)
 	}
 	if p.Period == nil {
 		return fmt.Errorf("policy period is required")
 	}
+	if !p.State.Valid() {
+		return fmt.Errorf("invalid state: %s", p.State)
+	}
+	if err := p.validateTargets(); err != nil {
+		return err
+	}
 	return p.Condition.Validate()
 }
 
@@ -102,14 +124,24 @@ func (p Policy) IsScheduled() bool {
 	return p.Period.Start().After(time.Now())
 }
 
-// Matches checks if the policy applies to a given resource and resourceID
+// Matches checks if the policy applies to a given resource and resourceID.
+// The resource is matched with MatchResource; the resource ID must be equal,
+// unless the policy applies to AnyResourceID.
 func (p Policy) Matches(resource, resourceID string) bool {
-	return p.Resource == resource && p.ResourceID == resourceID
+	return p.MatchesResource(resource) &&
+		(p.ResourceID == resourceID || p.ResourceID == AnyResourceID)
 }
 
-// MatchesResource checks if the policy applies to a given resource (ignoring resourceID)
+// MatchesResource checks if the policy applies to a given resource (ignoring
+// resourceID): its Resource, a pattern, matches the resource or one of its
+// ancestors.
 func (p Policy) MatchesResource(resource string) bool {
-	return p.Resource == resource
+	return MatchResource(p.Resource, resource)
+}
+
+// Specificity returns the ResourceSpecificity of the policy resource.
+func (p Policy) Specificity() int {
+	return ResourceSpecificity(p.Resource)
 }
 
 // AppliesTo checks if policy is active and matches the resource
@@ -226,6 +258,12 @@ func (p Policy) Clone() Policy {
 		periodCopy := *p.Period
 		clone.Period = &periodCopy
 	}
+	if p.ExplicitPriority != nil {
+		clone.ExplicitPriority = utils.Ptr(*p.ExplicitPriority)
+	}
+	clone.Subjects = slices.Clone(p.Subjects)
+	clone.Actions = slices.Clone(p.Actions)
+	clone.Condition = p.Condition.Clone()
 	return clone
 }
 
@@ -248,7 +286,12 @@ func (p Policy) HasConflict(other Policy) bool {
 
 // Priority returns a priority score for conflict resolution
 // Higher priority = more specific/restrictive
+// ExplicitPriority is returned as is when set.
 func (p Policy) Priority() int {
+	if p.ExplicitPriority != nil {
+		return *p.ExplicitPriority
+	}
+
 	priority := 0
 
 	// Deny policies have higher priority
@@ -262,9 +305,12 @@ func (p Policy) Priority() int {
 	}
 
 	// Policies with specific resource IDs have higher priority
-	if p.ResourceID != "" && p.ResourceID != "*" {
+	if p.ResourceID != "" && p.ResourceID != AnyResourceID {
 		priority += 25
 	}
 
+	// Policies on more specific resources have higher priority
+	priority += min(p.Specificity(), maxSpecificity)
+
 	return priority
 }
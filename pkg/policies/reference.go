@@ -0,0 +1,214 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// AttributeRef is a condition value referring to another attribute, so that
+// a condition compares two attributes (for example "resource.owner eq
+// subject.id"). In JSON it is written as {"ref": "subject.id"}.
+//
+// References may appear as the whole PolicyCondition.Value or inside it, such
+// as the bounds of a "between" condition. A condition referring to a missing
+// attribute follows the MissingAttributes of the engine, as a condition on a
+// missing attribute does.
+type AttributeRef struct {
+	Ref string `json:"ref"`
+}
+
+// Ref returns an AttributeRef to the attribute at path.
+func Ref(path string) AttributeRef {
+	return AttributeRef{Ref: path}
+}
+
+// RefOf reports whether v is an attribute reference and returns its path. It
+// recognises AttributeRef, *AttributeRef and the map[string]any{"ref": path}
+// form produced by decoding JSON.
+func RefOf(v any) (string, bool) {
+	switch t := v.(type) {
+	case AttributeRef:
+		return t.Ref, true
+	case *AttributeRef:
+		if t == nil {
+			return "", false
+		}
+		return t.Ref, true
+	case map[string]any:
+		if len(t) != 1 {
+			return "", false
+		}
+		path, ok := t["ref"].(string)
+		return path, ok
+	default:
+		return "", false
+	}
+}
+
+// HasRefs reports whether v is or contains an attribute reference or an
+// Arithmetic expression, whose value is only known once evaluated.
+func HasRefs(v any) bool {
+	if _, ok := RefOf(v); ok {
+		return true
+	}
+	if _, ok := ArithmeticOf(v); ok {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if HasRefs(rv.Index(i).Interface()) {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if HasRefs(iter.Value().Interface()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RefPaths returns the paths of every attribute reference in v, including
+// those of Arithmetic expressions.
+func RefPaths(v any) []string {
+	if path, ok := RefOf(v); ok {
+		return []string{path}
+	}
+	if a, ok := ArithmeticOf(v); ok {
+		return a.Paths()
+	}
+
+	var paths []string
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			paths = append(paths, RefPaths(rv.Index(i).Interface())...)
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			paths = append(paths, RefPaths(iter.Value().Interface())...)
+		}
+	}
+	return paths
+}
+
+// ResolveRefs returns v with every attribute reference replaced by the value
+// r resolves for it, and every Arithmetic expression by its value. Slices
+// and maps containing references are copied into []any and map[string]any;
+// values without references are returned as is. A reference to a missing
+// attribute fails with a *MissingReferenceError.
+func ResolveRefs(ctx context.Context, v any, r Resolver) (any, error) {
+	if a, ok := ArithmeticOf(v); ok {
+		return a.Eval(ctx, r)
+	}
+	if path, ok := RefOf(v); ok {
+		val, found, err := ResolveContext(ctx, r, path)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, &MissingReferenceError{Path: path}
+		}
+		return val, nil
+	}
+
+	if !HasRefs(v) {
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		out := make([]any, rv.Len())
+		for i := range out {
+			val, err := ResolveRefs(ctx, rv.Index(i).Interface(), r)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = val
+		}
+		return out, nil
+	case reflect.Map:
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			val, err := ResolveRefs(ctx, iter.Value().Interface(), r)
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprint(iter.Key().Interface())] = val
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// MissingReferenceError reports a reference to a missing attribute. It
+// matches ErrMissingAttribute.
+type MissingReferenceError struct {
+	Path string
+}
+
+func (e *MissingReferenceError) Error() string {
+	return "missing referenced attribute: " + e.Path
+}
+
+// Is matches ErrMissingAttribute.
+func (e *MissingReferenceError) Is(target error) bool {
+	return target == ErrMissingAttribute
+}
+
+// MissingReference returns the result of a condition whose value could not
+// be resolved with err. When err is a *MissingReferenceError, the condition
+// follows the MissingAttributes carried by ctx as if its attribute were
+// missing (see MissingAttribute); other errors are returned as is.
+func MissingReference(ctx context.Context, err error) (bool, error) {
+	var missing *MissingReferenceError
+	if errors.As(err, &missing) {
+		return MissingAttribute(ctx, missing.Path)
+	}
+	return false, err
+}
+
+func validateRefs(v any) error {
+	if path, ok := RefOf(v); ok {
+		if path == "" || strings.Contains(path, "..") ||
+			strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return fmt.Errorf("invalid attribute reference: %q", path)
+		}
+		return nil
+	}
+	if a, ok := ArithmeticOf(v); ok {
+		return a.Validate()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := validateRefs(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := validateRefs(iter.Value().Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
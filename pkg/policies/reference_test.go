@@ -0,0 +1,76 @@
+package policies_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestRefOf(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		path string
+		ok   bool
+	}{
+		{"attribute ref", policies.Ref("subject.id"), "subject.id", true},
+		{"pointer", &policies.AttributeRef{Ref: "subject.id"}, "subject.id", true},
+		{"json map", map[string]any{"ref": "subject.id"}, "subject.id", true},
+		{"map with other keys", map[string]any{"ref": "subject.id", "min": 1}, "", false},
+		{"map with non string ref", map[string]any{"ref": 1}, "", false},
+		{"literal", "subject.id", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, ok := policies.RefOf(tt.in)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.path, path)
+		})
+	}
+}
+
+func TestResolveRefs(t *testing.T) {
+	attr := policies.MapAttributes{"account": map[string]any{"min": 1, "limit": 10}}
+
+	tests := []struct {
+		name string
+		in   any
+		out  any
+		err  string
+	}{
+		{name: "literal", in: []int{1, 2}, out: []int{1, 2}},
+		{name: "whole value", in: policies.Ref("account.limit"), out: 10},
+		{name: "nested in slice", in: []any{0, policies.Ref("account.limit")}, out: []any{0, 10}},
+		{name: "nested in map", in: map[string]any{"min": map[string]any{"ref": "account.min"}, "max": 5}, out: map[string]any{"min": 1, "max": 5}},
+		{name: "missing", in: policies.Ref("account.other"), err: "missing referenced attribute: account.other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := policies.ResolveRefs(context.Background(), tt.in, attr)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
+
+func TestPolicyCondition_Validate_Refs(t *testing.T) {
+	var cond policies.PolicyCondition
+	err := json.Unmarshal([]byte(`{"attribute":"resource.owner","operator":"eq","value":{"ref":"subject.id"}}`), &cond)
+	assert.NoError(t, err)
+	assert.NoError(t, cond.Validate())
+
+	cond.Value = []any{policies.Ref("a"), policies.Ref("b..c")}
+	assert.EqualError(t, cond.Validate(), `invalid attribute reference: "b..c"`)
+
+	cond.Value = policies.Ref("")
+	assert.EqualError(t, cond.Validate(), `invalid attribute reference: ""`)
+}
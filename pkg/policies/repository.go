@@ -0,0 +1,52 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AnyResourceID is the ResourceID of policies applying to every resource ID
+// of their Resource.
+const AnyResourceID = "*"
+
+var (
+	// ErrPolicyNotFound is returned by repositories when no policy has the
+	// requested ID.
+	ErrPolicyNotFound = errors.New("policy not found")
+	// ErrPolicyExists is returned by repositories when adding a policy whose
+	// ID is already used.
+	ErrPolicyExists = errors.New("policy already exists")
+)
+
+type evaluationTimeKey struct{}
+
+// ContextWithEvaluationTime returns a copy of ctx carrying the time a request
+// is evaluated at. The Evaluator sets it when querying its PolicyRepository,
+// so that repositories filtering policies by period use the request time.
+func ContextWithEvaluationTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, evaluationTimeKey{}, t)
+}
+
+// EvaluationTime returns the evaluation time carried by ctx, if any.
+func EvaluationTime(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(evaluationTimeKey{}).(time.Time)
+	return t, ok
+}
+
+type anyPeriodKey struct{}
+
+// ContextWithAnyPeriod returns a copy of ctx asking repositories that filter
+// policies by period to return them whatever the evaluation time, so that
+// the result can be reused across evaluation times, as by a cache. The
+// Evaluator still skips the policies that are not active (see
+// Policy.IsActiveAt).
+func ContextWithAnyPeriod(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anyPeriodKey{}, true)
+}
+
+// AnyPeriod reports whether ctx carries ContextWithAnyPeriod.
+func AnyPeriod(ctx context.Context) bool {
+	ok, _ := ctx.Value(anyPeriodKey{}).(bool)
+	return ok
+}
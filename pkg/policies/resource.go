@@ -0,0 +1,105 @@
+package policies
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Resources are hierarchical paths whose segments are separated by
+// ResourceSeparator, such as "orgs/42/projects/7". The Resource of a policy
+// is a pattern that matches a resource or any of its ancestors, so that
+// policies of a parent are inherited by its children. In patterns, a "*"
+// segment matches exactly one segment, a "**" segment zero or more, and
+// other segments follow path.Match, for example "docs-*".
+const ResourceSeparator = "/"
+
+// maxSpecificity bounds the share of resource specificity in
+// Policy.Priority, so that it never outweighs a specific ResourceID.
+const maxSpecificity = 24
+
+// IsResourcePattern reports whether resource holds wildcards.
+func IsResourcePattern(resource string) bool {
+	return strings.ContainsAny(resource, "*?[")
+}
+
+// ResourceAncestors returns resource followed by its ancestors, most
+// specific first: "orgs/42/projects" gives "orgs/42/projects", "orgs/42" and
+// "orgs".
+func ResourceAncestors(resource string) []string {
+	out := []string{resource}
+	for i := strings.LastIndex(resource, ResourceSeparator); i > 0; i = strings.LastIndex(resource, ResourceSeparator) {
+		resource = resource[:i]
+		out = append(out, resource)
+	}
+	return out
+}
+
+// MatchResource reports whether pattern matches resource or one of its
+// ancestors.
+func MatchResource(pattern, resource string) bool {
+	if !IsResourcePattern(pattern) {
+		return pattern == resource ||
+			strings.HasPrefix(resource, pattern+ResourceSeparator)
+	}
+
+	segments := strings.Split(resource, ResourceSeparator)
+	patterns := strings.Split(pattern, ResourceSeparator)
+	for n := len(segments); n > 0; n-- {
+		if matchSegments(patterns, segments[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceSpecificity scores how specific a resource pattern is: every
+// literal segment counts 3, a segment with wildcards 2, "*" 1 and "**"
+// nothing. Deeper and more literal patterns score higher.
+func ResourceSpecificity(pattern string) int {
+	score := 0
+	for _, seg := range strings.Split(pattern, ResourceSeparator) {
+		switch {
+		case seg == "**":
+		case seg == "*":
+			score++
+		case IsResourcePattern(seg):
+			score += 2
+		default:
+			score += 3
+		}
+	}
+	return score
+}
+
+// validateResourcePattern checks the syntax of the segments of pattern.
+func validateResourcePattern(pattern string) error {
+	for _, seg := range strings.Split(pattern, ResourceSeparator) {
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid resource %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func matchSegments(patterns, segments []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			rest := patterns[1:]
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(patterns[0], segments[0]); !ok {
+			return false
+		}
+		patterns, segments = patterns[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,64 @@
+package policies_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestMatchResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		resource string
+		want     bool
+	}{
+		{name: "exact", pattern: "doc", resource: "doc", want: true},
+		{name: "other", pattern: "doc", resource: "docs", want: false},
+		{name: "inherited by children", pattern: "orgs/42", resource: "orgs/42/projects/7", want: true},
+		{name: "not by siblings", pattern: "orgs/42", resource: "orgs/420", want: false},
+		{name: "not by parents", pattern: "orgs/42/projects", resource: "orgs/42", want: false},
+		{name: "single segment", pattern: "orgs/*/projects", resource: "orgs/42/projects", want: true},
+		{name: "single segment inherited", pattern: "orgs/*/projects", resource: "orgs/42/projects/7/docs/9", want: true},
+		{name: "single segment only", pattern: "orgs/*/projects", resource: "orgs/42/teams/1/projects", want: false},
+		{name: "any depth", pattern: "orgs/**/docs/*", resource: "orgs/42/projects/7/docs/9", want: true},
+		{name: "any depth includes none", pattern: "orgs/**/docs", resource: "orgs/docs", want: true},
+		{name: "trailing any depth", pattern: "orgs/*/projects/**", resource: "orgs/42/projects", want: true},
+		{name: "segment glob", pattern: "orgs/42/doc-*", resource: "orgs/42/doc-9", want: true},
+		{name: "segment glob mismatch", pattern: "orgs/42/doc-*", resource: "orgs/42/img-9", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, policies.MatchResource(tt.pattern, tt.resource))
+		})
+	}
+}
+
+func TestResourceAncestors(t *testing.T) {
+	assert.Equal(t, []string{"orgs/42/projects", "orgs/42", "orgs"}, policies.ResourceAncestors("orgs/42/projects"))
+	assert.Equal(t, []string{"doc"}, policies.ResourceAncestors("doc"))
+}
+
+func TestPolicy_Priority_Specificity(t *testing.T) {
+	priority := func(resource string) int {
+		p := testPolicy("p", policies.EffectAllow, eqCond("role", "admin"))
+		p.Resource = resource
+		return p.Priority()
+	}
+
+	assert.Greater(t, priority("orgs/42/projects/7"), priority("orgs/42/projects/*"))
+	assert.Greater(t, priority("orgs/42/projects/*"), priority("orgs/42"))
+	assert.Greater(t, priority("orgs/42"), priority("orgs/**"))
+
+	deny := testPolicy("p", policies.EffectDeny, eqCond("role", "admin"))
+	deny.Resource = "orgs/**"
+	assert.Greater(t, deny.Priority(), priority("orgs/1/projects/2/docs/3/pages/4/lines/5"), "specificity never outweighs the effect")
+}
+
+func TestPolicy_Validate_Resource(t *testing.T) {
+	p := testPolicy("p", policies.EffectAllow, eqCond("role", "admin"))
+	p.Resource = "orgs/[/projects"
+	assert.ErrorContains(t, p.Validate(), `invalid resource "orgs/[/projects"`)
+}
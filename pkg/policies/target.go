@@ -0,0 +1,129 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Wildcard matches any subject or action. A pattern ending with it
+	// matches values starting with the rest of the pattern, for example
+	// "documents:*" matches "documents:read".
+	Wildcard = "*"
+	// GroupPrefix marks the entries of Policy.Subjects naming a group of
+	// subjects, for example "group:admins".
+	GroupPrefix = "group:"
+)
+
+// Subject is who makes a request: an ID and the groups it belongs to.
+type Subject struct {
+	ID     string   `json:"id,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Target is what a request is about: who does what on which resource.
+type Target struct {
+	Subject    Subject `json:"subject"`
+	Action     string  `json:"action,omitempty"`
+	Resource   string  `json:"resource"`
+	ResourceID string  `json:"resource_id,omitempty"`
+}
+
+// TargetRepository is a PolicyRepository able to narrow its lookups by
+// subject and action as well as by resource. The Evaluator uses
+// FindByTarget when its repository implements it.
+type TargetRepository interface {
+	PolicyRepository
+	// FindByTarget returns the policies FindByResourceAndResourceID returns
+	// for the resource of t that also match its subject and action (see
+	// Policy.MatchesTarget).
+	FindByTarget(ctx context.Context, t Target) ([]Policy, error)
+}
+
+// FindByTarget returns the policies of repo for t, using FindByTarget when
+// repo is a TargetRepository and filtering the policies of the resource of t
+// otherwise.
+func FindByTarget(ctx context.Context, repo PolicyRepository, t Target) ([]Policy, error) {
+	if tr, ok := repo.(TargetRepository); ok {
+		return tr.FindByTarget(ctx, t)
+	}
+
+	pols, err := repo.FindByResourceAndResourceID(ctx, t.Resource, t.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	var out []Policy
+	for _, p := range pols {
+		if p.MatchesTarget(t) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// MatchesTarget reports whether the policy targets the subject and the
+// action of t. Its resource is matched by repository lookups.
+func (p Policy) MatchesTarget(t Target) bool {
+	return p.MatchesSubject(t.Subject) && p.MatchesAction(t.Action)
+}
+
+// MatchesSubject reports whether the policy targets s: its Subjects are empty
+// or one of them matches the ID of s or, with GroupPrefix, one of its groups.
+func (p Policy) MatchesSubject(s Subject) bool {
+	if len(p.Subjects) == 0 {
+		return true
+	}
+	for _, pattern := range p.Subjects {
+		if group, ok := strings.CutPrefix(pattern, GroupPrefix); ok {
+			for _, g := range s.Groups {
+				if matchPattern(group, g) {
+					return true
+				}
+			}
+			continue
+		}
+		if matchPattern(pattern, s.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesAction reports whether the policy targets action: its Actions are
+// empty or one of them matches action.
+func (p Policy) MatchesAction(action string) bool {
+	if len(p.Actions) == 0 {
+		return true
+	}
+	for _, pattern := range p.Actions {
+		if matchPattern(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTargets checks that no subject or action pattern is empty.
+func (p Policy) validateTargets() error {
+	for _, s := range p.Subjects {
+		if s == "" || s == GroupPrefix {
+			return fmt.Errorf("invalid subject: %q", s)
+		}
+	}
+	for _, a := range p.Actions {
+		if a == "" {
+			return fmt.Errorf("invalid action: %q", a)
+		}
+	}
+	return nil
+}
+
+// matchPattern matches value against pattern, which is Wildcard, a prefix
+// followed by Wildcard, or a literal.
+func matchPattern(pattern, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, Wildcard); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+	return pattern == value
+}
@@ -0,0 +1,68 @@
+package policies_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+func TestPolicy_MatchesTarget(t *testing.T) {
+	alice := policies.Subject{ID: "alice", Groups: []string{"admins", "team-a"}}
+
+	tests := []struct {
+		name     string
+		subjects []string
+		actions  []string
+		target   policies.Target
+		want     bool
+	}{
+		{name: "no targeting", target: policies.Target{Subject: alice, Action: "read"}, want: true},
+		{name: "subject id", subjects: []string{"bob", "alice"}, target: policies.Target{Subject: alice}, want: true},
+		{name: "other subject", subjects: []string{"bob"}, target: policies.Target{Subject: alice}},
+		{name: "group", subjects: []string{"group:admins"}, target: policies.Target{Subject: alice}, want: true},
+		{name: "group wildcard", subjects: []string{"group:team-*"}, target: policies.Target{Subject: alice}, want: true},
+		{name: "group is not an id", subjects: []string{"group:alice"}, target: policies.Target{Subject: alice}},
+		{name: "any subject", subjects: []string{"*"}, target: policies.Target{}, want: true},
+		{name: "anonymous", subjects: []string{"alice"}, target: policies.Target{}},
+		{name: "action", actions: []string{"read", "write"}, target: policies.Target{Action: "write"}, want: true},
+		{name: "action prefix", actions: []string{"documents:*"}, target: policies.Target{Action: "documents:read"}, want: true},
+		{name: "other action", actions: []string{"documents:*"}, target: policies.Target{Action: "users:read"}},
+		{name: "subject and action", subjects: []string{"alice"}, actions: []string{"read"}, target: policies.Target{Subject: alice, Action: "write"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policies.Policy{Subjects: tt.subjects, Actions: tt.actions}
+			assert.Equal(t, tt.want, p.MatchesTarget(tt.target))
+		})
+	}
+}
+
+func TestFindByTarget(t *testing.T) {
+	read := testPolicy("read", policies.EffectAllow, eqCond("role", "admin"))
+	read.Actions = []string{"read"}
+	write := testPolicy("write", policies.EffectAllow, eqCond("role", "admin"))
+	write.Actions = []string{"write"}
+	repo := staticRepo(read, write)
+
+	pols, err := policies.FindByTarget(context.Background(), repo, policies.Target{Resource: "doc", Action: "write"})
+	assert.NoError(t, err)
+	if assert.Len(t, pols, 1) {
+		assert.Equal(t, "write", pols[0].ID)
+	}
+
+	pols, _ = policies.FindByTarget(context.Background(), repo, policies.Target{Resource: "doc", Action: "read"})
+	assert.Equal(t, "read", pols[0].ID, "the repository results are left untouched")
+}
+
+func TestPolicy_Validate_Targets(t *testing.T) {
+	p := testPolicy("p", policies.EffectAllow, eqCond("role", "admin"))
+	p.Subjects = []string{"group:"}
+	assert.EqualError(t, p.Validate(), `invalid subject: "group:"`)
+
+	p.Subjects = nil
+	p.Actions = []string{""}
+	assert.EqualError(t, p.Validate(), `invalid action: ""`)
+}
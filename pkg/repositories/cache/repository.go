@@ -0,0 +1,308 @@
+// Package cache provides a caching decorator for any policies.PolicyRepository.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+const (
+	// DefaultTTL is the time results are cached unless WithTTL is used.
+	DefaultTTL = 30 * time.Second
+	// DefaultMaxEntries is the number of results cached unless
+	// WithMaxEntries is used.
+	DefaultMaxEntries = 10000
+)
+
+// Stats reports the activity of a Repository. NegativeHits counts the hits
+// on cached empty results and Shared the misses served by a concurrent
+// lookup of the same key.
+type Stats struct {
+	Hits         uint64 `json:"hits"`
+	NegativeHits uint64 `json:"negative_hits"`
+	Misses       uint64 `json:"misses"`
+	Shared       uint64 `json:"shared"`
+	Evictions    uint64 `json:"evictions"`
+	Size         int    `json:"size"`
+}
+
+// Repository caches the results of FindByResourceAndResourceID of another
+// PolicyRepository. Results are kept for a TTL, the least recently used ones
+// are evicted beyond the maximum number of entries, and concurrent misses on
+// the same resource and resource ID share a single lookup, made with the
+// context of the first caller. When that context ends during the lookup, the
+// callers waiting for it retry with their own. Errors are never cached.
+//
+// Entries are keyed by resource and resource ID only, so lookups ask the
+// decorated repository for the policies of every period (see
+// policies.ContextWithAnyPeriod) and results hold policies whatever their
+// period: the Evaluator skips those not active at the request time, and other
+// callers should check Policy.IsActiveAt. Call InvalidateResource or
+// InvalidateAll when policies change, for example from a reload subscription.
+type Repository struct {
+	repo        policies.PolicyRepository
+	ttl         time.Duration
+	negativeTTL *time.Duration
+	maxEntries  int
+	now         func() time.Time
+
+	mu       sync.Mutex
+	order    *list.List
+	entries  map[key]*list.Element
+	inflight map[key]*call
+	stats    Stats
+}
+
+type key struct {
+	resource   string
+	resourceID string
+}
+
+type entry struct {
+	key     key
+	pols    []policies.Policy
+	expires time.Time
+}
+
+// call is a lookup in progress, shared by concurrent misses. canceled is set
+// when it failed because the context of its caller ended.
+type call struct {
+	done     chan struct{}
+	pols     []policies.Policy
+	err      error
+	canceled bool
+}
+
+// Option configures a Repository built by NewRepository.
+type Option func(*Repository)
+
+// WithTTL sets the time results are cached.
+func WithTTL(d time.Duration) Option {
+	return func(r *Repository) {
+		r.ttl = d
+	}
+}
+
+// WithNegativeTTL sets the time empty results are cached. It defaults to the
+// TTL; zero or less disables negative caching.
+func WithNegativeTTL(d time.Duration) Option {
+	return func(r *Repository) {
+		r.negativeTTL = &d
+	}
+}
+
+// WithMaxEntries sets the number of results cached.
+func WithMaxEntries(n int) Option {
+	return func(r *Repository) {
+		r.maxEntries = n
+	}
+}
+
+// WithClock sets the clock used to expire entries. It defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(r *Repository) {
+		r.now = now
+	}
+}
+
+// NewRepository returns a Repository caching the results of repo.
+func NewRepository(repo policies.PolicyRepository, opts ...Option) *Repository {
+	r := &Repository{
+		repo:       repo,
+		ttl:        DefaultTTL,
+		maxEntries: DefaultMaxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[key]*list.Element),
+		inflight:   make(map[key]*call),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.negativeTTL == nil {
+		r.negativeTTL = &r.ttl
+	}
+	return r
+}
+
+// FindByResourceAndResourceID returns the cached policies of resource and
+// resourceID, looking them up in the decorated repository on a miss.
+func (r *Repository) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	k := key{resource: resource, resourceID: resourceID}
+	for {
+		c, err := r.find(ctx, k)
+		if err != nil {
+			return nil, err
+		}
+		if c.canceled && ctx.Err() == nil {
+			continue
+		}
+		if c.err != nil {
+			return nil, c.err
+		}
+		return clone(c.pols), nil
+	}
+}
+
+// find returns the completed lookup of k, cached or shared with concurrent
+// misses, or the error of ctx when it ends first.
+func (r *Repository) find(ctx context.Context, k key) (*call, error) {
+	r.mu.Lock()
+	if pols, ok := r.get(k); ok {
+		r.mu.Unlock()
+		return &call{pols: pols}, nil
+	}
+	r.stats.Misses++
+
+	c, shared := r.inflight[k]
+	if shared {
+		r.stats.Shared++
+	} else {
+		c = &call{done: make(chan struct{})}
+		r.inflight[k] = c
+	}
+	r.mu.Unlock()
+
+	if !shared {
+		c.pols, c.err = r.repo.FindByResourceAndResourceID(policies.ContextWithAnyPeriod(ctx), k.resource, k.resourceID)
+		c.canceled = c.err != nil && ctx.Err() != nil
+
+		r.mu.Lock()
+		// An invalidation during the lookup removes the call: its result may
+		// be stale and is not cached.
+		if r.inflight[k] == c {
+			delete(r.inflight, k)
+			if c.err == nil {
+				r.add(k, c.pols)
+			}
+		}
+		r.mu.Unlock()
+		close(c.done)
+	}
+
+	select {
+	case <-c.done:
+		return c, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// FindByTarget returns the cached policies of the resource of t that target
+// its subject and action. Results are cached by resource, so that every
+// subject and action share them.
+func (r *Repository) FindByTarget(ctx context.Context, t policies.Target) ([]policies.Policy, error) {
+	pols, err := r.FindByResourceAndResourceID(ctx, t.Resource, t.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	out := pols[:0]
+	for _, p := range pols {
+		if p.MatchesTarget(t) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// InvalidateResource removes the cached results of every resource ID of
+// resource and of its children, which inherit its policies. resource can be
+// a pattern, as accepted by policies.MatchResource.
+func (r *Repository) InvalidateResource(resource string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, el := range r.entries {
+		if policies.MatchResource(resource, k.resource) {
+			r.remove(el)
+		}
+	}
+	for k := range r.inflight {
+		if policies.MatchResource(resource, k.resource) {
+			delete(r.inflight, k)
+		}
+	}
+}
+
+// InvalidateAll removes every cached result.
+func (r *Repository) InvalidateAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.order.Init()
+	r.entries = make(map[key]*list.Element)
+	r.inflight = make(map[key]*call)
+}
+
+// Stats returns the cache statistics.
+func (r *Repository) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats
+	stats.Size = r.order.Len()
+	return stats
+}
+
+// get returns the unexpired result cached for k, counting the hit.
+func (r *Repository) get(k key) ([]policies.Policy, bool) {
+	el, ok := r.entries[k]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !r.now().Before(e.expires) {
+		r.remove(el)
+		return nil, false
+	}
+
+	r.order.MoveToFront(el)
+	if len(e.pols) == 0 {
+		r.stats.NegativeHits++
+	} else {
+		r.stats.Hits++
+	}
+	return e.pols, true
+}
+
+func (r *Repository) add(k key, pols []policies.Policy) {
+	ttl := r.ttl
+	if len(pols) == 0 {
+		ttl = *r.negativeTTL
+	}
+	if ttl <= 0 || r.maxEntries <= 0 {
+		return
+	}
+
+	e := &entry{key: k, pols: clone(pols), expires: r.now().Add(ttl)}
+	if el, ok := r.entries[k]; ok {
+		el.Value = e
+		r.order.MoveToFront(el)
+		return
+	}
+	r.entries[k] = r.order.PushFront(e)
+	for r.order.Len() > r.maxEntries {
+		r.remove(r.order.Back())
+		r.stats.Evictions++
+	}
+}
+
+func (r *Repository) remove(el *list.Element) {
+	r.order.Remove(el)
+	delete(r.entries, el.Value.(*entry).key)
+}
+
+func clone(pols []policies.Policy) []policies.Policy {
+	if pols == nil {
+		return nil
+	}
+	out := make([]policies.Policy, len(pols))
+	for i, p := range pols {
+		out[i] = p.Clone()
+	}
+	return out
+}
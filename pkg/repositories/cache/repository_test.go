@@ -0,0 +1,189 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/cache"
+)
+
+// countingRepo returns one policy per resource ID, except for "none", and
+// counts its lookups. Lookups block on wait when it is set.
+type countingRepo struct {
+	calls atomic.Int64
+	wait  chan struct{}
+	err   error
+}
+
+func (r *countingRepo) FindByResourceAndResourceID(_ context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	r.calls.Add(1)
+	if r.wait != nil {
+		<-r.wait
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if resourceID == "none" {
+		return nil, nil
+	}
+	return []policies.Policy{{ID: resource + "/" + resourceID, Resource: resource, ResourceID: resourceID}}, nil
+}
+
+func TestRepository_TTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	inner := &countingRepo{}
+	repo := cache.NewRepository(inner,
+		cache.WithTTL(time.Minute),
+		cache.WithNegativeTTL(time.Second),
+		cache.WithClock(func() time.Time { return now }),
+	)
+
+	for i := 0; i < 3; i++ {
+		pols, err := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+		assert.NoError(t, err)
+		assert.Equal(t, "doc/1", pols[0].ID)
+		_, err = repo.FindByResourceAndResourceID(ctx, "doc", "none")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(2), inner.calls.Load())
+	assert.Equal(t, cache.Stats{Hits: 2, NegativeHits: 2, Misses: 2, Size: 2}, repo.Stats())
+
+	now = now.Add(2 * time.Second)
+	_, _ = repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	_, _ = repo.FindByResourceAndResourceID(ctx, "doc", "none")
+	assert.Equal(t, int64(3), inner.calls.Load(), "negative entries expire first")
+
+	now = now.Add(time.Minute)
+	_, _ = repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.Equal(t, int64(4), inner.calls.Load())
+}
+
+func TestRepository_MaxEntries(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingRepo{}
+	repo := cache.NewRepository(inner, cache.WithMaxEntries(2))
+
+	for _, id := range []string{"1", "2", "1", "3", "2"} {
+		_, err := repo.FindByResourceAndResourceID(ctx, "doc", id)
+		assert.NoError(t, err)
+	}
+
+	// 1 miss, 2 miss, 1 hit, 3 miss evicting 2, 2 miss evicting 1.
+	assert.Equal(t, int64(4), inner.calls.Load())
+	stats := repo.Stats()
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, 2, stats.Size)
+}
+
+func TestRepository_Errors(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingRepo{err: errors.New("database down")}
+	repo := cache.NewRepository(inner)
+
+	_, err := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.EqualError(t, err, "database down")
+	_, err = repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.EqualError(t, err, "database down")
+	assert.Equal(t, int64(2), inner.calls.Load(), "errors are not cached")
+}
+
+func TestRepository_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingRepo{}
+	repo := cache.NewRepository(inner)
+
+	_, _ = repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	_, _ = repo.FindByResourceAndResourceID(ctx, "doc", "2")
+	_, _ = repo.FindByResourceAndResourceID(ctx, "doc/pages", "1")
+	_, _ = repo.FindByResourceAndResourceID(ctx, "img", "1")
+
+	repo.InvalidateResource("doc")
+	assert.Equal(t, 1, repo.Stats().Size, "children are invalidated with their parent")
+	_, _ = repo.FindByResourceAndResourceID(ctx, "img", "1")
+	_, _ = repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.Equal(t, int64(5), inner.calls.Load())
+
+	repo.InvalidateAll()
+	assert.Equal(t, 0, repo.Stats().Size)
+}
+
+func TestRepository_SharesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingRepo{wait: make(chan struct{})}
+	repo := cache.NewRepository(inner)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pols, err := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+			assert.NoError(t, err)
+			assert.Len(t, pols, 1)
+		}()
+	}
+
+	assert.Eventually(t, func() bool { return repo.Stats().Misses == 10 }, 5*time.Second, time.Millisecond)
+	close(inner.wait)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), inner.calls.Load())
+	assert.Equal(t, uint64(9), repo.Stats().Shared)
+}
+
+// cancelingRepo blocks its first lookup until the context of the caller ends.
+type cancelingRepo struct {
+	calls atomic.Int64
+}
+
+func (r *cancelingRepo) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	if r.calls.Add(1) == 1 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return []policies.Policy{{ID: resource + "/" + resourceID, Resource: resource, ResourceID: resourceID}}, nil
+}
+
+func TestRepository_SharedMissOutlivesCanceledCaller(t *testing.T) {
+	inner := &cancelingRepo{}
+	repo := cache.NewRepository(inner)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	leader := make(chan error, 1)
+	go func() {
+		_, err := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+		leader <- err
+	}()
+	assert.Eventually(t, func() bool { return inner.calls.Load() == 1 }, 5*time.Second, time.Millisecond)
+
+	waiter := make(chan []policies.Policy, 1)
+	go func() {
+		pols, err := repo.FindByResourceAndResourceID(context.Background(), "doc", "1")
+		assert.NoError(t, err)
+		waiter <- pols
+	}()
+	assert.Eventually(t, func() bool { return repo.Stats().Shared == 1 }, 5*time.Second, time.Millisecond)
+	cancel()
+
+	assert.ErrorIs(t, <-leader, context.Canceled)
+	assert.Len(t, <-waiter, 1)
+	assert.Equal(t, int64(2), inner.calls.Load())
+}
+
+func TestRepository_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := cache.NewRepository(&countingRepo{})
+
+	pols, _ := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	pols[0].ID = "changed"
+
+	pols, _ = repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.Equal(t, "doc/1", pols[0].ID)
+}
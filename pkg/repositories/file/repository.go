@@ -0,0 +1,260 @@
+// Package file provides a policies.PolicyRepository loading policies from a
+// directory tree of JSON and YAML files.
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/memory"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadError reports an invalid policy file. Pointer is the JSON pointer
+// (RFC 6901) of the invalid value within the file, empty for the whole
+// document.
+type LoadError struct {
+	File    string
+	Pointer string
+	Err     error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("%s#%s: %v", e.File, e.Pointer, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// Repository is a PolicyRepository serving the policies loaded from a file
+// system. Policies are read when the Repository is created and on Reload.
+type Repository struct {
+	fsys fs.FS
+	repo *memory.Repository
+}
+
+// NewRepository loads the policies of fsys. See Load for the layout.
+func NewRepository(fsys fs.FS) (*Repository, error) {
+	r := &Repository{fsys: fsys, repo: &memory.Repository{}}
+	if err := r.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewDirRepository loads the policies of the directory dir.
+func NewDirRepository(dir string) (*Repository, error) {
+	return NewRepository(os.DirFS(dir))
+}
+
+// Reload loads the policies again and replaces the served set atomically.
+// When loading fails, the previous set is kept and the error is returned.
+func (r *Repository) Reload(ctx context.Context) error {
+	pols, err := Load(r.fsys)
+	if err != nil {
+		return err
+	}
+	return r.repo.Replace(ctx, pols)
+}
+
+// FindByResourceAndResourceID returns the policies of resource whose
+// ResourceID is resourceID, followed by those applying to any resource ID.
+func (r *Repository) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	return r.repo.FindByResourceAndResourceID(ctx, resource, resourceID)
+}
+
+// FindByTarget returns the policies of the resource of t that target its
+// subject and action.
+func (r *Repository) FindByTarget(ctx context.Context, t policies.Target) ([]policies.Policy, error) {
+	return r.repo.FindByTarget(ctx, t)
+}
+
+// Get returns the policy with id, or policies.ErrPolicyNotFound.
+func (r *Repository) Get(ctx context.Context, id string) (policies.Policy, error) {
+	return r.repo.Get(ctx, id)
+}
+
+// List returns every policy, in file order.
+func (r *Repository) List(ctx context.Context) ([]policies.Policy, error) {
+	return r.repo.List(ctx)
+}
+
+// Load reads every .json, .yaml and .yml file of fsys, in lexical order and
+// skipping files and directories whose name starts with a dot. A file holds
+// a single policy or an array of policies. Every policy must have an ID,
+// unique across files, and pass Policy.Validate.
+//
+// All the invalid policies are reported, joined, as *LoadError values.
+func Load(fsys fs.FS) ([]policies.Policy, error) {
+	var (
+		pols  []policies.Policy
+		errs  []error
+		files = make(map[string]string)
+	)
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isPolicyFile(name) {
+			return nil
+		}
+
+		loaded, err := loadFile(fsys, name)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		for _, lp := range loaded {
+			if lp.err != nil {
+				errs = append(errs, lp.err)
+				continue
+			}
+			if other, ok := files[lp.policy.ID]; ok {
+				errs = append(errs, &LoadError{File: name, Pointer: lp.pointer + "/id",
+					Err: fmt.Errorf("policy %s also defined in %s: %w", lp.policy.ID, other, policies.ErrPolicyExists)})
+				continue
+			}
+			files[lp.policy.ID] = name
+			pols = append(pols, lp.policy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return pols, nil
+}
+
+func isPolicyFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// loadedPolicy is a policy decoded from a file, or the error found for it.
+type loadedPolicy struct {
+	policy  policies.Policy
+	pointer string
+	err     error
+}
+
+// loadFile decodes the policies of the file name. The returned error is set
+// when the file as a whole cannot be read.
+func loadFile(fsys fs.FS, name string) ([]loadedPolicy, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, &LoadError{File: name, Err: err}
+	}
+
+	if ext := strings.ToLower(path.Ext(name)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, &LoadError{File: name, Err: err}
+		}
+		if data == nil {
+			return nil, nil
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '[' {
+		return []loadedPolicy{decodePolicy(name, "", data)}, nil
+	}
+
+	var docs []json.RawMessage
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, &LoadError{File: name, Err: err}
+	}
+	out := make([]loadedPolicy, len(docs))
+	for i, doc := range docs {
+		out[i] = decodePolicy(name, fmt.Sprintf("/%d", i), doc)
+	}
+	return out, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so that policies are decoded
+// with their JSON tags. An empty document is returned as nil.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func decodePolicy(name, pointer string, data []byte) loadedPolicy {
+	lp := loadedPolicy{pointer: pointer}
+	fail := func(ptr string, err error) loadedPolicy {
+		lp.err = &LoadError{File: name, Pointer: ptr, Err: err}
+		return lp
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	// Literals stay json.Number, so that decimals compare exactly.
+	dec.UseNumber()
+	if err := dec.Decode(&lp.policy); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return fail(pointer+fieldPointer(typeErr.Field), err)
+		}
+		return fail(pointer, err)
+	}
+
+	if lp.policy.ID == "" {
+		return fail(pointer+"/id", fmt.Errorf("policy id is required"))
+	}
+	if err := lp.policy.Validate(); err != nil {
+		if lp.policy.Condition.Validate() != nil {
+			return fail(pointer+conditionPointer(lp.policy.Condition, "/condition"), err)
+		}
+		return fail(pointer, err)
+	}
+	return lp
+}
+
+// conditionPointer returns the pointer of the deepest invalid condition of c,
+// which is at pointer.
+func conditionPointer(c policies.PolicyCondition, pointer string) string {
+	for i, child := range c.Conditions {
+		if child.Validate() != nil {
+			return conditionPointer(child, fmt.Sprintf("%s/conditions/%d", pointer, i))
+		}
+	}
+	return pointer
+}
+
+// fieldPointer converts the dotted field path of a JSON decoding error to a
+// JSON pointer.
+func fieldPointer(field string) string {
+	var sb strings.Builder
+	for _, part := range strings.Split(field, ".") {
+		sb.WriteString("/")
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(part))
+	}
+	return sb.String()
+}
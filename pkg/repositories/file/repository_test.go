@@ -0,0 +1,231 @@
+package file_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/file"
+)
+
+const adminJSON = `{
+	"id": "allow-admin",
+	"resource": "doc",
+	"effect": "allow",
+	"period": {"start": "2020-01-01T00:00:00Z"},
+	"condition": {"attribute": "role", "operator": "eq", "value": "admin"}
+}`
+
+const denyYAML = `
+- id: deny-blocked
+  resource: doc
+  resource_id: "*"
+  effect: deny
+  period:
+    start: 2020-01-01T00:00:00Z
+  condition:
+    attribute: blocked
+    operator: eq
+    value: true
+- id: deny-guest
+  resource: doc
+  resource_id: "1"
+  effect: deny
+  period:
+    start: 2020-01-01T00:00:00Z
+  condition:
+    operator: and
+    conditions:
+      - attribute: role
+        operator: eq
+        value: guest
+      - attribute: blocked
+        operator: eq
+        value: false
+`
+
+func ids(pols []policies.Policy) []string {
+	out := make([]string, len(pols))
+	for i, p := range pols {
+		out[i] = p.ID
+	}
+	return out
+}
+
+func loadErrors(err error) []*file.LoadError {
+	var out []*file.LoadError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			var le *file.LoadError
+			if errors.As(e, &le) {
+				out = append(out, le)
+			}
+		}
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/admin.json":     {Data: []byte(adminJSON)},
+		"b/deny.yaml":      {Data: []byte(denyYAML)},
+		"b/empty.yml":      {Data: []byte("")},
+		"README.md":        {Data: []byte("# policies")},
+		".git/config.json": {Data: []byte("{")},
+		"b/.draft.json":    {Data: []byte("{")},
+		"c/nested/ok.json": {Data: []byte(`[]`)},
+	}
+
+	pols, err := file.Load(fsys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"allow-admin", "deny-blocked", "deny-guest"}, ids(pols))
+	assert.Equal(t, policies.EffectDeny, pols[1].Effect)
+	assert.Equal(t, policies.AnyResourceID, pols[1].ResourceID)
+	assert.Equal(t, "guest", pols[2].Condition.Conditions[0].Value)
+}
+
+func TestLoad_Decimals(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cents.json": {Data: []byte(`{
+			"id": "whole-cents",
+			"resource": "invoice",
+			"effect": "allow",
+			"period": {"start": "2020-01-01T00:00:00Z"},
+			"condition": {"attribute": "amount", "operator": "mod", "value": 0.01}
+		}`)},
+		"cents.yaml": {Data: []byte(`
+id: whole-cents-yaml
+resource: invoice
+effect: allow
+period:
+  start: 2020-01-01T00:00:00Z
+condition:
+  attribute: amount
+  operator: mod
+  value: 0.01
+`)},
+	}
+
+	pols, err := file.Load(fsys)
+	if !assert.NoError(t, err) {
+		return
+	}
+	engine := native.NewNativeEngine()
+	for _, p := range pols {
+		assert.Equal(t, json.Number("0.01"), p.Condition.Value, p.ID)
+		ok, err := engine.Eval(p.Condition, policies.MapAttributes{"amount": json.Number("10.20")})
+		assert.NoError(t, err, p.ID)
+		assert.True(t, ok, p.ID)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	type output struct {
+		file    string
+		pointer string
+		msg     string
+	}
+
+	tests := []struct {
+		name   string
+		fsys   fstest.MapFS
+		output []output
+	}{
+		{
+			name:   "invalid json",
+			fsys:   fstest.MapFS{"bad.json": {Data: []byte(`{"id": `)}},
+			output: []output{{file: "bad.json", msg: "unexpected EOF"}},
+		},
+		{
+			name:   "wrong field type",
+			fsys:   fstest.MapFS{"bad.json": {Data: []byte(`[` + adminJSON + `, {"id": "x", "condition": {"conditions": [{"attribute": 1}]}}]`)}},
+			output: []output{{file: "bad.json", pointer: "/1/condition/conditions/0/attribute", msg: "cannot unmarshal number"}},
+		},
+		{
+			name:   "unknown field",
+			fsys:   fstest.MapFS{"bad.yaml": {Data: []byte("id: x\nefect: allow\n")}},
+			output: []output{{file: "bad.yaml", msg: `unknown field "efect"`}},
+		},
+		{
+			name:   "missing id",
+			fsys:   fstest.MapFS{"bad.json": {Data: []byte(`{"resource": "doc"}`)}},
+			output: []output{{file: "bad.json", pointer: "/id", msg: "policy id is required"}},
+		},
+		{
+			name:   "invalid nested condition",
+			fsys:   fstest.MapFS{"bad.yaml": {Data: []byte(denyYAML + "      - operator: eq\n        value: 1\n")}},
+			output: []output{{file: "bad.yaml", pointer: "/1/condition/conditions/2", msg: "operator eq requires attribute"}},
+		},
+		{
+			name:   "invalid policy",
+			fsys:   fstest.MapFS{"bad.json": {Data: []byte(`{"id": "x", "resource": "doc", "effect": "allow", "condition": {"attribute": "a", "operator": "eq", "value": 1}}`)}},
+			output: []output{{file: "bad.json", msg: "policy period is required"}},
+		},
+		{
+			name: "duplicated ids across files",
+			fsys: fstest.MapFS{
+				"a.json": {Data: []byte(adminJSON)},
+				"b.json": {Data: []byte(`[` + adminJSON + `]`)},
+			},
+			output: []output{{file: "b.json", pointer: "/0/id", msg: "also defined in a.json"}},
+		},
+		{
+			name: "every error is reported",
+			fsys: fstest.MapFS{
+				"a.json": {Data: []byte(`{`)},
+				"b.json": {Data: []byte(`{"resource": "doc"}`)},
+			},
+			output: []output{{file: "a.json", msg: "unexpected EOF"}, {file: "b.json", pointer: "/id", msg: "id is required"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := file.Load(tt.fsys)
+			errs := loadErrors(err)
+			if !assert.Len(t, errs, len(tt.output), "error: %v", err) {
+				return
+			}
+			for i, expected := range tt.output {
+				assert.Equal(t, expected.file, errs[i].File)
+				assert.Equal(t, expected.pointer, errs[i].Pointer)
+				assert.ErrorContains(t, errs[i], expected.msg)
+			}
+		})
+	}
+}
+
+func TestRepository_Reload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	write := func(name, data string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+	write("admin.json", adminJSON)
+
+	repo, err := file.NewDirRepository(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	pols, _ := repo.FindByResourceAndResourceID(ctx, "doc", "")
+	assert.Equal(t, []string{"allow-admin"}, ids(pols))
+
+	write("deny.yaml", denyYAML)
+	assert.NoError(t, repo.Reload(ctx))
+	pols, _ = repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.Equal(t, []string{"deny-guest", "deny-blocked"}, ids(pols))
+
+	write("broken.json", `{`)
+	assert.Error(t, repo.Reload(ctx))
+	pols, _ = repo.List(ctx)
+	assert.Equal(t, []string{"allow-admin", "deny-blocked", "deny-guest"}, ids(pols), "failed reload keeps the previous set")
+}
@@ -0,0 +1,456 @@
+// Package history provides a policies.PolicyRepository decorator keeping an
+// immutable revision for every change to a policy, so that earlier versions
+// can be inspected, compared and restored.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// ErrRevisionNotFound is returned when a policy has no revision with the
+// requested number, or none at the requested time.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Change describes who made a change and why. It is attached to writes with
+// ContextWithChange.
+type Change struct {
+	Author string `json:"author,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+type changeKey struct{}
+
+// ContextWithChange returns a copy of ctx carrying the author and note
+// recorded in the revisions created by writes made with it.
+func ContextWithChange(ctx context.Context, c Change) context.Context {
+	return context.WithValue(ctx, changeKey{}, c)
+}
+
+// ChangeFromContext returns the change carried by ctx, if any.
+func ChangeFromContext(ctx context.Context) (Change, bool) {
+	c, ok := ctx.Value(changeKey{}).(Change)
+	return c, ok
+}
+
+// Revision is an immutable version of a policy. Numbers start at 1 and
+// increase by one on every change of the policy. A deleted policy has a last
+// revision with Deleted set, holding the content it had when deleted.
+type Revision struct {
+	PolicyID string          `json:"policy_id"`
+	Number   int             `json:"number"`
+	Author   string          `json:"author,omitempty"`
+	Note     string          `json:"note,omitempty"`
+	At       time.Time       `json:"at"`
+	Deleted  bool            `json:"deleted,omitempty"`
+	Policy   policies.Policy `json:"policy"`
+}
+
+// Difference is a field whose value differs between two revisions. Field is
+// the JSON name of the policy field, and From and To its JSON values, nil
+// when the field is unset.
+type Difference struct {
+	Field string `json:"field"`
+	From  any    `json:"from,omitempty"`
+	To    any    `json:"to,omitempty"`
+}
+
+// Store is a policy repository that can be written to, such as
+// memory.Repository or sqldb.Repository.
+type Store interface {
+	policies.PolicyRepository
+	Get(ctx context.Context, id string) (policies.Policy, error)
+	List(ctx context.Context) ([]policies.Policy, error)
+	Add(ctx context.Context, p policies.Policy) error
+	Update(ctx context.Context, p policies.Policy) error
+	Delete(ctx context.Context, id string) error
+}
+
+// RevisionStore stores the revisions of policies. A store that outlives the
+// process, such as a database table, keeps the history across restarts.
+type RevisionStore interface {
+	// Revisions returns the revisions of the policy with policyID, oldest
+	// first, or none when it has no history.
+	Revisions(ctx context.Context, policyID string) ([]Revision, error)
+	// AppendRevision stores rev as the last revision of its policy.
+	AppendRevision(ctx context.Context, rev Revision) error
+	// RemoveRevision removes rev, the last revision of its policy, appended
+	// for a write the Store then failed.
+	RemoveRevision(ctx context.Context, rev Revision) error
+}
+
+// MemoryRevisionStore is a RevisionStore keeping revisions in memory, so that
+// they are lost on restart. It is the default store of a Repository.
+type MemoryRevisionStore struct {
+	mu        sync.Mutex
+	revisions map[string][]Revision
+}
+
+// NewMemoryRevisionStore returns an empty MemoryRevisionStore.
+func NewMemoryRevisionStore() *MemoryRevisionStore {
+	return &MemoryRevisionStore{revisions: make(map[string][]Revision)}
+}
+
+// Revisions returns the revisions of the policy with policyID, oldest first.
+func (s *MemoryRevisionStore) Revisions(ctx context.Context, policyID string) ([]Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs := s.revisions[policyID]
+	out := make([]Revision, len(revs))
+	for i, rev := range revs {
+		out[i] = rev.clone()
+	}
+	return out, nil
+}
+
+// AppendRevision stores rev as the last revision of its policy.
+func (s *MemoryRevisionStore) AppendRevision(ctx context.Context, rev Revision) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revisions[rev.PolicyID] = append(s.revisions[rev.PolicyID], rev.clone())
+	return nil
+}
+
+// RemoveRevision removes rev if it is the last revision of its policy.
+func (s *MemoryRevisionStore) RemoveRevision(ctx context.Context, rev Revision) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	revs := s.revisions[rev.PolicyID]
+	if n := len(revs); n > 0 && revs[n-1].Number == rev.Number {
+		s.revisions[rev.PolicyID] = revs[:n-1]
+	}
+	return nil
+}
+
+// Repository decorates a Store, recording the history of every policy in a
+// RevisionStore. Add, Update and Delete create a revision, attributed with
+// the Change of their context. The Version of the policies they store is
+// replaced by the number of their revision, so that decisions identify the
+// revision that made them (see policies.Policy.Version).
+//
+// Writes are serialised by the Repository: the Store and the RevisionStore
+// should not be written to by other means. Every write records its revision
+// before writing to the Store, and removes it when the Store fails, so that
+// a revision number never identifies two contents.
+type Repository struct {
+	repo      Store
+	revisions RevisionStore
+	now       func() time.Time
+
+	mu sync.Mutex
+}
+
+// Option configures a Repository built by NewRepository.
+type Option func(*Repository)
+
+// WithClock sets the clock used to timestamp revisions. It defaults to
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(r *Repository) {
+		r.now = now
+	}
+}
+
+// WithRevisionStore sets the store of the revisions. It defaults to a
+// MemoryRevisionStore.
+func WithRevisionStore(s RevisionStore) Option {
+	return func(r *Repository) {
+		r.revisions = s
+	}
+}
+
+// NewRepository returns a Repository recording the history of the policies
+// of repo.
+func NewRepository(repo Store, opts ...Option) *Repository {
+	r := &Repository{
+		repo: repo,
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.revisions == nil {
+		r.revisions = NewMemoryRevisionStore()
+	}
+	return r
+}
+
+// FindByResourceAndResourceID returns the current policies of resource and
+// resourceID found by the decorated Store.
+func (r *Repository) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	return r.repo.FindByResourceAndResourceID(ctx, resource, resourceID)
+}
+
+// FindByTarget returns the current policies of the resource of t that target
+// its subject and action (see policies.FindByTarget).
+func (r *Repository) FindByTarget(ctx context.Context, t policies.Target) ([]policies.Policy, error) {
+	return policies.FindByTarget(ctx, r.repo, t)
+}
+
+// Get returns the current version of the policy with id, or
+// policies.ErrPolicyNotFound.
+func (r *Repository) Get(ctx context.Context, id string) (policies.Policy, error) {
+	return r.repo.Get(ctx, id)
+}
+
+// List returns the current version of every policy.
+func (r *Repository) List(ctx context.Context) ([]policies.Policy, error) {
+	return r.repo.List(ctx)
+}
+
+// Add stores p as a new revision, with the revision number as its Version.
+// It fails with policies.ErrPolicyExists when a policy with the same ID is
+// stored. A deleted policy can be added again: its history continues.
+func (r *Repository) Add(ctx context.Context, p policies.Policy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.numbered(ctx, p)
+	if err != nil {
+		return err
+	}
+	_, err = r.write(ctx, p, false, func() error { return r.repo.Add(ctx, p) })
+	return err
+}
+
+// Update stores p as a new revision of the policy with its ID, with the
+// revision number as its Version. It fails with policies.ErrPolicyNotFound
+// when there is none.
+func (r *Repository) Update(ctx context.Context, p policies.Policy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.numbered(ctx, p)
+	if err != nil {
+		return err
+	}
+	_, err = r.write(ctx, p, false, func() error { return r.repo.Update(ctx, p) })
+	return err
+}
+
+// Delete removes the policy with id, recording a deletion revision. It fails
+// with policies.ErrPolicyNotFound when there is none.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := r.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if p, err = r.numbered(ctx, p); err != nil {
+		return err
+	}
+	_, err = r.write(ctx, p, true, func() error { return r.repo.Delete(ctx, id) })
+	return err
+}
+
+// History returns the revisions of the policy with id, oldest first. It fails
+// with policies.ErrPolicyNotFound when the policy never existed.
+func (r *Repository) History(ctx context.Context, id string) ([]Revision, error) {
+	revs, err := r.revisions.Revisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("policy %s: %w", id, policies.ErrPolicyNotFound)
+	}
+	return revs, nil
+}
+
+// Revision returns the revision of the policy with id numbered number, or
+// ErrRevisionNotFound.
+func (r *Repository) Revision(ctx context.Context, id string, number int) (Revision, error) {
+	revs, err := r.History(ctx, id)
+	if err != nil {
+		return Revision{}, err
+	}
+	if number < 1 || number > len(revs) {
+		return Revision{}, fmt.Errorf("policy %s revision %d: %w", id, number, ErrRevisionNotFound)
+	}
+	return revs[number-1], nil
+}
+
+// AsOf returns the policy with id as it was stored at t. It fails with
+// ErrRevisionNotFound when the policy did not exist yet or was deleted at t.
+func (r *Repository) AsOf(ctx context.Context, id string, t time.Time) (policies.Policy, error) {
+	revs, err := r.History(ctx, id)
+	if err != nil {
+		return policies.Policy{}, err
+	}
+
+	// Revisions are in time order: find the last one made at or before t.
+	i := sort.Search(len(revs), func(i int) bool { return revs[i].At.After(t) })
+	if i == 0 || revs[i-1].Deleted {
+		return policies.Policy{}, fmt.Errorf("policy %s at %s: %w", id, t.Format(time.RFC3339), ErrRevisionNotFound)
+	}
+	return revs[i-1].Policy, nil
+}
+
+// Diff returns the fields of the policy with id that differ between the
+// revisions numbered from and to, sorted by field name. Version, which
+// always differs, is left out.
+func (r *Repository) Diff(ctx context.Context, id string, from, to int) ([]Difference, error) {
+	a, err := r.Revision(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.Revision(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+	return diff(a.Policy, b.Policy)
+}
+
+// Rollback restores the policy with id to its content at the revision
+// numbered number, as a new revision. A deleted policy is restored. The
+// change note defaults to "rollback to revision <number>".
+func (r *Repository) Rollback(ctx context.Context, id string, number int) (Revision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rev, err := r.Revision(ctx, id, number)
+	if err != nil {
+		return Revision{}, err
+	}
+	if rev.Deleted {
+		return Revision{}, fmt.Errorf("policy %s revision %d: cannot roll back to a deletion", id, number)
+	}
+
+	c, _ := ChangeFromContext(ctx)
+	if c.Note == "" {
+		c.Note = "rollback to revision " + strconv.Itoa(number)
+	}
+	ctx = ContextWithChange(ctx, c)
+
+	p, err := r.numbered(ctx, rev.Policy)
+	if err != nil {
+		return Revision{}, err
+	}
+	_, err = r.repo.Get(ctx, id)
+	switch {
+	case errors.Is(err, policies.ErrPolicyNotFound):
+		return r.write(ctx, p, false, func() error { return r.repo.Add(ctx, p) })
+	case err != nil:
+		return Revision{}, err
+	}
+	return r.write(ctx, p, false, func() error { return r.repo.Update(ctx, p) })
+}
+
+// numbered returns a copy of p with the number of its next revision as
+// Version.
+func (r *Repository) numbered(ctx context.Context, p policies.Policy) (policies.Policy, error) {
+	revs, err := r.revisions.Revisions(ctx, p.ID)
+	if err != nil {
+		return policies.Policy{}, err
+	}
+	p = p.Clone()
+	p.Version = strconv.Itoa(len(revs) + 1)
+	return p, nil
+}
+
+// write records a revision of p, then writes it to the Store with store. The
+// revision is removed when store fails.
+func (r *Repository) write(ctx context.Context, p policies.Policy, deleted bool, store func() error) (Revision, error) {
+	rev, err := r.record(ctx, p, deleted)
+	if err != nil {
+		return Revision{}, err
+	}
+	if err := store(); err != nil {
+		if rerr := r.revisions.RemoveRevision(context.WithoutCancel(ctx), rev); rerr != nil {
+			return Revision{}, errors.Join(err, fmt.Errorf("policy %s: remove revision %d: %w", p.ID, rev.Number, rerr))
+		}
+		return Revision{}, err
+	}
+	return rev, nil
+}
+
+// record appends a revision of p, numbered by numbered, to its history.
+func (r *Repository) record(ctx context.Context, p policies.Policy, deleted bool) (Revision, error) {
+	number, err := strconv.Atoi(p.Version)
+	if err != nil {
+		return Revision{}, fmt.Errorf("policy %s: revision number: %w", p.ID, err)
+	}
+	c, _ := ChangeFromContext(ctx)
+	rev := Revision{
+		PolicyID: p.ID,
+		Number:   number,
+		Author:   c.Author,
+		Note:     c.Note,
+		At:       r.now(),
+		Deleted:  deleted,
+		Policy:   p.Clone(),
+	}
+	if err := r.revisions.AppendRevision(ctx, rev); err != nil {
+		return Revision{}, err
+	}
+	return rev, nil
+}
+
+func (rev Revision) clone() Revision {
+	rev.Policy = rev.Policy.Clone()
+	return rev
+}
+
+// diff compares the JSON encodings of a and b field by field.
+func diff(a, b policies.Policy) ([]Difference, error) {
+	from, err := fields(a)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(from)+len(to))
+	for name := range from {
+		names[name] = struct{}{}
+	}
+	for name := range to {
+		names[name] = struct{}{}
+	}
+	delete(names, "version")
+
+	var out []Difference
+	for name := range names {
+		if !reflect.DeepEqual(from[name], to[name]) {
+			out = append(out, Difference{Field: name, From: from[name], To: to[name]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out, nil
+}
+
+func fields(p policies.Policy) (map[string]any, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: encode: %w", p.ID, err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("policy %s: decode: %w", p.ID, err)
+	}
+	return m, nil
+}
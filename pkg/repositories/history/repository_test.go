@@ -0,0 +1,238 @@
+package history_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/history"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/memory"
+	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
+)
+
+var jan2020 = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testPolicy(id string, effect policies.Effect) policies.Policy {
+	return policies.Policy{
+		ID:         id,
+		Resource:   "doc",
+		ResourceID: "1",
+		Effect:     effect,
+		Period:     timerange.MustNew(jan2020, nil),
+		Condition:  policies.PolicyCondition{Attribute: "role", Operator: policies.OpEqual, Value: "admin"},
+	}
+}
+
+// tick returns a clock starting at t and advancing by an hour on every call.
+func tick(t time.Time) func() time.Time {
+	return func() time.Time {
+		t = t.Add(time.Hour)
+		return t
+	}
+}
+
+func numbers(revs []history.Revision) []int {
+	out := make([]int, len(revs))
+	for i, rev := range revs {
+		out[i] = rev.Number
+	}
+	return out
+}
+
+// newRepository returns a repository holding policy "a" with three revisions,
+// made at 01:00, 02:00 and 03:00 on jan2020; the last one deletes it.
+func newRepository(t *testing.T) *history.Repository {
+	t.Helper()
+	ctx := history.ContextWithChange(context.Background(), history.Change{Author: "alice", Note: "create"})
+	repo := history.NewRepository(&memory.Repository{}, history.WithClock(tick(jan2020)))
+
+	assert.NoError(t, repo.Add(ctx, testPolicy("a", policies.EffectAllow)))
+	ctx = history.ContextWithChange(ctx, history.Change{Author: "bob", Note: "deny admins"})
+	assert.NoError(t, repo.Update(ctx, testPolicy("a", policies.EffectDeny)))
+	assert.NoError(t, repo.Delete(context.Background(), "a"))
+	return repo
+}
+
+func TestRepository_History(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	revs, err := repo.History(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, numbers(revs))
+	assert.Equal(t, "alice", revs[0].Author)
+	assert.Equal(t, "deny admins", revs[1].Note)
+	assert.Equal(t, "2", revs[1].Policy.Version)
+	assert.Equal(t, jan2020.Add(2*time.Hour), revs[1].At)
+	assert.True(t, revs[2].Deleted)
+	assert.Equal(t, policies.EffectDeny, revs[2].Policy.Effect)
+
+	_, err = repo.Get(ctx, "a")
+	assert.ErrorIs(t, err, policies.ErrPolicyNotFound)
+	_, err = repo.History(ctx, "b")
+	assert.ErrorIs(t, err, policies.ErrPolicyNotFound)
+	_, err = repo.Revision(ctx, "a", 4)
+	assert.ErrorIs(t, err, history.ErrRevisionNotFound)
+
+	revs[0].Policy.Condition.Value = "changed"
+	rev, _ := repo.Revision(ctx, "a", 1)
+	assert.Equal(t, "admin", rev.Policy.Condition.Value, "history is immutable")
+}
+
+func TestRepository_AsOf(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		effect policies.Effect
+		err    error
+	}{
+		{name: "before creation", at: jan2020, err: history.ErrRevisionNotFound},
+		{name: "first revision", at: jan2020.Add(90 * time.Minute), effect: policies.EffectAllow},
+		{name: "at second revision", at: jan2020.Add(2 * time.Hour), effect: policies.EffectDeny},
+		{name: "after deletion", at: jan2020.Add(3 * time.Hour), err: history.ErrRevisionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := repo.AsOf(ctx, "a", tt.at)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.effect, p.Effect)
+		})
+	}
+}
+
+func TestRepository_Diff(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	diffs, err := repo.Diff(ctx, "a", 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []history.Difference{{Field: "effect", From: "allow", To: "deny"}}, diffs)
+
+	diffs, err = repo.Diff(ctx, "a", 2, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestRepository_Rollback(t *testing.T) {
+	ctx := history.ContextWithChange(context.Background(), history.Change{Author: "carol"})
+	repo := newRepository(t)
+
+	rev, err := repo.Rollback(ctx, "a", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, rev.Number)
+	assert.Equal(t, "carol", rev.Author)
+	assert.Equal(t, "rollback to revision 1", rev.Note)
+
+	p, err := repo.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, policies.EffectAllow, p.Effect)
+	assert.Equal(t, "4", p.Version)
+
+	rev, err = repo.Rollback(ctx, "a", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, rev.Number)
+	pols, _ := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.Equal(t, policies.EffectDeny, pols[0].Effect)
+
+	_, err = repo.Rollback(ctx, "a", 3)
+	assert.ErrorContains(t, err, "cannot roll back to a deletion")
+	_, err = repo.Rollback(ctx, "a", 9)
+	assert.ErrorIs(t, err, history.ErrRevisionNotFound)
+}
+
+func TestRepository_InvalidWritesKeepHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := history.NewRepository(&memory.Repository{})
+
+	assert.ErrorContains(t, repo.Add(ctx, policies.Policy{}), "policy id is required")
+	assert.ErrorIs(t, repo.Update(ctx, testPolicy("a", policies.EffectAllow)), policies.ErrPolicyNotFound)
+	_, err := repo.History(ctx, "a")
+	assert.ErrorIs(t, err, policies.ErrPolicyNotFound)
+
+	assert.NoError(t, repo.Add(ctx, testPolicy("a", policies.EffectAllow)))
+	assert.ErrorIs(t, repo.Add(ctx, testPolicy("a", policies.EffectAllow)), policies.ErrPolicyExists)
+	revs, _ := repo.History(ctx, "a")
+	assert.Len(t, revs, 1)
+}
+
+// failingRevisionStore is a MemoryRevisionStore failing its next append
+// when fail is set.
+type failingRevisionStore struct {
+	*history.MemoryRevisionStore
+	fail bool
+}
+
+func (s *failingRevisionStore) AppendRevision(ctx context.Context, rev history.Revision) error {
+	if s.fail {
+		s.fail = false
+		return errors.New("boom")
+	}
+	return s.MemoryRevisionStore.AppendRevision(ctx, rev)
+}
+
+func TestRepository_FailedRevisionKeepsStore(t *testing.T) {
+	ctx := context.Background()
+	store := &memory.Repository{}
+	revisions := &failingRevisionStore{MemoryRevisionStore: history.NewMemoryRevisionStore()}
+	repo := history.NewRepository(store, history.WithRevisionStore(revisions))
+	assert.NoError(t, repo.Add(ctx, testPolicy("a", policies.EffectAllow)))
+
+	revisions.fail = true
+	assert.EqualError(t, repo.Update(ctx, testPolicy("a", policies.EffectDeny)), "boom")
+	stored, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, policies.EffectAllow, stored.Effect)
+	assert.Equal(t, "1", stored.Version)
+
+	revisions.fail = true
+	assert.EqualError(t, repo.Delete(ctx, "a"), "boom")
+	_, err = store.Get(ctx, "a")
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.Update(ctx, testPolicy("a", policies.EffectDeny)))
+	revs, err := repo.History(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, numbers(revs))
+	assert.Equal(t, policies.EffectDeny, revs[1].Policy.Effect)
+}
+
+func TestRepository_DecoratesStore(t *testing.T) {
+	ctx := context.Background()
+	store := &memory.Repository{}
+	revisions := history.NewMemoryRevisionStore()
+	repo := history.NewRepository(store, history.WithRevisionStore(revisions))
+
+	p := testPolicy("a", policies.EffectAllow)
+	p.Version = "v7"
+	assert.NoError(t, repo.Add(ctx, p))
+	assert.NoError(t, repo.Update(ctx, testPolicy("a", policies.EffectDeny)))
+
+	stored, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, policies.EffectDeny, stored.Effect)
+	assert.Equal(t, "2", stored.Version)
+
+	// A repository over the same stores continues the history.
+	repo = history.NewRepository(store, history.WithRevisionStore(revisions))
+	assert.NoError(t, repo.Update(ctx, testPolicy("a", policies.EffectAllow)))
+	revs, err := revisions.Revisions(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, numbers(revs))
+	assert.Equal(t, "1", revs[0].Policy.Version)
+
+	pols, err := repo.FindByTarget(ctx, policies.Target{Resource: "doc", ResourceID: "1"})
+	assert.NoError(t, err)
+	assert.Len(t, pols, 1)
+	assert.Equal(t, "3", pols[0].Version)
+}
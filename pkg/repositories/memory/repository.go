@@ -0,0 +1,261 @@
+// Package memory provides a thread-safe, in-memory policies.PolicyRepository.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+// Repository is an in-memory PolicyRepository indexing policies by Resource
+// and ResourceID. Policies whose ResourceID is policies.AnyResourceID apply
+// to every resource ID of their Resource, and policies apply to the children
+// of their Resource. Resource patterns are matched on every lookup.
+//
+// Reads use an immutable snapshot of the policy set that writers replace
+// atomically, so readers never block writers nor each other.
+type Repository struct {
+	mu       sync.Mutex
+	snapshot atomic.Pointer[snapshot]
+}
+
+// snapshot is an immutable policy set. Policies are kept in insertion order.
+type snapshot struct {
+	policies []policies.Policy
+	byID     map[string]int
+	// index maps a resource and a resource ID to the positions of their
+	// policies, except for resource patterns whose positions are in
+	// patterns.
+	index    map[string]map[string][]int
+	patterns []int
+}
+
+// NewRepository returns a Repository holding pols, which must be valid and
+// have distinct IDs.
+func NewRepository(pols ...policies.Policy) (*Repository, error) {
+	r := &Repository{}
+	if err := r.Replace(context.Background(), pols); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FindByResourceAndResourceID returns the policies whose Resource matches
+// resource or one of its ancestors (see policies.MatchResource) and whose
+// ResourceID is resourceID or policies.AnyResourceID. The most specific
+// resources come first; for equally specific ones, policies with
+// resourceID come before those applying to any resource ID.
+func (r *Repository) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := r.load()
+	var matched []int
+	for _, res := range policies.ResourceAncestors(resource) {
+		ids := s.index[res]
+		matched = append(matched, ids[resourceID]...)
+		if resourceID != policies.AnyResourceID {
+			matched = append(matched, ids[policies.AnyResourceID]...)
+		}
+	}
+	for _, i := range s.patterns {
+		if s.policies[i].Matches(resource, resourceID) {
+			matched = append(matched, i)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := s.policies[matched[i]], s.policies[matched[j]]
+		if a.Specificity() != b.Specificity() {
+			return a.Specificity() > b.Specificity()
+		}
+		return a.ResourceID == resourceID && b.ResourceID != resourceID
+	})
+
+	out := make([]policies.Policy, len(matched))
+	for i, idx := range matched {
+		out[i] = s.policies[idx].Clone()
+	}
+	return out, nil
+}
+
+// FindByTarget returns the policies FindByResourceAndResourceID returns for
+// the resource of t that target its subject and action.
+func (r *Repository) FindByTarget(ctx context.Context, t policies.Target) ([]policies.Policy, error) {
+	pols, err := r.FindByResourceAndResourceID(ctx, t.Resource, t.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	out := pols[:0]
+	for _, p := range pols {
+		if p.MatchesTarget(t) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// Get returns the policy with id, or policies.ErrPolicyNotFound.
+func (r *Repository) Get(ctx context.Context, id string) (policies.Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return policies.Policy{}, err
+	}
+
+	s := r.load()
+	i, ok := s.byID[id]
+	if !ok {
+		return policies.Policy{}, fmt.Errorf("policy %s: %w", id, policies.ErrPolicyNotFound)
+	}
+	return s.policies[i].Clone(), nil
+}
+
+// List returns every policy in insertion order.
+func (r *Repository) List(ctx context.Context) ([]policies.Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := r.load()
+	out := make([]policies.Policy, len(s.policies))
+	for i, p := range s.policies {
+		out[i] = p.Clone()
+	}
+	return out, nil
+}
+
+// Add stores p. It fails with policies.ErrPolicyExists when a policy with the
+// same ID is stored.
+func (r *Repository) Add(ctx context.Context, p policies.Policy) error {
+	return r.write(ctx, func(pols []policies.Policy, byID map[string]int) ([]policies.Policy, error) {
+		if err := validate(p); err != nil {
+			return nil, err
+		}
+		return append(pols, p), nil
+	})
+}
+
+// Update replaces the stored policy with the ID of p. It fails with
+// policies.ErrPolicyNotFound when there is none.
+func (r *Repository) Update(ctx context.Context, p policies.Policy) error {
+	return r.write(ctx, func(pols []policies.Policy, byID map[string]int) ([]policies.Policy, error) {
+		if err := validate(p); err != nil {
+			return nil, err
+		}
+		i, ok := byID[p.ID]
+		if !ok {
+			return nil, fmt.Errorf("policy %s: %w", p.ID, policies.ErrPolicyNotFound)
+		}
+		pols[i] = p
+		return pols, nil
+	})
+}
+
+// Delete removes the policy with id. It fails with policies.ErrPolicyNotFound
+// when there is none.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	return r.write(ctx, func(pols []policies.Policy, byID map[string]int) ([]policies.Policy, error) {
+		i, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("policy %s: %w", id, policies.ErrPolicyNotFound)
+		}
+		return append(pols[:i], pols[i+1:]...), nil
+	})
+}
+
+// Replace atomically replaces every stored policy with pols. Nothing is
+// replaced when a policy is invalid or IDs are repeated.
+func (r *Repository) Replace(ctx context.Context, pols []policies.Policy) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range pols {
+		if err := validate(p); err != nil {
+			return err
+		}
+	}
+	s, err := newSnapshot(pols)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshot.Store(s)
+	return nil
+}
+
+// load returns the current snapshot, which is empty for a zero Repository.
+func (r *Repository) load() *snapshot {
+	if s := r.snapshot.Load(); s != nil {
+		return s
+	}
+	return &snapshot{}
+}
+
+// write applies fn to a copy of the current policies and publishes the
+// result as the new snapshot.
+func (r *Repository) write(ctx context.Context, fn func(pols []policies.Policy, byID map[string]int) ([]policies.Policy, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.load()
+	pols, err := fn(append([]policies.Policy(nil), current.policies...), current.byID)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSnapshot(pols)
+	if err != nil {
+		return err
+	}
+	r.snapshot.Store(s)
+	return nil
+}
+
+// newSnapshot indexes pols, which must have distinct IDs.
+func newSnapshot(pols []policies.Policy) (*snapshot, error) {
+	s := &snapshot{
+		policies: make([]policies.Policy, len(pols)),
+		byID:     make(map[string]int, len(pols)),
+		index:    make(map[string]map[string][]int),
+	}
+	for i, p := range pols {
+		if _, ok := s.byID[p.ID]; ok {
+			return nil, fmt.Errorf("policy %s: %w", p.ID, policies.ErrPolicyExists)
+		}
+
+		s.policies[i] = p.Clone()
+		s.byID[p.ID] = i
+		if policies.IsResourcePattern(p.Resource) {
+			s.patterns = append(s.patterns, i)
+			continue
+		}
+		ids, ok := s.index[p.Resource]
+		if !ok {
+			ids = make(map[string][]int)
+			s.index[p.Resource] = ids
+		}
+		ids[p.ResourceID] = append(ids[p.ResourceID], i)
+	}
+	return s, nil
+}
+
+func validate(p policies.Policy) error {
+	if p.ID == "" {
+		return fmt.Errorf("policy id is required")
+	}
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("policy %s: %w", p.ID, err)
+	}
+	return nil
+}
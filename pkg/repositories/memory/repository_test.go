@@ -0,0 +1,246 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/memory"
+	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
+)
+
+func testPolicy(id, resource, resourceID string) policies.Policy {
+	period, _ := timerange.New(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	return policies.Policy{
+		ID:         id,
+		Resource:   resource,
+		ResourceID: resourceID,
+		Effect:     policies.EffectAllow,
+		Period:     period,
+		Condition:  policies.PolicyCondition{Attribute: "role", Operator: policies.OpEqual, Value: "admin"},
+	}
+}
+
+func ids(pols []policies.Policy) []string {
+	out := make([]string, len(pols))
+	for i, p := range pols {
+		out[i] = p.ID
+	}
+	return out
+}
+
+func TestRepository_FindByResourceAndResourceID(t *testing.T) {
+	repo, err := memory.NewRepository(
+		testPolicy("doc-1", "doc", "1"),
+		testPolicy("doc-any", "doc", policies.AnyResourceID),
+		testPolicy("doc-2", "doc", "2"),
+		testPolicy("doc", "doc", ""),
+		testPolicy("img-1", "img", "1"),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name       string
+		resource   string
+		resourceID string
+		ids        []string
+	}{
+		{name: "exact resource id then wildcard", resource: "doc", resourceID: "1", ids: []string{"doc-1", "doc-any"}},
+		{name: "wildcard fallback", resource: "doc", resourceID: "3", ids: []string{"doc-any"}},
+		{name: "empty resource id", resource: "doc", resourceID: "", ids: []string{"doc", "doc-any"}},
+		{name: "wildcard only once", resource: "doc", resourceID: policies.AnyResourceID, ids: []string{"doc-any"}},
+		{name: "unknown resource", resource: "video", resourceID: "1", ids: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pols, err := repo.FindByResourceAndResourceID(context.Background(), tt.resource, tt.resourceID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ids, ids(pols))
+		})
+	}
+}
+
+func TestRepository_FindByResourceAndResourceID_Hierarchy(t *testing.T) {
+	repo, err := memory.NewRepository(
+		testPolicy("org", "orgs/42", policies.AnyResourceID),
+		testPolicy("projects", "orgs/*/projects/**", policies.AnyResourceID),
+		testPolicy("project", "orgs/42/projects/7", policies.AnyResourceID),
+		testPolicy("other-org", "orgs/43", policies.AnyResourceID),
+		testPolicy("project-doc", "orgs/42/projects/7", "9"),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name       string
+		resource   string
+		resourceID string
+		ids        []string
+	}{
+		{name: "most specific first", resource: "orgs/42/projects/7/docs", resourceID: "9", ids: []string{"project-doc", "project", "projects", "org"}},
+		{name: "parent only", resource: "orgs/42/teams", resourceID: "1", ids: []string{"org"}},
+		{name: "pattern", resource: "orgs/43/projects/1", resourceID: "1", ids: []string{"projects", "other-org"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pols, err := repo.FindByResourceAndResourceID(context.Background(), tt.resource, tt.resourceID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ids, ids(pols))
+		})
+	}
+}
+
+func TestRepository_FindByTarget(t *testing.T) {
+	readers := testPolicy("readers", "doc", "1")
+	readers.Subjects = []string{"group:readers"}
+	readers.Actions = []string{"read"}
+	owner := testPolicy("owner", "doc", policies.AnyResourceID)
+	owner.Subjects = []string{"alice"}
+	repo, err := memory.NewRepository(readers, owner, testPolicy("all", "doc", "1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		target policies.Target
+		ids    []string
+	}{
+		{name: "reader", target: policies.Target{Subject: policies.Subject{ID: "bob", Groups: []string{"readers"}}, Action: "read"}, ids: []string{"readers", "all"}},
+		{name: "reader writing", target: policies.Target{Subject: policies.Subject{ID: "bob", Groups: []string{"readers"}}, Action: "write"}, ids: []string{"all"}},
+		{name: "owner", target: policies.Target{Subject: policies.Subject{ID: "alice"}, Action: "write"}, ids: []string{"all", "owner"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.target.Resource, tt.target.ResourceID = "doc", "1"
+			pols, err := repo.FindByTarget(context.Background(), tt.target)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ids, ids(pols))
+		})
+	}
+}
+
+func TestRepository_Write(t *testing.T) {
+	ctx := context.Background()
+	repo, err := memory.NewRepository(testPolicy("a", "doc", "1"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, repo.Add(ctx, testPolicy("b", "doc", "1")))
+	assert.ErrorIs(t, repo.Add(ctx, testPolicy("a", "doc", "2")), policies.ErrPolicyExists)
+	assert.ErrorContains(t, repo.Add(ctx, testPolicy("", "doc", "1")), "policy id is required")
+	assert.ErrorContains(t, repo.Add(ctx, testPolicy("c", "", "1")), "policy resource is required")
+
+	moved := testPolicy("a", "doc", "2")
+	assert.NoError(t, repo.Update(ctx, moved))
+	assert.ErrorIs(t, repo.Update(ctx, testPolicy("x", "doc", "1")), policies.ErrPolicyNotFound)
+
+	pols, _ := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+	assert.Equal(t, []string{"b"}, ids(pols))
+	pols, _ = repo.FindByResourceAndResourceID(ctx, "doc", "2")
+	assert.Equal(t, []string{"a"}, ids(pols))
+
+	assert.NoError(t, repo.Delete(ctx, "a"))
+	assert.ErrorIs(t, repo.Delete(ctx, "a"), policies.ErrPolicyNotFound)
+	_, err = repo.Get(ctx, "a")
+	assert.ErrorIs(t, err, policies.ErrPolicyNotFound)
+
+	p, err := repo.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", p.ID)
+
+	pols, _ = repo.List(ctx)
+	assert.Equal(t, []string{"b"}, ids(pols))
+}
+
+func TestRepository_Replace(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := memory.NewRepository(testPolicy("a", "doc", "1"))
+
+	err := repo.Replace(ctx, []policies.Policy{testPolicy("b", "doc", "1"), testPolicy("b", "doc", "2")})
+	assert.ErrorIs(t, err, policies.ErrPolicyExists)
+	pols, _ := repo.List(ctx)
+	assert.Equal(t, []string{"a"}, ids(pols), "failed replace keeps the current set")
+
+	assert.NoError(t, repo.Replace(ctx, []policies.Policy{testPolicy("c", "doc", "1")}))
+	pols, _ = repo.List(ctx)
+	assert.Equal(t, []string{"c"}, ids(pols))
+}
+
+func TestRepository_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := memory.NewRepository(testPolicy("a", "doc", "1"))
+
+	p, _ := repo.Get(ctx, "a")
+	p.Effect = policies.EffectDeny
+	end := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	*p.Period = *timerange.MustNew(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), &end)
+
+	stored, _ := repo.Get(ctx, "a")
+	assert.Equal(t, policies.EffectAllow, stored.Effect)
+	assert.Nil(t, stored.Period.End())
+}
+
+func TestRepository_ReturnsConditionCopies(t *testing.T) {
+	ctx := context.Background()
+	cond := func() policies.PolicyCondition {
+		return policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+			{Attribute: "role", Operator: policies.OpIn, Value: []any{"admin", map[string]any{"ref": "subject.role"}}},
+			{Expression: policies.Arith(policies.ArithMul, map[string]any{"ref": "request.amount"}, 2), Operator: policies.OpLessOrEqual, Value: 100},
+		}}
+	}
+	p := testPolicy("a", "doc", "1")
+	p.Condition = cond()
+	repo, _ := memory.NewRepository(p)
+
+	got, _ := repo.Get(ctx, "a")
+	got.Condition.Conditions[0].Value.([]any)[0] = "guest"
+	got.Condition.Conditions[0].Value.([]any)[1].(map[string]any)["ref"] = "subject.other"
+	got.Condition.Conditions[1].Expression.Args[1] = 3
+	got.Condition.Conditions[1].Operator = policies.OpGreater
+
+	stored, _ := repo.Get(ctx, "a")
+	assert.Equal(t, cond(), stored.Condition)
+}
+
+func TestRepository_ZeroValue(t *testing.T) {
+	var repo memory.Repository
+	pols, err := repo.FindByResourceAndResourceID(context.Background(), "doc", "1")
+	assert.NoError(t, err)
+	assert.Empty(t, pols)
+	assert.NoError(t, repo.Add(context.Background(), testPolicy("a", "doc", "1")))
+}
+
+func TestRepository_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := memory.NewRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, repo.Add(ctx, testPolicy(fmt.Sprintf("p%d", i), "doc", "1")))
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, err := repo.FindByResourceAndResourceID(ctx, "doc", "1")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	pols, _ := repo.List(ctx)
+	assert.Len(t, pols, 20)
+}
@@ -0,0 +1,270 @@
+// Package reload provides a policies.PolicyRepository that reloads its
+// policies from a Source while running and notifies subscribers of changes.
+package reload
+
+import (
+	"context"
+	"io/fs"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/file"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/memory"
+)
+
+// DefaultInterval is the time between two polls of the Source unless
+// WithInterval is used.
+const DefaultInterval = 10 * time.Second
+
+// subscriptionBuffer is the number of events a subscriber can fall behind
+// before events are dropped for it.
+const subscriptionBuffer = 16
+
+// Source loads the complete policy set served by a Repository.
+type Source interface {
+	Load(ctx context.Context) ([]policies.Policy, error)
+}
+
+// SourceFunc adapts a function to the Source interface, for example to poll
+// a remote service.
+type SourceFunc func(ctx context.Context) ([]policies.Policy, error)
+
+// Load calls f(ctx).
+func (f SourceFunc) Load(ctx context.Context) ([]policies.Policy, error) {
+	return f(ctx)
+}
+
+// DirSource returns a Source reading the policy files of fsys with
+// file.Load.
+func DirSource(fsys fs.FS) Source {
+	return SourceFunc(func(context.Context) ([]policies.Policy, error) {
+		return file.Load(fsys)
+	})
+}
+
+// ChangeEvent describes how a reload changed the policy set. Generation
+// increases by one on every change, so subscribers can tell when they missed
+// events.
+type ChangeEvent struct {
+	Generation uint64    `json:"generation"`
+	Added      []string  `json:"added,omitempty"`
+	Updated    []string  `json:"updated,omitempty"`
+	Removed    []string  `json:"removed,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// Empty reports whether the event holds no change.
+func (e ChangeEvent) Empty() bool {
+	return len(e.Added) == 0 && len(e.Updated) == 0 && len(e.Removed) == 0
+}
+
+// Repository is a PolicyRepository serving the last policy set successfully
+// loaded from its Source. Run polls the Source; when the loaded set is valid
+// it replaces the served one atomically, otherwise the last good set is kept
+// and the error is passed to the error handler.
+type Repository struct {
+	src          Source
+	interval     time.Duration
+	errorHandler func(error)
+	now          func() time.Time
+
+	repo *memory.Repository
+
+	// mu serializes reloads.
+	mu         sync.Mutex
+	current    map[string]policies.Policy
+	generation uint64
+
+	subMu       sync.Mutex
+	subscribers map[chan ChangeEvent]struct{}
+}
+
+// Option configures a Repository built by NewRepository.
+type Option func(*Repository)
+
+// WithInterval sets the time between two polls of the Source.
+func WithInterval(d time.Duration) Option {
+	return func(r *Repository) {
+		r.interval = d
+	}
+}
+
+// WithErrorHandler sets the function receiving the errors of the reloads
+// made by Run. Errors are discarded by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(r *Repository) {
+		r.errorHandler = fn
+	}
+}
+
+// WithClock sets the function used to timestamp change events. It defaults
+// to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(r *Repository) {
+		r.now = now
+	}
+}
+
+// NewRepository loads the policies of src, failing when they are invalid.
+func NewRepository(src Source, opts ...Option) (*Repository, error) {
+	r := &Repository{
+		src:          src,
+		interval:     DefaultInterval,
+		errorHandler: func(error) {},
+		now:          time.Now,
+		repo:         &memory.Repository{},
+		current:      make(map[string]policies.Policy),
+		subscribers:  make(map[chan ChangeEvent]struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if _, err := r.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FindByResourceAndResourceID returns the policies of resource whose
+// ResourceID is resourceID, followed by those applying to any resource ID.
+func (r *Repository) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	return r.repo.FindByResourceAndResourceID(ctx, resource, resourceID)
+}
+
+// FindByTarget returns the policies of the resource of t that target its
+// subject and action.
+func (r *Repository) FindByTarget(ctx context.Context, t policies.Target) ([]policies.Policy, error) {
+	return r.repo.FindByTarget(ctx, t)
+}
+
+// Get returns the policy with id, or policies.ErrPolicyNotFound.
+func (r *Repository) Get(ctx context.Context, id string) (policies.Policy, error) {
+	return r.repo.Get(ctx, id)
+}
+
+// List returns every policy, in Source order.
+func (r *Repository) List(ctx context.Context) ([]policies.Policy, error) {
+	return r.repo.List(ctx)
+}
+
+// Generation returns the generation of the served policy set.
+func (r *Repository) Generation() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// Run polls the Source every interval until ctx is done, returning ctx.Err().
+func (r *Repository) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := r.Reload(ctx); err != nil && ctx.Err() == nil {
+				r.errorHandler(err)
+			}
+		}
+	}
+}
+
+// Reload loads the Source once and, when the loaded set is valid and differs
+// from the served one, replaces it and notifies subscribers. The returned
+// event is empty when nothing changed. On error the served set is kept.
+func (r *Repository) Reload(ctx context.Context) (ChangeEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pols, err := r.src.Load(ctx)
+	if err != nil {
+		return ChangeEvent{}, err
+	}
+
+	next := make(map[string]policies.Policy, len(pols))
+	for _, p := range pols {
+		next[p.ID] = p
+	}
+	// The set is replaced even without changes, to keep the Source order.
+	if err := r.repo.Replace(ctx, pols); err != nil {
+		return ChangeEvent{}, err
+	}
+
+	event := diff(r.current, next)
+	r.current = next
+	if event.Empty() && r.generation > 0 {
+		return event, nil
+	}
+
+	r.generation++
+	event.Generation = r.generation
+	event.At = r.now()
+	r.publish(event)
+	return event, nil
+}
+
+// Subscribe returns a channel receiving the change events of the
+// Repository and a function ending the subscription and closing the channel.
+// Events are dropped for subscribers falling behind; Generation tells when
+// that happened.
+func (r *Repository) Subscribe() (<-chan ChangeEvent, func()) {
+	ch := make(chan ChangeEvent, subscriptionBuffer)
+
+	r.subMu.Lock()
+	r.subscribers[ch] = struct{}{}
+	r.subMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			r.subMu.Lock()
+			delete(r.subscribers, ch)
+			r.subMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (r *Repository) publish(event ChangeEvent) {
+	if event.Empty() {
+		return
+	}
+
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	for ch := range r.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// diff returns the IDs added, updated and removed from prev to next, sorted.
+func diff(prev, next map[string]policies.Policy) ChangeEvent {
+	var event ChangeEvent
+	for id, p := range next {
+		old, ok := prev[id]
+		switch {
+		case !ok:
+			event.Added = append(event.Added, id)
+		case !reflect.DeepEqual(old, p):
+			event.Updated = append(event.Updated, id)
+		}
+	}
+	for id := range prev {
+		if _, ok := next[id]; !ok {
+			event.Removed = append(event.Removed, id)
+		}
+	}
+	sort.Strings(event.Added)
+	sort.Strings(event.Updated)
+	sort.Strings(event.Removed)
+	return event
+}
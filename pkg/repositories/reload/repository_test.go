@@ -0,0 +1,160 @@
+package reload_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/reload"
+	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
+)
+
+func testPolicy(id, value string) policies.Policy {
+	return policies.Policy{
+		ID:        id,
+		Resource:  "doc",
+		Effect:    policies.EffectAllow,
+		Period:    timerange.MustNew(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), nil),
+		Condition: policies.PolicyCondition{Attribute: "role", Operator: policies.OpEqual, Value: value},
+	}
+}
+
+// stubSource serves the policies and error it holds.
+type stubSource struct {
+	mu   sync.Mutex
+	pols []policies.Policy
+	err  error
+}
+
+func (s *stubSource) set(err error, pols ...policies.Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pols, s.err = pols, err
+}
+
+func (s *stubSource) Load(context.Context) ([]policies.Policy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pols, s.err
+}
+
+func TestRepository_Reload(t *testing.T) {
+	ctx := context.Background()
+	src := &stubSource{}
+	src.set(nil, testPolicy("a", "admin"), testPolicy("b", "dev"))
+
+	repo, err := reload.NewRepository(src)
+	if !assert.NoError(t, err) {
+		return
+	}
+	events, cancel := repo.Subscribe()
+	defer cancel()
+
+	src.set(nil, testPolicy("a", "root"), testPolicy("c", "ops"))
+	event, err := repo.Reload(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, event.Added)
+	assert.Equal(t, []string{"a"}, event.Updated)
+	assert.Equal(t, []string{"b"}, event.Removed)
+	assert.Equal(t, uint64(2), event.Generation)
+	assert.Equal(t, event, <-events)
+
+	event, err = repo.Reload(ctx)
+	assert.NoError(t, err)
+	assert.True(t, event.Empty())
+	assert.Equal(t, uint64(2), repo.Generation())
+
+	src.set(nil, testPolicy("a", "root"), testPolicy("a", "dup"))
+	_, err = repo.Reload(ctx)
+	assert.ErrorIs(t, err, policies.ErrPolicyExists)
+
+	src.set(errors.New("unavailable"))
+	_, err = repo.Reload(ctx)
+	assert.EqualError(t, err, "unavailable")
+
+	pols, _ := repo.FindByResourceAndResourceID(ctx, "doc", "")
+	if assert.Len(t, pols, 2, "failed reloads keep the last good set") {
+		assert.Equal(t, "root", pols[0].Condition.Value)
+	}
+	assert.Len(t, events, 0)
+}
+
+func TestRepository_NewRepositoryFails(t *testing.T) {
+	src := &stubSource{}
+	src.set(nil, testPolicy("", "admin"))
+
+	_, err := reload.NewRepository(src)
+	assert.ErrorContains(t, err, "policy id is required")
+}
+
+func TestRepository_Run(t *testing.T) {
+	dir := t.TempDir()
+	write := func(data string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "policies.json"), []byte(data), 0o600))
+	}
+	write(`[]`)
+
+	errs := make(chan error, 10)
+	repo, err := reload.NewRepository(reload.DirSource(os.DirFS(dir)),
+		reload.WithInterval(time.Millisecond),
+		reload.WithErrorHandler(func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	events, unsubscribe := repo.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- repo.Run(ctx) }()
+
+	write(`{"id": "a", "resource": "doc", "effect": "allow", "period": {"start": "2020-01-01T00:00:00Z"},
+		"condition": {"attribute": "role", "operator": "eq", "value": "admin"}}`)
+	select {
+	case event := <-events:
+		assert.Equal(t, []string{"a"}, event.Added)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change event")
+	}
+
+	write(`{`)
+	select {
+	case err := <-errs:
+		assert.ErrorContains(t, err, "policies.json#")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload error")
+	}
+	_, err = repo.Get(context.Background(), "a")
+	assert.NoError(t, err)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestRepository_Unsubscribe(t *testing.T) {
+	src := &stubSource{}
+	repo, _ := reload.NewRepository(src)
+
+	events, unsubscribe := repo.Subscribe()
+	unsubscribe()
+	unsubscribe()
+
+	src.set(nil, testPolicy("a", "admin"))
+	_, err := repo.Reload(context.Background())
+	assert.NoError(t, err)
+
+	_, ok := <-events
+	assert.False(t, ok)
+}
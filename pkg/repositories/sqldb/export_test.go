@@ -0,0 +1,8 @@
+package sqldb
+
+import "context"
+
+// FindQuery exposes the query of FindByResourceAndResourceID to tests.
+func (r *Repository) FindQuery(ctx context.Context, resource, resourceID string) (string, []any) {
+	return r.findQuery(ctx, resource, resourceID)
+}
@@ -0,0 +1,474 @@
+// Package sqldb provides a policies.PolicyRepository backed by a database/sql
+// database.
+//
+// Policies are stored in a single table, created by Repository.Migrate:
+//
+//	id             VARCHAR(255) PRIMARY KEY
+//	resource       VARCHAR(255) NOT NULL
+//	resource_id    VARCHAR(255) NOT NULL  -- "" or "*" for any resource ID
+//	effect         VARCHAR(16)  NOT NULL  -- "allow" or "deny"
+//	condition_json TEXT         NOT NULL  -- PolicyCondition as JSON
+//	version        VARCHAR(255) NOT NULL
+//	dry_run        BOOLEAN      NOT NULL
+//	priority       INTEGER      NULL      -- Policy.ExplicitPriority
+//	period_start   BIGINT       NOT NULL  -- Unix time in nanoseconds
+//	period_end     BIGINT       NULL      -- Unix time in nanoseconds, exclusive
+//	created_at     BIGINT       NOT NULL  -- Unix time in nanoseconds
+//	updated_at     BIGINT       NOT NULL  -- Unix time in nanoseconds
+//	state          VARCHAR(16)  NOT NULL  -- Policy.State, "" when unset
+//	subjects       TEXT         NOT NULL  -- Policy.Subjects as JSON, "" for any
+//	actions        TEXT         NOT NULL  -- Policy.Actions as JSON, "" for any
+//	resource_pattern BOOLEAN    NOT NULL  -- resource holds wildcards
+//
+// with indexes on (resource, resource_id, period_start) and (resource_pattern,
+// resource_id, period_start). Times are stored as integers so that period
+// filtering behaves alike on every database; they are read back in UTC.
+//
+// The SQL is portable; databases using numbered placeholders, such as
+// PostgreSQL, need WithPlaceholder(Dollar).
+//
+// The package imports no driver. Its tests run against SQLite through
+// github.com/mattn/go-sqlite3, which needs cgo and a C compiler, so they are
+// behind the sqlite build tag:
+//
+//	go test -tags sqlite ./pkg/repositories/sqldb/
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
+)
+
+// DefaultTable is the name of the policy table unless WithTable is used.
+const DefaultTable = "policies"
+
+// Placeholder is the bind parameter style of a database.
+type Placeholder int
+
+const (
+	// Question binds parameters with "?" (SQLite, MySQL).
+	Question Placeholder = iota
+	// Dollar binds parameters with "$1", "$2"... (PostgreSQL).
+	Dollar
+)
+
+const columns = `id, resource, resource_id, effect, condition_json, version, dry_run, priority, period_start, period_end, state, subjects, actions`
+
+// Repository is a PolicyRepository storing policies in a SQL table.
+type Repository struct {
+	db          *sql.DB
+	table       string
+	placeholder Placeholder
+	now         func() time.Time
+}
+
+// Option configures a Repository built by NewRepository.
+type Option func(*Repository)
+
+// WithTable sets the name of the policy table.
+func WithTable(name string) Option {
+	return func(r *Repository) {
+		r.table = name
+	}
+}
+
+// WithPlaceholder sets the bind parameter style of the database. It defaults
+// to Question.
+func WithPlaceholder(p Placeholder) Option {
+	return func(r *Repository) {
+		r.placeholder = p
+	}
+}
+
+// WithClock sets the clock used to filter active policies when the context
+// carries no evaluation time, and to timestamp writes. It defaults to
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(r *Repository) {
+		r.now = now
+	}
+}
+
+// NewRepository returns a Repository using db. Call Migrate to create the
+// schema.
+func NewRepository(db *sql.DB, opts ...Option) *Repository {
+	r := &Repository{
+		db:    db,
+		table: DefaultTable,
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// FindByResourceAndResourceID returns the policies whose Resource matches
+// resource or one of its ancestors (see policies.MatchResource) and whose
+// ResourceID is resourceID or policies.AnyResourceID. The most specific
+// resources come first; for equally specific ones, policies with resourceID
+// come before those applying to any resource ID, each in creation order.
+// Only policies active at the evaluation time of ctx (see
+// policies.EvaluationTime), or now, are returned, unless ctx carries
+// policies.ContextWithAnyPeriod: the period is filtered by the database, and
+// resource patterns after the query.
+func (r *Repository) FindByResourceAndResourceID(ctx context.Context, resource, resourceID string) ([]policies.Policy, error) {
+	query, args := r.findQuery(ctx, resource, resourceID)
+	pols, err := r.queryPolicies(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := pols[:0]
+	for _, p := range pols {
+		if p.MatchesResource(resource) {
+			out = append(out, p)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Specificity() > out[j].Specificity()
+	})
+	return out, nil
+}
+
+// findQuery returns the query of FindByResourceAndResourceID and its
+// arguments. The literal ancestors of resource and the resource patterns are
+// looked up by separate arms of a UNION ALL, so that both use an index
+// instead of scanning the table.
+func (r *Repository) findQuery(ctx context.Context, resource, resourceID string) (string, []any) {
+	ancestors := policies.ResourceAncestors(resource)
+
+	period := ""
+	var periodArgs []any
+	if !policies.AnyPeriod(ctx) {
+		at, ok := policies.EvaluationTime(ctx)
+		if !ok {
+			at = r.now()
+		}
+		t := at.UnixNano()
+		period = "AND period_start <= ? AND (period_end IS NULL OR period_end > ?)"
+		periodArgs = []any{t, t}
+	}
+
+	args := make([]any, 0, len(ancestors)+10)
+	for _, a := range ancestors {
+		args = append(args, a)
+	}
+	args = append(args, resourceID, policies.AnyResourceID)
+	args = append(args, periodArgs...)
+	args = append(args, true, resourceID, policies.AnyResourceID)
+	args = append(args, periodArgs...)
+	args = append(args, policies.AnyResourceID)
+
+	return fmt.Sprintf(`SELECT %[1]s FROM (
+			SELECT %[1]s, created_at FROM %[2]s
+			WHERE resource IN (%[3]s) AND NOT resource_pattern AND resource_id IN (?, ?)
+			%[4]s
+			UNION ALL
+			SELECT %[1]s, created_at FROM %[2]s
+			WHERE resource_pattern = ? AND resource_id IN (?, ?)
+			%[4]s
+		) AS matched
+		ORDER BY CASE WHEN resource_id = ? THEN 1 ELSE 0 END, created_at, id`,
+		columns, r.table, strings.TrimSuffix(strings.Repeat("?, ", len(ancestors)), ", "), period), args
+}
+
+// FindByTarget returns the policies FindByResourceAndResourceID returns for
+// the resource of t that target its subject and action. Subjects and actions
+// are matched after the query, as they may hold wildcards and groups.
+func (r *Repository) FindByTarget(ctx context.Context, t policies.Target) ([]policies.Policy, error) {
+	pols, err := r.FindByResourceAndResourceID(ctx, t.Resource, t.ResourceID)
+	if err != nil {
+		return nil, err
+	}
+	out := pols[:0]
+	for _, p := range pols {
+		if p.MatchesTarget(t) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// Get returns the policy with id, or policies.ErrPolicyNotFound.
+func (r *Repository) Get(ctx context.Context, id string) (policies.Policy, error) {
+	pols, err := r.queryPolicies(ctx, fmt.Sprintf(`SELECT %s FROM %s WHERE id = ?`, columns, r.table), id)
+	if err != nil {
+		return policies.Policy{}, err
+	}
+	if len(pols) == 0 {
+		return policies.Policy{}, fmt.Errorf("policy %s: %w", id, policies.ErrPolicyNotFound)
+	}
+	return pols[0], nil
+}
+
+// List returns every policy, active or not, in creation order.
+func (r *Repository) List(ctx context.Context) ([]policies.Policy, error) {
+	return r.queryPolicies(ctx, fmt.Sprintf(`SELECT %s FROM %s ORDER BY created_at, id`, columns, r.table))
+}
+
+// Add stores p. It fails with policies.ErrPolicyExists when a policy with the
+// same ID is stored.
+//
+// The policy is stored by a single INSERT, so the primary key of the table
+// decides between concurrent Adds of the same ID. As the package imports no
+// driver, a failed INSERT is reported as policies.ErrPolicyExists when the ID
+// is found stored afterwards.
+func (r *Repository) Add(ctx context.Context, p policies.Policy) error {
+	row, err := newPolicyRow(p)
+	if err != nil {
+		return err
+	}
+
+	now := r.now().UnixNano()
+	_, err = r.db.ExecContext(ctx, r.query(fmt.Sprintf(`INSERT INTO %s (%s, created_at, updated_at, resource_pattern)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, r.table, columns)),
+		append(row.args(), now, now, policies.IsResourcePattern(p.Resource))...)
+	if err == nil {
+		return nil
+	}
+	if exists, ferr := r.exists(ctx, p.ID); ferr == nil && exists {
+		return fmt.Errorf("policy %s: %w", p.ID, policies.ErrPolicyExists)
+	}
+	return fmt.Errorf("insert policy %s: %w", p.ID, err)
+}
+
+// Update replaces the stored policy with the ID of p. It fails with
+// policies.ErrPolicyNotFound when there is none.
+func (r *Repository) Update(ctx context.Context, p policies.Policy) error {
+	row, err := newPolicyRow(p)
+	if err != nil {
+		return err
+	}
+
+	args := append(row.args()[1:], policies.IsResourcePattern(p.Resource), r.now().UnixNano(), p.ID)
+	res, err := r.db.ExecContext(ctx, r.query(fmt.Sprintf(`UPDATE %s SET
+		resource = ?, resource_id = ?, effect = ?, condition_json = ?, version = ?, dry_run = ?,
+		priority = ?, period_start = ?, period_end = ?, state = ?,
+		subjects = ?, actions = ?, resource_pattern = ?, updated_at = ?
+		WHERE id = ?`, r.table)), args...)
+	if err != nil {
+		return fmt.Errorf("update policy %s: %w", p.ID, err)
+	}
+	return r.affected(res, p.ID)
+}
+
+// Delete removes the policy with id. It fails with policies.ErrPolicyNotFound
+// when there is none.
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, r.query(fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, r.table)), id)
+	if err != nil {
+		return fmt.Errorf("delete policy %s: %w", id, err)
+	}
+	return r.affected(res, id)
+}
+
+func (r *Repository) exists(ctx context.Context, id string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, r.query(fmt.Sprintf(`SELECT 1 FROM %s WHERE id = ?`, r.table)), id).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("find policy %s: %w", id, err)
+	}
+	return true, nil
+}
+
+func (r *Repository) affected(res sql.Result, id string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("policy %s: %w", id, policies.ErrPolicyNotFound)
+	}
+	return nil
+}
+
+func (r *Repository) queryPolicies(ctx context.Context, query string, args ...any) ([]policies.Policy, error) {
+	rows, err := r.db.QueryContext(ctx, r.query(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query policies: %w", err)
+	}
+	defer rows.Close()
+
+	var pols []policies.Policy
+	for rows.Next() {
+		var row policyRow
+		if err := rows.Scan(&row.id, &row.resource, &row.resourceID, &row.effect, &row.condition,
+			&row.version, &row.dryRun, &row.priority, &row.periodStart, &row.periodEnd, &row.state,
+			&row.subjects, &row.actions); err != nil {
+			return nil, fmt.Errorf("scan policy: %w", err)
+		}
+		p, err := row.policy()
+		if err != nil {
+			return nil, err
+		}
+		pols = append(pols, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query policies: %w", err)
+	}
+	return pols, nil
+}
+
+// inTx runs fn in a transaction, committed when fn succeeds.
+func (r *Repository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// query rewrites the "?" placeholders of q for the database.
+func (r *Repository) query(q string) string {
+	if r.placeholder != Dollar {
+		return q
+	}
+
+	var sb strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// policyRow is a policy as stored in the policy table.
+type policyRow struct {
+	id          string
+	resource    string
+	resourceID  string
+	effect      string
+	condition   string
+	version     string
+	dryRun      bool
+	priority    sql.NullInt64
+	periodStart int64
+	periodEnd   sql.NullInt64
+	state       string
+	subjects    string
+	actions     string
+}
+
+func newPolicyRow(p policies.Policy) (policyRow, error) {
+	if p.ID == "" {
+		return policyRow{}, fmt.Errorf("policy id is required")
+	}
+	if err := p.Validate(); err != nil {
+		return policyRow{}, fmt.Errorf("policy %s: %w", p.ID, err)
+	}
+
+	cond, err := json.Marshal(p.Condition)
+	if err != nil {
+		return policyRow{}, fmt.Errorf("policy %s: encode condition: %w", p.ID, err)
+	}
+
+	row := policyRow{
+		id:          p.ID,
+		resource:    p.Resource,
+		resourceID:  p.ResourceID,
+		effect:      string(p.Effect),
+		condition:   string(cond),
+		version:     p.Version,
+		dryRun:      p.DryRun,
+		periodStart: p.Period.Start().UnixNano(),
+		state:       string(p.State),
+	}
+	if row.subjects, err = encodeList(p.Subjects); err != nil {
+		return policyRow{}, fmt.Errorf("policy %s: encode subjects: %w", p.ID, err)
+	}
+	if row.actions, err = encodeList(p.Actions); err != nil {
+		return policyRow{}, fmt.Errorf("policy %s: encode actions: %w", p.ID, err)
+	}
+	if p.ExplicitPriority != nil {
+		row.priority = sql.NullInt64{Int64: int64(*p.ExplicitPriority), Valid: true}
+	}
+	if end := p.Period.End(); end != nil {
+		row.periodEnd = sql.NullInt64{Int64: end.UnixNano(), Valid: true}
+	}
+	return row, nil
+}
+
+// args returns the values of the row in the order of columns.
+func (row policyRow) args() []any {
+	return []any{row.id, row.resource, row.resourceID, row.effect, row.condition,
+		row.version, row.dryRun, row.priority, row.periodStart, row.periodEnd, row.state, row.subjects, row.actions}
+}
+
+func (row policyRow) policy() (policies.Policy, error) {
+	p := policies.Policy{
+		ID:         row.id,
+		Resource:   row.resource,
+		ResourceID: row.resourceID,
+		Effect:     policies.Effect(row.effect),
+		Version:    row.version,
+		DryRun:     row.dryRun,
+		State:      policies.State(row.state),
+	}
+	// Literals stay json.Number, so that decimals compare exactly.
+	dec := json.NewDecoder(strings.NewReader(row.condition))
+	dec.UseNumber()
+	if err := dec.Decode(&p.Condition); err != nil {
+		return policies.Policy{}, fmt.Errorf("policy %s: decode condition: %w", row.id, err)
+	}
+	if err := decodeList(row.subjects, &p.Subjects); err != nil {
+		return policies.Policy{}, fmt.Errorf("policy %s: decode subjects: %w", row.id, err)
+	}
+	if err := decodeList(row.actions, &p.Actions); err != nil {
+		return policies.Policy{}, fmt.Errorf("policy %s: decode actions: %w", row.id, err)
+	}
+	if row.priority.Valid {
+		priority := int(row.priority.Int64)
+		p.ExplicitPriority = &priority
+	}
+
+	var end *time.Time
+	if row.periodEnd.Valid {
+		t := time.Unix(0, row.periodEnd.Int64).UTC()
+		end = &t
+	}
+	period, err := timerange.New(time.Unix(0, row.periodStart).UTC(), end)
+	if err != nil {
+		return policies.Policy{}, fmt.Errorf("policy %s: %w", row.id, err)
+	}
+	p.Period = period
+	return p, nil
+}
+
+// encodeList encodes a list of targets as JSON, or "" when empty.
+func encodeList(list []string) (string, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(list)
+	return string(data), err
+}
+
+func decodeList(data string, list *[]string) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), list)
+}
@@ -0,0 +1,357 @@
+//go:build sqlite
+
+package sqldb_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/cache"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/sqldb"
+	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+var (
+	jan2020 = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	jan2021 = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	jan2022 = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func testPolicy(id, resourceID string, end *time.Time) policies.Policy {
+	return policies.Policy{
+		ID:         id,
+		Resource:   "doc",
+		ResourceID: resourceID,
+		Effect:     policies.EffectAllow,
+		Version:    "1",
+		Period:     timerange.MustNew(jan2020, end),
+		Condition: policies.PolicyCondition{Operator: policies.OpAnd, Conditions: []policies.PolicyCondition{
+			{Attribute: "role", Operator: policies.OpIn, Value: []any{"admin", "dev"}},
+			{Attribute: "level", Operator: policies.OpGreater, Value: json.Number("2")},
+		}},
+	}
+}
+
+func ids(pols []policies.Policy) []string {
+	out := make([]string, len(pols))
+	for i, p := range pols {
+		out[i] = p.ID
+	}
+	return out
+}
+
+// hasPrefix reports whether one of lines starts with prefix.
+func hasPrefix(lines []string, prefix string) bool {
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tick returns a clock starting at t and advancing by a second on every call.
+func tick(t time.Time) func() time.Time {
+	return func() time.Time {
+		t = t.Add(time.Second)
+		return t
+	}
+}
+
+func newRepository(t *testing.T, opts ...sqldb.Option) *sqldb.Repository {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "policies.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo := sqldb.NewRepository(db, opts...)
+	if err := repo.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestRepository_Migrate(t *testing.T) {
+	repo := newRepository(t, sqldb.WithTable("acl_policies"))
+	assert.NoError(t, repo.Migrate(context.Background()), "migrations are applied once")
+	assert.NoError(t, repo.Add(context.Background(), testPolicy("a", "1", nil)))
+}
+
+func TestRepository_Migrate_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "policies.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	errs := make([]error, 8)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = sqldb.NewRepository(db).Migrate(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	var applied, latest int
+	assert.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*), MAX(version) FROM policies_migrations`).Scan(&applied, &latest))
+	assert.Equal(t, latest, applied, "every migration is applied once")
+	assert.NoError(t, sqldb.NewRepository(db).Add(ctx, testPolicy("a", "1", nil)))
+}
+
+func TestRepository_Add_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	errs := make([]error, 8)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repo.Add(ctx, testPolicy("a", "1", nil))
+		}()
+	}
+	wg.Wait()
+
+	var added int
+	for _, err := range errs {
+		if err == nil {
+			added++
+			continue
+		}
+		assert.ErrorIs(t, err, policies.ErrPolicyExists)
+	}
+	assert.Equal(t, 1, added)
+}
+
+func TestRepository_CRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t, sqldb.WithClock(tick(jan2020)))
+
+	p := testPolicy("a", "1", utils.Ptr(jan2022))
+	p.DryRun = true
+	p.State = policies.StateApproved
+	p.Subjects = []string{"alice", "group:admins"}
+	p.Actions = []string{"read"}
+	p.ExplicitPriority = utils.Ptr(10)
+	assert.NoError(t, repo.Add(ctx, p))
+	assert.ErrorIs(t, repo.Add(ctx, p), policies.ErrPolicyExists)
+	assert.ErrorContains(t, repo.Add(ctx, testPolicy("", "1", nil)), "policy id is required")
+
+	got, err := repo.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, p, got)
+
+	p.Effect = policies.EffectDeny
+	p.ExplicitPriority = nil
+	p.Actions = nil
+	assert.NoError(t, repo.Update(ctx, p))
+	got, _ = repo.Get(ctx, "a")
+	assert.Equal(t, p, got)
+	assert.ErrorIs(t, repo.Update(ctx, testPolicy("x", "1", nil)), policies.ErrPolicyNotFound)
+
+	assert.NoError(t, repo.Add(ctx, testPolicy("b", "1", nil)))
+	pols, err := repo.List(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids(pols))
+
+	assert.NoError(t, repo.Delete(ctx, "a"))
+	assert.ErrorIs(t, repo.Delete(ctx, "a"), policies.ErrPolicyNotFound)
+	_, err = repo.Get(ctx, "a")
+	assert.ErrorIs(t, err, policies.ErrPolicyNotFound)
+}
+
+func TestRepository_FindByResourceAndResourceID(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t, sqldb.WithClock(tick(jan2021)))
+
+	for _, p := range []policies.Policy{
+		testPolicy("any", policies.AnyResourceID, nil),
+		testPolicy("one", "1", nil),
+		testPolicy("expired", "1", utils.Ptr(jan2021)),
+		testPolicy("two", "2", nil),
+		testPolicy("one-later", "1", utils.Ptr(jan2022)),
+	} {
+		assert.NoError(t, repo.Add(ctx, p))
+	}
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		resourceID string
+		ids        []string
+	}{
+		{name: "active now", ctx: ctx, resourceID: "1", ids: []string{"one", "one-later", "any"}},
+		{name: "wildcard fallback", ctx: ctx, resourceID: "3", ids: []string{"any"}},
+		{name: "evaluation time", ctx: policies.ContextWithEvaluationTime(ctx, jan2020), resourceID: "1", ids: []string{"one", "expired", "one-later", "any"}},
+		{name: "nothing active", ctx: policies.ContextWithEvaluationTime(ctx, jan2020.Add(-time.Hour)), resourceID: "1", ids: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pols, err := repo.FindByResourceAndResourceID(tt.ctx, "doc", tt.resourceID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ids, ids(pols))
+		})
+	}
+}
+
+func TestRepository_FindByResourceAndResourceID_Hierarchy(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t, sqldb.WithClock(tick(jan2021)))
+
+	for _, p := range []struct{ id, resource, resourceID string }{
+		{"org", "orgs/42", policies.AnyResourceID},
+		{"projects", "orgs/*/projects/**", policies.AnyResourceID},
+		{"other-projects", "orgs/*/teams/**", policies.AnyResourceID},
+		{"project-doc", "orgs/42/projects/7", "9"},
+	} {
+		pol := testPolicy(p.id, p.resourceID, nil)
+		pol.Resource = p.resource
+		assert.NoError(t, repo.Add(ctx, pol))
+	}
+
+	pols, err := repo.FindByResourceAndResourceID(ctx, "orgs/42/projects/7/docs", "9")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"project-doc", "projects", "org"}, ids(pols))
+}
+
+func TestRepository_FindByResourceAndResourceID_UsesIndexes(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "policies.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	repo := sqldb.NewRepository(db)
+	if err := repo.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"period":     ctx,
+		"any period": policies.ContextWithAnyPeriod(ctx),
+	} {
+		t.Run(name, func(t *testing.T) {
+			query, args := repo.FindQuery(ctx, "orgs/42/projects/7", "9")
+			rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var plan []string
+			for rows.Next() {
+				var id, parent, unused int
+				var detail string
+				if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+					t.Fatal(err)
+				}
+				plan = append(plan, detail)
+			}
+			assert.NoError(t, rows.Err())
+			assert.NotContains(t, plan, "SCAN policies")
+			assert.True(t, hasPrefix(plan, "SEARCH policies USING INDEX policies_resource_idx (resource=? AND resource_id=?"), "plan: %q", plan)
+			assert.True(t, hasPrefix(plan, "SEARCH policies USING INDEX policies_pattern_idx (resource_pattern=? AND resource_id=?"), "plan: %q", plan)
+		})
+	}
+}
+
+func TestRepository_FindByTarget(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t, sqldb.WithClock(tick(jan2021)))
+
+	admins := testPolicy("admins", "1", nil)
+	admins.Subjects = []string{"group:admins"}
+	readers := testPolicy("readers", policies.AnyResourceID, nil)
+	readers.Actions = []string{"read", "list"}
+	for _, p := range []policies.Policy{admins, readers} {
+		assert.NoError(t, repo.Add(ctx, p))
+	}
+
+	pols, err := repo.FindByTarget(ctx, policies.Target{Resource: "doc", ResourceID: "1", Action: "read"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"readers"}, ids(pols))
+
+	pols, err = repo.FindByTarget(ctx, policies.Target{
+		Subject:  policies.Subject{ID: "bob", Groups: []string{"admins"}},
+		Resource: "doc", ResourceID: "1", Action: "write",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admins"}, ids(pols))
+}
+
+func TestRepository_Evaluator(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	assert.NoError(t, repo.Add(ctx, testPolicy("a", "1", utils.Ptr(jan2021))))
+
+	ev := policies.NewEvaluator(native.NewNativeEngine(), repo)
+	attrs := policies.MapAttributes{"role": "dev", "level": 3.0}
+
+	d, err := ev.Eval(ctx, policies.EvaluatorRequest{Resource: "doc", ResourceID: "1", Context: attrs, Time: jan2020})
+	assert.NoError(t, err)
+	assert.Equal(t, policies.OutcomeAllow, d.Outcome)
+
+	d, err = ev.Eval(ctx, policies.EvaluatorRequest{Resource: "doc", ResourceID: "1", Context: attrs, Time: jan2022})
+	assert.NoError(t, err)
+	assert.Equal(t, policies.OutcomeNotApplicable, d.Outcome)
+}
+
+func TestRepository_Decimals(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	p := testPolicy("cents", "1", nil)
+	p.Condition = policies.PolicyCondition{Attribute: "amount", Operator: policies.OpMod, Value: 0.01}
+	assert.NoError(t, repo.Add(ctx, p))
+
+	stored, err := repo.Get(ctx, "cents")
+	assert.NoError(t, err)
+	assert.Equal(t, json.Number("0.01"), stored.Condition.Value)
+
+	ok, err := native.NewNativeEngine().Eval(stored.Condition, policies.MapAttributes{"amount": json.Number("10.20")})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestRepository_Cached(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	assert.NoError(t, repo.Add(ctx, testPolicy("a", "1", utils.Ptr(jan2021))))
+
+	pols, err := repo.FindByResourceAndResourceID(policies.ContextWithAnyPeriod(policies.ContextWithEvaluationTime(ctx, jan2022)), "doc", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(pols), "periods are not filtered")
+
+	ev := policies.NewEvaluator(native.NewNativeEngine(), cache.NewRepository(repo))
+	attrs := policies.MapAttributes{"role": "dev", "level": 3.0}
+
+	d, err := ev.Eval(ctx, policies.EvaluatorRequest{Resource: "doc", ResourceID: "1", Context: attrs, Time: jan2022})
+	assert.NoError(t, err)
+	assert.Equal(t, policies.OutcomeNotApplicable, d.Outcome)
+	assert.Len(t, d.Inactive, 1)
+
+	d, err = ev.Eval(ctx, policies.EvaluatorRequest{Resource: "doc", ResourceID: "1", Context: attrs, Time: jan2020})
+	assert.NoError(t, err)
+	assert.Equal(t, policies.OutcomeAllow, d.Outcome, "cached results do not depend on the first evaluation time")
+}
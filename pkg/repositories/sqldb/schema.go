@@ -0,0 +1,88 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// migrations are the schema changes applied by Migrate, in order. The version
+// of a migration is its index plus one. "{table}" is replaced by the policy
+// table name.
+var migrations = []string{
+	`CREATE TABLE {table} (
+		id             VARCHAR(255) NOT NULL PRIMARY KEY,
+		resource       VARCHAR(255) NOT NULL,
+		resource_id    VARCHAR(255) NOT NULL DEFAULT '',
+		effect         VARCHAR(16)  NOT NULL,
+		condition_json TEXT         NOT NULL,
+		version        VARCHAR(255) NOT NULL DEFAULT '',
+		dry_run        BOOLEAN      NOT NULL DEFAULT FALSE,
+		priority       INTEGER      NULL,
+		period_start   BIGINT       NOT NULL,
+		period_end     BIGINT       NULL,
+		created_at     BIGINT       NOT NULL,
+		updated_at     BIGINT       NOT NULL
+	)`,
+	`CREATE INDEX {table}_resource_idx ON {table} (resource, resource_id, period_start)`,
+	`ALTER TABLE {table} ADD COLUMN state VARCHAR(16) NOT NULL DEFAULT ''`,
+	`ALTER TABLE {table} ADD COLUMN subjects TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE {table} ADD COLUMN actions TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE {table} ADD COLUMN resource_pattern BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE INDEX {table}_pattern_idx ON {table} (resource_pattern, resource_id, period_start)`,
+}
+
+// Migrate creates or upgrades the schema of the policy table. Applied
+// migrations are recorded in the "<table>_migrations" table, so Migrate can
+// run on every start. Every migration runs in its own transaction, which
+// first records its version: when several processes migrate at once, the
+// primary key of the migrations table lets only one of them apply it, and
+// the others move on to the next version.
+func (r *Repository) Migrate(ctx context.Context) error {
+	versions := r.table + "_migrations"
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL PRIMARY KEY, applied_at BIGINT NOT NULL)`, versions))
+	if err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	for {
+		current, err := r.schemaVersion(ctx, versions)
+		if err != nil {
+			return err
+		}
+		if current >= len(migrations) {
+			return nil
+		}
+
+		version := current + 1
+		err = r.inTx(ctx, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, r.query(fmt.Sprintf(
+				`INSERT INTO %s (version, applied_at) VALUES (?, ?)`, versions)), version, r.now().UnixNano())
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, strings.ReplaceAll(migrations[current], "{table}", r.table))
+			return err
+		})
+		if err == nil {
+			continue
+		}
+		// Another process may have applied the migration meanwhile.
+		if applied, verr := r.schemaVersion(ctx, versions); verr == nil && applied >= version {
+			continue
+		}
+		return fmt.Errorf("apply migration %d: %w", version, err)
+	}
+}
+
+// schemaVersion returns the latest version recorded in the versions table.
+func (r *Repository) schemaVersion(ctx context.Context, versions string) (int, error) {
+	var current int
+	row := r.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s`, versions))
+	if err := row.Scan(&current); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return current, nil
+}
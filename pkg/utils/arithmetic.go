@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Arithmetic functions take numbers, json.Number, *big.Rat and numeric
+// strings, and other values AnyToFloat64 converts. When every operand is an
+// integer or a decimal the result is exact: an int64 when it is an integer
+// that fits and a *big.Rat otherwise. Any float operand makes the result a
+// float64.
+
+// toNumber returns v as a number for arithmetic: numbers and numeric strings
+// as numberOf does, and other values AnyToFloat64 converts as floats.
+func toNumber(v any) (number, error) {
+	if n, ok := numberOf(v, CoerceNumericStrings); ok {
+		return n, nil
+	}
+	f, err := AnyToFloat64(v)
+	if err != nil {
+		return number{}, err
+	}
+	return number{f: f}, nil
+}
+
+// value returns n as the result of an arithmetic function.
+func (n number) value() any {
+	if !n.exact {
+		return n.f
+	}
+	if n.r.IsInt() && n.r.Num().IsInt64() {
+		return n.r.Num().Int64()
+	}
+	return n.r
+}
+
+// result returns n as the result of an arithmetic function failing with
+// err.
+func result(n number, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return n.value(), nil
+}
+
+// binary applies exact or float to a and b.
+func binary(a, b any, exact func(x, y *big.Rat) (*big.Rat, error), float func(x, y float64) float64) (number, error) {
+	x, err := toNumber(a)
+	if err != nil {
+		return number{}, err
+	}
+	y, err := toNumber(b)
+	if err != nil {
+		return number{}, err
+	}
+	if x.exact && y.exact {
+		r, err := exact(x.r, y.r)
+		if err != nil {
+			return number{}, err
+		}
+		return number{r: r, exact: true}, nil
+	}
+	return number{f: float(x.float(), y.float())}, nil
+}
+
+// Add returns a + b.
+func Add(a, b any) (any, error) {
+	n, err := binary(a, b, func(x, y *big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Add(x, y), nil
+	}, func(x, y float64) float64 { return x + y })
+	return result(n, err)
+}
+
+// Sub returns a - b.
+func Sub(a, b any) (any, error) {
+	n, err := binary(a, b, func(x, y *big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Sub(x, y), nil
+	}, func(x, y float64) float64 { return x - y })
+	return result(n, err)
+}
+
+// Mul returns a * b.
+func Mul(a, b any) (any, error) {
+	n, err := binary(a, b, func(x, y *big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Mul(x, y), nil
+	}, func(x, y float64) float64 { return x * y })
+	return result(n, err)
+}
+
+// Div returns a / b. Dividing by zero is an error.
+func Div(a, b any) (any, error) {
+	n, err := divide(a, b, "division by zero", func(x, y *big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Quo(x, y), nil
+	}, func(x, y float64) float64 { return x / y })
+	return result(n, err)
+}
+
+// Mod returns the remainder of a divided by b, truncating the quotient as
+// the % operator of Go does: the remainder has the sign of a. Dividing by
+// zero is an error.
+func Mod(a, b any) (any, error) {
+	return result(mod(a, b))
+}
+
+func mod(a, b any) (number, error) {
+	return divide(a, b, "modulo by zero", func(x, y *big.Rat) (*big.Rat, error) {
+		q := new(big.Rat).Quo(x, y)
+		t := new(big.Int).Quo(q.Num(), q.Denom())
+		return new(big.Rat).Sub(x, new(big.Rat).Mul(y, new(big.Rat).SetInt(t))), nil
+	}, math.Mod)
+}
+
+// divide is binary, failing with msg when b is zero.
+func divide(a, b any, msg string, exact func(x, y *big.Rat) (*big.Rat, error), float func(x, y float64) float64) (number, error) {
+	if y, err := toNumber(b); err == nil && y.isZero() {
+		if _, err := toNumber(a); err != nil {
+			return number{}, err
+		}
+		return number{}, fmt.Errorf("%s", msg)
+	}
+	return binary(a, b, exact, float)
+}
+
+// Min returns the least of a and b, as given.
+func Min(a, b any) (any, error) {
+	return pick(a, b, -1)
+}
+
+// Max returns the greatest of a and b, as given.
+func Max(a, b any) (any, error) {
+	return pick(a, b, 1)
+}
+
+// pick returns a when it compares to b as want or is equal, and b otherwise.
+func pick(a, b any, want int) (any, error) {
+	x, err := toNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toNumber(b)
+	if err != nil {
+		return nil, err
+	}
+	c, err := x.cmp(y)
+	if err != nil {
+		return nil, err
+	}
+	if c == want || c == 0 {
+		return a, nil
+	}
+	return b, nil
+}
+
+// Abs returns the absolute value of a.
+func Abs(a any) (any, error) {
+	x, err := toNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	if x.exact {
+		return number{r: new(big.Rat).Abs(x.r), exact: true}.value(), nil
+	}
+	return math.Abs(x.f), nil
+}
+
+// HasRemainder reports whether a divided by b leaves the remainder r, as
+// computed by Mod. When a, b and r are integers or decimals the result is
+// exact, so that "10.20" is a multiple of "0.01"; otherwise remainders
+// within 1e-9 of each other are the same.
+func HasRemainder(a, b, r any) (bool, error) {
+	n, err := mod(a, b)
+	if err != nil {
+		return false, err
+	}
+	want, err := toNumber(r)
+	if err != nil {
+		return false, err
+	}
+	if n.exact && want.exact {
+		return n.r.Cmp(want.r) == 0, nil
+	}
+	return math.Abs(n.float()-want.float()) < 1e-9, nil
+}
+
+// Divisible reports whether a is a multiple of b (see HasRemainder).
+func Divisible(a, b any) (bool, error) {
+	return HasRemainder(a, b, 0)
+}
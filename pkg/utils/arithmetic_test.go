@@ -0,0 +1,101 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(a, b any) (any, error)
+		a, b any
+		out  any
+		err  string
+	}{
+		{name: "add ints", fn: utils.Add, a: 1, b: uint8(2), out: int64(3)},
+		{name: "add beyond int64", fn: utils.Add, a: uint64(math.MaxUint64), b: 1, out: new(big.Rat).SetFrac(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(1))},
+		{name: "add decimals", fn: utils.Add, a: json.Number("0.1"), b: json.Number("0.2"), out: big.NewRat(3, 10)},
+		{name: "add floats", fn: utils.Add, a: 0.1, b: 0.2, out: 0.30000000000000004},
+		{name: "sub", fn: utils.Sub, a: "10.5", b: json.Number("0.5"), out: int64(10)},
+		{name: "mul", fn: utils.Mul, a: json.Number("0.1"), b: 3, out: big.NewRat(3, 10)},
+		{name: "div", fn: utils.Div, a: 10, b: 4, out: big.NewRat(5, 2)},
+		{name: "div float", fn: utils.Div, a: 10, b: 4.0, out: 2.5},
+		{name: "div by zero", fn: utils.Div, a: 1, b: 0.0, err: "division by zero"},
+		{name: "mod", fn: utils.Mod, a: json.Number("10.25"), b: "0.1", out: big.NewRat(1, 20)},
+		{name: "mod negative", fn: utils.Mod, a: -7, b: 5, out: int64(-2)},
+		{name: "mod float", fn: utils.Mod, a: 7.5, b: 2, out: 1.5},
+		{name: "mod by zero", fn: utils.Mod, a: 1, b: "0", err: "modulo by zero"},
+		{name: "min", fn: utils.Min, a: json.Number("2.50"), b: 3, out: json.Number("2.50")},
+		{name: "max", fn: utils.Max, a: json.Number("2.50"), b: 3, out: 3},
+		{name: "not numeric", fn: utils.Add, a: "abc", b: 2, err: `strconv.ParseFloat: parsing "abc": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tt.fn(tt.a, tt.b)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
+
+func TestAbs(t *testing.T) {
+	out, err := utils.Abs(json.Number("-0.25"))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewRat(1, 4), out)
+
+	out, err = utils.Abs(-2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, out)
+}
+
+func TestHasRemainder(t *testing.T) {
+	ok, err := utils.HasRemainder(json.Number("10.25"), "0.1", json.Number("0.05"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = utils.HasRemainder(17, 5, 3)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDivisible(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b any
+		out  bool
+		err  string
+	}{
+		{name: "ints", a: 9, b: 3, out: true},
+		{name: "ints remainder", a: 10, b: 3, out: false},
+		{name: "decimal cents", a: json.Number("10.20"), b: json.Number("0.01"), out: true},
+		{name: "decimal strings", a: "0.3", b: "0.1", out: true},
+		{name: "decimal remainder", a: json.Number("10.205"), b: "0.01", out: false},
+		{name: "floats", a: 7.5, b: 2.5, out: true},
+		{name: "float remainder", a: 7.5, b: 2, out: false},
+		{name: "zero divisor", a: json.Number("1"), b: "0.00", err: "modulo by zero"},
+		{name: "not numeric", a: "abc", b: 2, err: `strconv.ParseFloat: parsing "abc": invalid syntax`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := utils.Divisible(tt.a, tt.b)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
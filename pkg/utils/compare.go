@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Coercion sets how Equal and Compare treat values of different types.
+type Coercion int
+
+const (
+	// CoerceNumbers compares numbers of any int, uint or float kind,
+	// json.Number and *big.Rat by value, so that int(5) equals float64(5),
+	// and time.Time values with the values AnyToTime converts. It is the
+	// default. Integers and decimals, such as json.Number("0.1"), compare
+	// exactly; a float compares with the float64 nearest to the other number.
+	CoerceNumbers Coercion = iota
+	// CoerceNumericStrings also compares numeric strings as numbers, so that
+	// "5" equals 5.
+	CoerceNumericStrings
+	// CoerceNone compares values of the same type only.
+	CoerceNone
+)
+
+var coercionNames = [...]string{
+	CoerceNumbers:        "numbers",
+	CoerceNumericStrings: "numeric-strings",
+	CoerceNone:           "none",
+}
+
+func (c Coercion) String() string {
+	if c >= 0 && int(c) < len(coercionNames) {
+		return coercionNames[c]
+	}
+	return fmt.Sprintf("Coercion(%d)", int(c))
+}
+
+// number is a numeric value: exact when it is an integer or a decimal, such
+// as a json.Number or a numeric string, and a float otherwise.
+type number struct {
+	r     *big.Rat
+	f     float64
+	exact bool
+}
+
+func exactInt(i int64) number {
+	return number{r: new(big.Rat).SetInt64(i), exact: true}
+}
+
+func exactUint(u uint64) number {
+	return number{r: new(big.Rat).SetInt(new(big.Int).SetUint64(u)), exact: true}
+}
+
+// numberOf returns v as a number. Numeric strings are numbers under
+// CoerceNumericStrings only.
+func numberOf(v any, c Coercion) (number, bool) {
+	switch t := v.(type) {
+	case int:
+		return exactInt(int64(t)), true
+	case int8:
+		return exactInt(int64(t)), true
+	case int16:
+		return exactInt(int64(t)), true
+	case int32:
+		return exactInt(int64(t)), true
+	case int64:
+		return exactInt(t), true
+	case uint:
+		return exactUint(uint64(t)), true
+	case uint8:
+		return exactUint(uint64(t)), true
+	case uint16:
+		return exactUint(uint64(t)), true
+	case uint32:
+		return exactUint(uint64(t)), true
+	case uint64:
+		return exactUint(t), true
+	case float32:
+		return number{f: float64(t)}, true
+	case float64:
+		return number{f: t}, true
+	case *big.Rat:
+		if t != nil {
+			return number{r: t, exact: true}, true
+		}
+	case json.Number:
+		return parseNumber(string(t))
+	case string:
+		if c == CoerceNumericStrings {
+			return parseNumber(strings.TrimSpace(t))
+		}
+	}
+	return number{}, false
+}
+
+// parseNumber parses s as an exact decimal, or as a float when its exponent
+// is out of the range of float64 or it is not finite.
+func parseNumber(s string) (number, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return number{}, false
+	}
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return number{f: f}, true
+	}
+	if r, ok := new(big.Rat).SetString(s); ok {
+		return number{r: r, exact: true}, true
+	}
+	return number{f: f}, true
+}
+
+// cmp compares n and m, exactly when both are exact and as floats otherwise.
+// It fails when either is NaN.
+func (n number) cmp(m number) (int, error) {
+	if n.exact && m.exact {
+		return n.r.Cmp(m.r), nil
+	}
+	if n.isNaN() || m.isNaN() {
+		return 0, fmt.Errorf("cannot compare NaN")
+	}
+	return cmp.Compare(n.float(), m.float()), nil
+}
+
+func (n number) isZero() bool {
+	if n.exact {
+		return n.r.Sign() == 0
+	}
+	return n.f == 0
+}
+
+func (n number) isNaN() bool {
+	return !n.exact && math.IsNaN(n.f)
+}
+
+// float returns n, or the float64 nearest to it when exact.
+func (n number) float() float64 {
+	if n.exact {
+		f, _ := n.r.Float64()
+		return f
+	}
+	return n.f
+}
+
+// CompareNumbers compares a and b when both are numbers under c, reporting
+// whether they are.
+func CompareNumbers(a, b any, c Coercion) (int, bool, error) {
+	if c == CoerceNone {
+		return 0, false, nil
+	}
+	x, ok := numberOf(a, c)
+	if !ok {
+		return 0, false, nil
+	}
+	y, ok := numberOf(b, c)
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := x.cmp(y)
+	return n, true, err
+}
+
+// isTime reports whether v is a time.Time or a *time.Time.
+func isTime(v any) bool {
+	switch v.(type) {
+	case time.Time, *time.Time:
+		return true
+	default:
+		return false
+	}
+}
+
+// Equal reports whether a and b are equal under c. Besides numbers, values
+// are equal when a time.Time and the value AnyToTime converts are the same
+// instant, and otherwise when they are deeply equal.
+func Equal(a, b any, c Coercion) bool {
+	if n, ok, err := CompareNumbers(a, b, c); ok {
+		return err == nil && n == 0
+	}
+	if c != CoerceNone && (isTime(a) || isTime(b)) {
+		at, errA := AnyToTime(a)
+		bt, errB := AnyToTime(b)
+		if errA == nil && errB == nil {
+			return at.Equal(bt)
+		}
+	}
+	if IsScalar(a) && IsScalar(b) {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Compare returns -1, 0 or 1 as a is less than, equal to or greater than b
+// under c. Numbers compare by value and values AnyToTime converts, such as
+// dates, as times; other values must be numbers, strings or times of the
+// same type.
+func Compare(a, b any, c Coercion) (int, error) {
+	if n, ok, err := CompareNumbers(a, b, c); ok {
+		return n, err
+	}
+	if c != CoerceNone {
+		at, errA := AnyToTime(a)
+		bt, errB := AnyToTime(b)
+		if errA == nil && errB == nil {
+			return at.Compare(bt), nil
+		}
+	}
+
+	aval, bval := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !aval.IsValid() || !bval.IsValid() || aval.Type() != bval.Type() {
+		return 0, fmt.Errorf("cant compare differente type: %T x %T", a, b)
+	}
+
+	switch aval.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(aval.Int(), bval.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(aval.Uint(), bval.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(aval.Float()) || math.IsNaN(bval.Float()) {
+			return 0, fmt.Errorf("cannot compare NaN")
+		}
+		return cmp.Compare(aval.Float(), bval.Float()), nil
+	case reflect.String:
+		return strings.Compare(aval.String(), bval.String()), nil
+	}
+	if at, ok := a.(time.Time); ok {
+		return at.Compare(b.(time.Time)), nil
+	}
+	return 0, fmt.Errorf("unsupported type for comparison: %v", aval.Kind())
+}
+
+// IsScalar reports whether v is a boolean, number or string, for which
+// reflect.DeepEqual is the same as ==.
+func IsScalar(v any) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	default:
+		return false
+	}
+}
+
+// Between reports whether v lies between min and max, inclusive or not.
+// Unless c is CoerceNone, values are compared as numbers, numeric strings
+// included, then as times and finally as strings, at the first level where
+// the three convert; under CoerceNone they compare with Compare. An empty
+// range, where min is greater than max, contains no value.
+func Between(v, min, max any, inclusive bool, c Coercion) (bool, error) {
+	if c == CoerceNone {
+		lo, err := Compare(v, min, c)
+		if err != nil {
+			return false, err
+		}
+		hi, err := Compare(v, max, c)
+		if err != nil {
+			return false, err
+		}
+		return within(lo, hi, inclusive), nil
+	}
+
+	if lo, ok, err := CompareNumbers(v, min, CoerceNumericStrings); ok {
+		hi, ok, errHi := CompareNumbers(v, max, CoerceNumericStrings)
+		if ok {
+			if err != nil || errHi != nil {
+				return false, fmt.Errorf("cannot compare NaN")
+			}
+			if n, _, _ := CompareNumbers(min, max, CoerceNumericStrings); n > 0 {
+				return false, nil
+			}
+			return within(lo, hi, inclusive), nil
+		}
+	}
+
+	mint, errMin := AnyToTime(min)
+	maxt, errMax := AnyToTime(max)
+	if errMin == nil && errMax == nil {
+		if t, err := AnyToTime(v); err == nil {
+			return within(t.Compare(mint), t.Compare(maxt), inclusive), nil
+		}
+	}
+
+	mins, errMin := AnyToString(min)
+	maxs, errMax := AnyToString(max)
+	if errMin == nil && errMax == nil {
+		if s, err := AnyToString(v); err == nil {
+			if mins > maxs {
+				return false, nil
+			}
+			return within(strings.Compare(s, mins), strings.Compare(s, maxs), inclusive), nil
+		}
+	}
+
+	return false, fmt.Errorf("mismatched types for between: %T, %T, %T", v, min, max)
+}
+
+// within reports whether the comparisons lo and hi of a value with the
+// bounds of a range place it inside.
+func within(lo, hi int, inclusive bool) bool {
+	if inclusive {
+		return lo >= 0 && hi <= 0
+	}
+	return lo > 0 && hi < 0
+}
@@ -0,0 +1,114 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/utils"
+)
+
+func TestEqual(t *testing.T) {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		a, b any
+		c    utils.Coercion
+		out  bool
+	}{
+		{"int and float", 5, 5.0, utils.CoerceNumbers, true},
+		{"int and uint", int8(5), uint64(5), utils.CoerceNumbers, true},
+		{"int and json number", 5, json.Number("5"), utils.CoerceNumbers, true},
+		{"large ints", uint64(math.MaxUint64), uint64(math.MaxUint64 - 1), utils.CoerceNumbers, false},
+		{"int and fraction", 5, 5.5, utils.CoerceNumbers, false},
+		{"decimals", json.Number("0.30"), json.Number("0.3"), utils.CoerceNumbers, true},
+		{"decimals beyond float precision", json.Number("0.30000000000000001"), json.Number("0.3"), utils.CoerceNumbers, false},
+		{"decimal and rat", json.Number("0.25"), big.NewRat(1, 4), utils.CoerceNumbers, true},
+		{"float and decimal", 0.1, json.Number("0.1"), utils.CoerceNumbers, true},
+		{"NaN", math.NaN(), math.NaN(), utils.CoerceNumbers, false},
+		{"int and numeric string", 5, "5", utils.CoerceNumbers, false},
+		{"int and numeric string coerced", 5, " 5.0", utils.CoerceNumericStrings, true},
+		{"time and date", day, "2021-06-01", utils.CoerceNumbers, true},
+		{"strings", "a", "a", utils.CoerceNumbers, true},
+		{"slices", []any{1, "a"}, []any{1, "a"}, utils.CoerceNumbers, true},
+		{"strict int and float", 5, 5.0, utils.CoerceNone, false},
+		{"strict ints", 5, 5, utils.CoerceNone, true},
+		{"strict time and date", day, "2021-06-01", utils.CoerceNone, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.out, utils.Equal(tt.a, tt.b, tt.c))
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b any
+		c    utils.Coercion
+		out  int
+		err  string
+	}{
+		{name: "int and float", a: 5, b: 4.5, c: utils.CoerceNumbers, out: 1},
+		{name: "uint and negative int", a: uint(1), b: -1, c: utils.CoerceNumbers, out: 1},
+		{name: "json numbers", a: json.Number("1.5"), b: json.Number("10"), c: utils.CoerceNumbers, out: -1},
+		{name: "decimal strings", a: "100.30", b: "100.29999999999999999", c: utils.CoerceNumericStrings, out: 1},
+		{name: "dates", a: "2021-06-01", b: "2021-05-31", c: utils.CoerceNumbers, out: 1},
+		{name: "strings", a: "a", b: "b", c: utils.CoerceNumbers, out: -1},
+		{name: "numeric string", a: "10", b: 9, c: utils.CoerceNumericStrings, out: 1},
+		{name: "int and string", a: 5, b: "5", c: utils.CoerceNumbers, err: "cant compare differente type: int x string"},
+		{name: "strict int and float", a: 5, b: 4.5, c: utils.CoerceNone, err: "cant compare differente type: int x float64"},
+		{name: "strict times", a: time.Unix(10, 0), b: time.Unix(20, 0), c: utils.CoerceNone, out: -1},
+		{name: "NaN", a: math.NaN(), b: 1, c: utils.CoerceNumbers, err: "cannot compare NaN"},
+		{name: "slices", a: []int{1}, b: []int{2}, c: utils.CoerceNumbers, err: "unsupported type for comparison: slice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := utils.Compare(tt.a, tt.b, tt.c)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+}
+
+func TestSet_Contains(t *testing.T) {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	set := utils.NewSet([]any{1, 2.5, "3", json.Number("4"), "2021-06-01", []any{"x"}}, false)
+
+	tests := []struct {
+		name string
+		v    any
+		c    utils.Coercion
+		out  bool
+	}{
+		{"same int", 1, utils.CoerceNone, true},
+		{"float of an int", 1.0, utils.CoerceNumbers, true},
+		{"strict float of an int", 1.0, utils.CoerceNone, false},
+		{"int of a json number", int64(4), utils.CoerceNumbers, true},
+		{"int of a numeric string", 3, utils.CoerceNumbers, false},
+		{"int of a numeric string coerced", 3, utils.CoerceNumericStrings, true},
+		{"numeric string of a float", "2.5", utils.CoerceNumericStrings, true},
+		{"decimal of a float", json.Number("2.50"), utils.CoerceNumbers, true},
+		{"decimal of a json number", json.Number("4.0"), utils.CoerceNumbers, true},
+		{"time of a date", day, utils.CoerceNumbers, true},
+		{"slice", []any{"x"}, utils.CoerceNumbers, true},
+		{"absent", 7, utils.CoerceNumericStrings, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.out, set.Contains(tt.v, tt.c))
+		})
+	}
+}
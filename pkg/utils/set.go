@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"reflect"
+)
+
+// Set is a set of values whose membership follows Equal. Numbers and other
+// scalars are hashed; other values are compared one by one.
+type Set struct {
+	// scalars maps the scalar elements to themselves.
+	scalars map[any]struct{}
+	// numbers holds the numeric elements, and strings the numeric strings,
+	// for CoerceNumericStrings.
+	numbers numberSet
+	strings numberSet
+	others  []any
+}
+
+// numberSet is a set of numbers compared as number.cmp does.
+type numberSet struct {
+	// exact maps the exact numbers by their fraction and floats the other
+	// numbers, while approx maps the float64 nearest to each exact number.
+	exact  map[string]struct{}
+	floats map[float64]struct{}
+	approx map[float64]struct{}
+}
+
+func newNumberSet() numberSet {
+	return numberSet{
+		exact:  make(map[string]struct{}),
+		floats: make(map[float64]struct{}),
+		approx: make(map[float64]struct{}),
+	}
+}
+
+func (s numberSet) add(n number) {
+	if n.exact {
+		s.exact[n.r.RatString()] = struct{}{}
+		s.approx[n.float()] = struct{}{}
+		return
+	}
+	s.floats[n.f] = struct{}{}
+}
+
+func (s numberSet) contains(n number) bool {
+	if n.exact {
+		if _, ok := s.exact[n.r.RatString()]; ok {
+			return true
+		}
+		_, ok := s.floats[n.float()]
+		return ok
+	}
+	if _, ok := s.floats[n.f]; ok {
+		return true
+	}
+	_, ok := s.approx[n.f]
+	return ok
+}
+
+// NewSet returns the set of the elements of the slice or array v, or of its
+// keys when v is a map and keys is true. Any other value is an empty set.
+func NewSet(v any, keys bool) *Set {
+	s := &Set{
+		scalars: make(map[any]struct{}),
+		numbers: newNumberSet(),
+		strings: newNumberSet(),
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			s.add(rv.Index(i).Interface())
+		}
+	case reflect.Map:
+		if keys {
+			for _, key := range rv.MapKeys() {
+				s.add(key.Interface())
+			}
+		}
+	}
+	return s
+}
+
+func (s *Set) add(v any) {
+	if n, ok := numberOf(v, CoerceNumbers); ok {
+		s.numbers.add(n)
+	} else if n, ok := numberOf(v, CoerceNumericStrings); ok {
+		s.strings.add(n)
+	}
+	if IsScalar(v) {
+		s.scalars[v] = struct{}{}
+		return
+	}
+	s.others = append(s.others, v)
+}
+
+// Contains reports whether an element of s is Equal to v under c.
+func (s *Set) Contains(v any, c Coercion) bool {
+	if n, ok := numberOf(v, c); ok && c != CoerceNone {
+		if s.numbers.contains(n) {
+			return true
+		}
+		if c == CoerceNumericStrings && s.strings.contains(n) {
+			return true
+		}
+	}
+	if IsScalar(v) {
+		if _, ok := s.scalars[v]; ok {
+			return true
+		}
+	} else if isTime(v) && c != CoerceNone {
+		// Strings and numbers may convert to the same instant.
+		for e := range s.scalars {
+			if Equal(v, e, c) {
+				return true
+			}
+		}
+	}
+	for _, e := range s.others {
+		if Equal(v, e, c) {
+			return true
+		}
+	}
+	return false
+}
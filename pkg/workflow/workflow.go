@@ -0,0 +1,412 @@
+// Package workflow implements the review of policy changes: policies are
+// written as drafts, submitted for review and only become live, and thus
+// evaluated, once approved by enough approvers other than their authors.
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+)
+
+var (
+	// ErrSelfApproval is returned when an author of a policy approves it.
+	ErrSelfApproval = errors.New("authors cannot approve their own policy")
+	// ErrNotApprover is returned when someone not allowed by the approval
+	// rule of the policy resource approves it.
+	ErrNotApprover = errors.New("not an approver")
+	// ErrNoReview is returned when approving or rejecting a policy the
+	// Workflow has no review for, for example one submitted outside of it:
+	// the policy has to be submitted again. ReviewStore implementations
+	// return it for unknown policies.
+	ErrNoReview = errors.New("no review in progress")
+)
+
+// Repository is the policy store a Workflow writes to.
+type Repository interface {
+	Get(ctx context.Context, id string) (policies.Policy, error)
+	Add(ctx context.Context, p policies.Policy) error
+	Update(ctx context.Context, p policies.Policy) error
+}
+
+// ReviewStore stores the reviews of a Workflow. A store that outlives the
+// process, such as a database table, lets reviews in progress and the
+// approvals they record survive a restart.
+type ReviewStore interface {
+	// GetReview returns the review of the policy with policyID, or
+	// ErrNoReview.
+	GetReview(ctx context.Context, policyID string) (Review, error)
+	// SaveReview stores r, replacing the review of its policy.
+	SaveReview(ctx context.Context, r Review) error
+}
+
+// MemoryReviewStore is a ReviewStore keeping reviews in memory, so that they
+// are lost on restart. It is the default store of a Workflow.
+type MemoryReviewStore struct {
+	mu      sync.Mutex
+	reviews map[string]Review
+}
+
+// NewMemoryReviewStore returns an empty MemoryReviewStore.
+func NewMemoryReviewStore() *MemoryReviewStore {
+	return &MemoryReviewStore{reviews: make(map[string]Review)}
+}
+
+// GetReview returns the review of the policy with policyID, or ErrNoReview.
+func (s *MemoryReviewStore) GetReview(ctx context.Context, policyID string) (Review, error) {
+	if err := ctx.Err(); err != nil {
+		return Review{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reviews[policyID]
+	if !ok {
+		return Review{}, fmt.Errorf("policy %s: %w", policyID, ErrNoReview)
+	}
+	return r.clone(), nil
+}
+
+// SaveReview stores r, replacing the review of its policy.
+func (s *MemoryReviewStore) SaveReview(ctx context.Context, r Review) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reviews[r.PolicyID] = r.clone()
+	return nil
+}
+
+// ApprovalRule sets who can approve the policies of a resource and how many
+// distinct approvals they need. Any non-author can approve when Approvers is
+// empty. Required is at least 1.
+type ApprovalRule struct {
+	Approvers []string `json:"approvers,omitempty"`
+	Required  int      `json:"required"`
+}
+
+// Approval records that a policy was approved.
+type Approval struct {
+	By string    `json:"by"`
+	At time.Time `json:"at"`
+}
+
+// Review is the state of the review of a policy. Authors lists everyone who
+// wrote the draft; Rejection holds the reason of the last rejection.
+type Review struct {
+	PolicyID  string         `json:"policy_id"`
+	State     policies.State `json:"state"`
+	Authors   []string       `json:"authors"`
+	Approvals []Approval     `json:"approvals,omitempty"`
+	Rejection string         `json:"rejection,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Workflow moves policies through their lifecycle states, enforcing the
+// approval rules. Policies are written to its Repository with their State,
+// which the Evaluator uses to ignore policies that are not live. Reviews,
+// with their authors and approvers, are written to its ReviewStore.
+type Workflow struct {
+	repo        Repository
+	reviews     ReviewStore
+	rules       map[string]ApprovalRule
+	defaultRule ApprovalRule
+	now         func() time.Time
+
+	mu sync.Mutex
+}
+
+// Option configures a Workflow built by New.
+type Option func(*Workflow)
+
+// WithReviewStore sets the store of the reviews. It defaults to a
+// MemoryReviewStore.
+func WithReviewStore(s ReviewStore) Option {
+	return func(w *Workflow) {
+		w.reviews = s
+	}
+}
+
+// WithApprovalRule sets the approval rule of the policies of resource, a
+// resource pattern that also covers the children of the resources it
+// matches (see policies.MatchResource). When several rules match, the most
+// specific pattern wins.
+func WithApprovalRule(resource string, rule ApprovalRule) Option {
+	return func(w *Workflow) {
+		w.rules[resource] = rule
+	}
+}
+
+// WithDefaultApprovalRule sets the approval rule of resources without one. It
+// defaults to a single approval by anyone but the authors.
+func WithDefaultApprovalRule(rule ApprovalRule) Option {
+	return func(w *Workflow) {
+		w.defaultRule = rule
+	}
+}
+
+// WithClock sets the clock used to timestamp reviews. It defaults to
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(w *Workflow) {
+		w.now = now
+	}
+}
+
+// New returns a Workflow storing policies in repo.
+func New(repo Repository, opts ...Option) *Workflow {
+	w := &Workflow{
+		repo:        repo,
+		rules:       make(map[string]ApprovalRule),
+		defaultRule: ApprovalRule{Required: 1},
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.reviews == nil {
+		w.reviews = NewMemoryReviewStore()
+	}
+	return w
+}
+
+// Draft adds p as a draft written by author.
+func (w *Workflow) Draft(ctx context.Context, p policies.Policy, author string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p.State = policies.StateDraft
+	if err := w.repo.Add(ctx, p); err != nil {
+		return Review{}, err
+	}
+	r := Review{PolicyID: p.ID, State: p.State, Authors: []string{author}, UpdatedAt: w.now()}
+	if err := w.reviews.SaveReview(ctx, r); err != nil {
+		return Review{}, err
+	}
+	return r, nil
+}
+
+// Edit replaces the draft with the ID of p, adding author to its authors.
+// Policies past the draft state cannot be edited: approved ones can be sent
+// back to draft with Reopen.
+func (w *Workflow) Edit(ctx context.Context, p policies.Policy, author string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, err := w.repo.Get(ctx, p.ID)
+	if err != nil {
+		return Review{}, err
+	}
+	if current.State != policies.StateDraft {
+		return Review{}, fmt.Errorf("policy %s is %s, not a draft: %w", p.ID, current.State, policies.ErrInvalidTransition)
+	}
+
+	p.State = policies.StateDraft
+	if err := w.repo.Update(ctx, p); err != nil {
+		return Review{}, err
+	}
+	r, err := w.review(ctx, p.ID, p.State)
+	if err != nil {
+		return Review{}, err
+	}
+	if !slices.Contains(r.Authors, author) {
+		r.Authors = append(r.Authors, author)
+	}
+	return w.save(ctx, r)
+}
+
+// Submit sends the draft with id to review, clearing previous approvals. The
+// submitter counts as an author, so that drafts written outside the Workflow
+// are not approved by who submitted them.
+func (w *Workflow) Submit(ctx context.Context, id, submitter string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.transition(ctx, id, policies.StateInReview, func(r *Review) {
+		if !slices.Contains(r.Authors, submitter) {
+			r.Authors = append(r.Authors, submitter)
+		}
+		r.Approvals = nil
+		r.Rejection = ""
+	})
+}
+
+// Approve records the approval of the policy with id by approver. The policy
+// is approved, and becomes live, once the approval rule of its resource is
+// met. Approving twice counts once.
+func (w *Workflow) Approve(ctx context.Context, id, approver string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, r, err := w.inReview(ctx, id)
+	if err != nil {
+		return Review{}, err
+	}
+	rule := w.rule(p.Resource)
+	if slices.Contains(r.Authors, approver) {
+		return Review{}, fmt.Errorf("policy %s: %s: %w", id, approver, ErrSelfApproval)
+	}
+	if len(rule.Approvers) > 0 && !slices.Contains(rule.Approvers, approver) {
+		return Review{}, fmt.Errorf("policy %s: %s: %w", id, approver, ErrNotApprover)
+	}
+
+	if !slices.ContainsFunc(r.Approvals, func(a Approval) bool { return a.By == approver }) {
+		r.Approvals = append(r.Approvals, Approval{By: approver, At: w.now()})
+	}
+	if len(r.Approvals) < max(rule.Required, 1) {
+		return w.save(ctx, r)
+	}
+	return w.transition(ctx, id, policies.StateApproved, func(approved *Review) {
+		approved.Approvals = r.Approvals
+	})
+}
+
+// Reject sends the policy with id in review back to draft, recording reason.
+// Anyone allowed to approve the policy can reject it.
+func (w *Workflow) Reject(ctx context.Context, id, reviewer, reason string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, _, err := w.inReview(ctx, id)
+	if err != nil {
+		return Review{}, err
+	}
+	if rule := w.rule(p.Resource); len(rule.Approvers) > 0 && !slices.Contains(rule.Approvers, reviewer) {
+		return Review{}, fmt.Errorf("policy %s: %s: %w", id, reviewer, ErrNotApprover)
+	}
+
+	return w.transition(ctx, id, policies.StateDraft, func(r *Review) {
+		r.Approvals = nil
+		r.Rejection = reason
+	})
+}
+
+// Reopen sends the approved policy with id back to draft, so that it can be
+// edited. It stops being live.
+func (w *Workflow) Reopen(ctx context.Context, id string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.transition(ctx, id, policies.StateDraft, func(r *Review) {
+		r.Approvals = nil
+	})
+}
+
+// Activate puts the approved policy with id in use.
+func (w *Workflow) Activate(ctx context.Context, id string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.transition(ctx, id, policies.StateActive, nil)
+}
+
+// Retire withdraws the approved or active policy with id from use for good.
+func (w *Workflow) Retire(ctx context.Context, id string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.transition(ctx, id, policies.StateRetired, nil)
+}
+
+// Review returns the review of the policy with id, or ErrNoReview.
+func (w *Workflow) Review(ctx context.Context, id string) (Review, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.reviews.GetReview(ctx, id)
+}
+
+// transition moves the policy with id to state to, applying fn to its review
+// once the policy is stored.
+func (w *Workflow) transition(ctx context.Context, id string, to policies.State, fn func(r *Review)) (Review, error) {
+	p, err := w.repo.Get(ctx, id)
+	if err != nil {
+		return Review{}, err
+	}
+	if err := p.Transition(to); err != nil {
+		return Review{}, err
+	}
+	if err := w.repo.Update(ctx, p); err != nil {
+		return Review{}, err
+	}
+
+	r, err := w.review(ctx, id, to)
+	if err != nil {
+		return Review{}, err
+	}
+	if fn != nil {
+		fn(&r)
+	}
+	r.State = to
+	return w.save(ctx, r)
+}
+
+// inReview returns the policy with id and its review, which must be in
+// progress.
+func (w *Workflow) inReview(ctx context.Context, id string) (policies.Policy, Review, error) {
+	p, err := w.repo.Get(ctx, id)
+	if err != nil {
+		return policies.Policy{}, Review{}, err
+	}
+	if p.State != policies.StateInReview {
+		return policies.Policy{}, Review{}, fmt.Errorf("policy %s is %s, not in review: %w", id, p.State, policies.ErrInvalidTransition)
+	}
+	r, err := w.reviews.GetReview(ctx, id)
+	if err != nil {
+		return policies.Policy{}, Review{}, err
+	}
+	return p, r, nil
+}
+
+// review returns the review of the policy with id, starting one for
+// policies written outside the Workflow.
+func (w *Workflow) review(ctx context.Context, id string, state policies.State) (Review, error) {
+	r, err := w.reviews.GetReview(ctx, id)
+	if errors.Is(err, ErrNoReview) {
+		return Review{PolicyID: id, State: state}, nil
+	}
+	return r, err
+}
+
+// save timestamps r and writes it to the ReviewStore.
+func (w *Workflow) save(ctx context.Context, r Review) (Review, error) {
+	r.UpdatedAt = w.now()
+	if err := w.reviews.SaveReview(ctx, r); err != nil {
+		return Review{}, err
+	}
+	return r, nil
+}
+
+// rule returns the approval rule of the most specific pattern matching
+// resource, or the default rule. Equally specific patterns are compared as
+// strings so that the choice is stable.
+func (w *Workflow) rule(resource string) ApprovalRule {
+	rule, best, found := w.defaultRule, "", false
+	for pattern, r := range w.rules {
+		if !policies.MatchResource(pattern, resource) {
+			continue
+		}
+		if found {
+			a, b := policies.ResourceSpecificity(pattern), policies.ResourceSpecificity(best)
+			if a < b || a == b && pattern > best {
+				continue
+			}
+		}
+		rule, best, found = r, pattern, true
+	}
+	return rule
+}
+
+func (r *Review) clone() Review {
+	c := *r
+	c.Authors = slices.Clone(r.Authors)
+	c.Approvals = slices.Clone(r.Approvals)
+	return c
+}
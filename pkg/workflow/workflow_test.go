@@ -0,0 +1,221 @@
+package workflow_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tavaresphil/go-policy-engine/pkg/evaluators/native"
+	"github.com/tavaresphil/go-policy-engine/pkg/policies"
+	"github.com/tavaresphil/go-policy-engine/pkg/repositories/memory"
+	"github.com/tavaresphil/go-policy-engine/pkg/timerange"
+	"github.com/tavaresphil/go-policy-engine/pkg/workflow"
+)
+
+func testPolicy(id, resource string) policies.Policy {
+	return policies.Policy{
+		ID:         id,
+		Resource:   resource,
+		ResourceID: policies.AnyResourceID,
+		Effect:     policies.EffectDeny,
+		Period:     timerange.MustNew(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), nil),
+		Condition:  policies.PolicyCondition{Attribute: "role", Operator: policies.OpEqual, Value: "guest"},
+	}
+}
+
+func newWorkflow(t *testing.T) (*workflow.Workflow, *memory.Repository) {
+	t.Helper()
+	repo := &memory.Repository{}
+	w := workflow.New(repo,
+		workflow.WithApprovalRule("secrets", workflow.ApprovalRule{Approvers: []string{"sec1", "sec2", "alice"}, Required: 2}),
+	)
+	return w, repo
+}
+
+func state(t *testing.T, repo *memory.Repository, id string) policies.State {
+	t.Helper()
+	p, err := repo.Get(context.Background(), id)
+	assert.NoError(t, err)
+	return p.State
+}
+
+func TestWorkflow_Approval(t *testing.T) {
+	ctx := context.Background()
+	w, repo := newWorkflow(t)
+	ev := policies.NewEvaluator(native.NewNativeEngine(), repo)
+	req := policies.EvaluatorRequest{Resource: "doc", ResourceID: "1", Context: policies.MapAttributes{"role": "guest"}}
+
+	_, err := w.Draft(ctx, testPolicy("p", "doc"), "alice")
+	assert.NoError(t, err)
+	_, err = w.Edit(ctx, testPolicy("p", "doc"), "bob")
+	assert.NoError(t, err)
+	d, _ := ev.Eval(ctx, req)
+	assert.Equal(t, policies.OutcomeNotApplicable, d.Outcome, "drafts are not evaluated")
+
+	_, err = w.Approve(ctx, "p", "carol")
+	assert.ErrorIs(t, err, policies.ErrInvalidTransition, "drafts cannot be approved")
+
+	_, err = w.Submit(ctx, "p", "alice")
+	assert.NoError(t, err)
+	_, err = w.Edit(ctx, testPolicy("p", "doc"), "alice")
+	assert.ErrorIs(t, err, policies.ErrInvalidTransition, "policies in review cannot be edited")
+	_, err = w.Approve(ctx, "p", "bob")
+	assert.ErrorIs(t, err, workflow.ErrSelfApproval)
+
+	r, err := w.Approve(ctx, "p", "carol")
+	assert.NoError(t, err)
+	assert.Equal(t, policies.StateApproved, r.State)
+	assert.Equal(t, []string{"alice", "bob"}, r.Authors)
+	assert.Equal(t, "carol", r.Approvals[0].By)
+	d, _ = ev.Eval(ctx, req)
+	assert.Equal(t, policies.OutcomeDeny, d.Outcome, "approved policies are evaluated")
+
+	_, err = w.Activate(ctx, "p")
+	assert.NoError(t, err)
+	assert.Equal(t, policies.StateActive, state(t, repo, "p"))
+
+	_, err = w.Retire(ctx, "p")
+	assert.NoError(t, err)
+	d, _ = ev.Eval(ctx, req)
+	assert.Equal(t, policies.OutcomeNotApplicable, d.Outcome, "retired policies are not evaluated")
+	_, err = w.Activate(ctx, "p")
+	assert.ErrorIs(t, err, policies.ErrInvalidTransition)
+}
+
+func TestWorkflow_ApprovalRule(t *testing.T) {
+	ctx := context.Background()
+	w, repo := newWorkflow(t)
+
+	_, _ = w.Draft(ctx, testPolicy("s", "secrets"), "alice")
+	_, err := w.Submit(ctx, "s", "alice")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		approver string
+		err      error
+		state    policies.State
+	}{
+		{name: "outsider", approver: "bob", err: workflow.ErrNotApprover, state: policies.StateInReview},
+		{name: "author approver", approver: "alice", err: workflow.ErrSelfApproval, state: policies.StateInReview},
+		{name: "first approval", approver: "sec1", state: policies.StateInReview},
+		{name: "repeated approval", approver: "sec1", state: policies.StateInReview},
+		{name: "second approval", approver: "sec2", state: policies.StateApproved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := w.Approve(ctx, "s", tt.approver)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.state, state(t, repo, "s"))
+		})
+	}
+}
+
+func TestWorkflow_Reject(t *testing.T) {
+	ctx := context.Background()
+	w, repo := newWorkflow(t)
+
+	_, _ = w.Draft(ctx, testPolicy("p", "doc"), "alice")
+	_, _ = w.Submit(ctx, "p", "alice")
+	r, err := w.Reject(ctx, "p", "carol", "too broad")
+	assert.NoError(t, err)
+	assert.Equal(t, policies.StateDraft, r.State)
+	assert.Equal(t, "too broad", r.Rejection)
+
+	_, _ = w.Submit(ctx, "p", "alice")
+	_, _ = w.Approve(ctx, "p", "carol")
+	r, err = w.Reopen(ctx, "p")
+	assert.NoError(t, err)
+	assert.Empty(t, r.Approvals)
+	assert.Equal(t, policies.StateDraft, state(t, repo, "p"))
+}
+
+func TestWorkflow_PoliciesWrittenOutside(t *testing.T) {
+	ctx := context.Background()
+	w, repo := newWorkflow(t)
+
+	p := testPolicy("p", "doc")
+	p.State = policies.StateDraft
+	assert.NoError(t, repo.Add(ctx, p))
+	_, err := w.Submit(ctx, "p", "mallory")
+	assert.NoError(t, err)
+	_, err = w.Approve(ctx, "p", "mallory")
+	assert.ErrorIs(t, err, workflow.ErrSelfApproval, "the submitter is an author")
+
+	p = testPolicy("q", "doc")
+	p.State = policies.StateInReview
+	assert.NoError(t, repo.Add(ctx, p))
+	_, err = w.Approve(ctx, "q", "carol")
+	assert.ErrorIs(t, err, workflow.ErrNoReview)
+}
+
+func TestWorkflow_ReviewStore(t *testing.T) {
+	ctx := context.Background()
+	repo := &memory.Repository{}
+	store := workflow.NewMemoryReviewStore()
+	rule := workflow.WithApprovalRule("doc", workflow.ApprovalRule{Required: 2})
+
+	w := workflow.New(repo, workflow.WithReviewStore(store), rule)
+	_, _ = w.Draft(ctx, testPolicy("p", "doc"), "alice")
+	_, _ = w.Submit(ctx, "p", "alice")
+	_, err := w.Approve(ctx, "p", "carol")
+	assert.NoError(t, err)
+
+	restarted := workflow.New(repo, workflow.WithReviewStore(store), rule)
+	r, err := restarted.Approve(ctx, "p", "dave")
+	assert.NoError(t, err)
+	assert.Equal(t, policies.StateApproved, r.State)
+
+	r, err = restarted.Review(ctx, "p")
+	assert.NoError(t, err)
+	if assert.Len(t, r.Approvals, 2) {
+		assert.Equal(t, "carol", r.Approvals[0].By)
+		assert.Equal(t, "dave", r.Approvals[1].By)
+	}
+
+	_, err = restarted.Review(ctx, "unknown")
+	assert.ErrorIs(t, err, workflow.ErrNoReview)
+}
+
+func TestWorkflow_ApprovalRuleMatching(t *testing.T) {
+	ctx := context.Background()
+	w := workflow.New(&memory.Repository{},
+		workflow.WithApprovalRule("orgs", workflow.ApprovalRule{Approvers: []string{"owner"}, Required: 1}),
+		workflow.WithApprovalRule("orgs/*/secrets", workflow.ApprovalRule{Approvers: []string{"sec"}, Required: 1}),
+	)
+
+	tests := []struct {
+		name     string
+		resource string
+		approver string
+		err      error
+	}{
+		{name: "child of a rule resource", resource: "orgs/42/projects", approver: "owner"},
+		{name: "child of a rule resource rejects others", resource: "orgs/42/projects", approver: "sec", err: workflow.ErrNotApprover},
+		{name: "most specific pattern wins", resource: "orgs/42/secrets/db", approver: "sec"},
+		{name: "less specific rule does not apply", resource: "orgs/42/secrets", approver: "owner", err: workflow.ErrNotApprover},
+		{name: "default rule", resource: "docs", approver: "anyone"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := fmt.Sprintf("p%d", i)
+			_, _ = w.Draft(ctx, testPolicy(id, tt.resource), "alice")
+			_, _ = w.Submit(ctx, id, "alice")
+
+			_, err := w.Approve(ctx, id, tt.approver)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}